@@ -5,7 +5,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"log/slog"
 	"net/http"
 	"os"
@@ -15,11 +14,10 @@ import (
 	"sync"
 	"time"
 
-	"github.com/fsnotify/fsnotify"
 	"github.com/gorilla/websocket"
 	"github.com/pocketbase/dbx"
-	"github.com/pocketbase/pocketbase"
 	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/security"
 )
 
 var (
@@ -112,21 +110,15 @@ func (sf *ScriptFlow) ApiTaskLogWebSocket(e *core.RequestEvent) error {
 		openWebSocketsMutex.Unlock()
 	}()
 
-	// Locate the log file
-	logFilePath := sf.taskTodayLogFilePath(taskId)
-	sf.app.Logger().Debug("TaskLogWebSocket handler", slog.String("file", logFilePath))
-	if _, err := os.Stat(logFilePath); os.IsNotExist(err) {
-		err := conn.WriteMessage(websocket.TextMessage, []byte("Log file not found"))
-		if err != nil {
-			return e.InternalServerError(err.Error(), "Failed to write to socket")
-		}
-		return e.Next()
+	// Send the last lines of the task log and follow the log files of its runs
+	lines, err := sf.taskLogLines(taskId, 100)
+	if err != nil {
+		return e.InternalServerError(err.Error(), "Failed to read last lines")
 	}
-
-	if err := sendLastLines(conn, logFilePath, 100); err != nil {
+	if err := sendLines(conn, lines); err != nil {
 		return e.InternalServerError(err.Error(), "Failed to send last lines")
 	}
-	if err := watchFileChanges(conn, logFilePath, sf.app); err != nil {
+	if err := watchTaskLogs(conn, sf.taskLogRootDir(taskId), sf.app.Logger()); err != nil {
 		return e.InternalServerError(err.Error(), "Failed to watch file changes")
 	}
 	return e.Next()
@@ -142,18 +134,7 @@ func (sf *ScriptFlow) ApiRunLog(e *core.RequestEvent) error {
 		return e.NotFoundError("Run not found", slog.String("runId", runId))
 	}
 
-	// select task by run
-	task, err := sf.app.FindRecordById(CollectionTasks, run.GetString("task"))
-	if err != nil {
-		return e.NotFoundError("Task not found", slog.String("taskId", run.GetString("task")))
-	}
-
-	// get log file path
-	logFilePath := sf.taskLogFilePathDate(
-		task.GetString("id"),
-		run.GetDateTime("created").Time(),
-	)
-	logs, err := extractLogsForRun(logFilePath, runId)
+	logs, err := sf.readRunLog(run)
 	if err != nil {
 		return e.InternalServerError(err.Error(), slog.String("runId", runId))
 	}
@@ -171,6 +152,11 @@ func (sf *ScriptFlow) ApiScriptFlowStats(e *core.RequestEvent) error {
 	return e.JSON(http.StatusOK, map[string]int{"WebSocketsCount": count})
 }
 
+// ApiRunTask starts the task and returns id of its run, which is recorded as queued until it starts.
+// Body: {"params": {...}} with values of the task parameters. Query params: wait=true blocks until the run
+// finishes, at most timeout (duration or seconds, default 5m), and returns status, exit code and the last lines
+// (default 100) of the output of its last attempt. If the timeout expires first, the current state is returned
+// with 202 Accepted and the run goes on.
 func (sf *ScriptFlow) ApiRunTask(e *core.RequestEvent) error {
 	taskId := e.Request.PathValue("taskId")
 
@@ -182,11 +168,219 @@ func (sf *ScriptFlow) ApiRunTask(e *core.RequestEvent) error {
 		return e.BadRequestError("task is not active", nil)
 	}
 
-	if sf.isTaskRunning(taskId) {
+	q := e.Request.URL.Query()
+	timeout, err := parseWaitTimeout(q.Get("timeout"))
+	if err != nil {
+		return e.BadRequestError(err.Error(), nil)
+	}
+	lines := parseQueryInt(q.Get("lines"), RunWaitOutputLines, 1, maxLogLines)
+
+	// values of the task parameters are optional, missing ones are replaced by defaults
+	var body struct {
+		Params map[string]any `json:"params"`
+	}
+	if err := e.BindBody(&body); err != nil {
+		return e.BadRequestError("invalid request body", err)
+	}
+	params, err := resolveTaskParams(task, body.Params)
+	if err != nil {
+		return e.BadRequestError(err.Error(), nil)
+	}
+
+	if reason, _ := sf.calendarSkipReason(task, time.Now()); reason != "" {
+		return e.JSON(http.StatusConflict, map[string]string{"message": "task is not run: " + reason})
+	}
+	if sf.wouldSkipTrigger(task) {
 		return e.JSON(http.StatusConflict, map[string]string{"message": "task is already running"})
 	}
-	go sf.runTask(taskId)
-	return e.JSON(http.StatusOK, map[string]string{"status": "started", "taskId": taskId})
+
+	runId := core.GenerateDefaultRandomId()
+	trigger := RunTrigger{RunId: runId, Type: TriggerManual, Params: params}
+	// the run is polled by its id right away, also while the trigger waits for a slot of the task
+	if _, err := sf.recordUnexecutedRun(task, trigger, RunStatusQueued, ""); err != nil {
+		return e.InternalServerError("failed to save run record", err)
+	}
+	if q.Get("wait") != "true" {
+		go sf.triggerTask(taskId, trigger)
+		return e.JSON(http.StatusOK, map[string]string{"status": "started", "taskId": taskId, "runId": runId})
+	}
+
+	// wait mode: the run keeps going when the timeout expires or the client disconnects
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		sf.triggerTask(taskId, trigger)
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	httpStatus := http.StatusOK
+	select {
+	case <-done:
+	case <-timer.C:
+		httpStatus = http.StatusAccepted
+	case <-e.Request.Context().Done():
+		return nil
+	}
+
+	// retried runs are reported by their last attempt
+	result, err := sf.runResult(runId, lines)
+	if err != nil {
+		return e.InternalServerError("failed to get run result", err)
+	}
+	return e.JSON(httpStatus, result)
+}
+
+// ApiTaskWebhook triggers the task by its inbound webhook, which is authorized by the webhook token of the task
+// instead of a user session. The token is passed in the URL, signed webhooks pass HMAC-SHA256 signature of
+// the timestamp and the body in WebhookSignatureHeader and the timestamp in WebhookTimestampHeader instead.
+// Fields of the JSON payload selected by webhook_env of the task are passed to the run as env variables.
+// Returns id of the run to poll, the run is recorded as queued until it starts, or as skipped if the trigger
+// is not executed.
+func (sf *ScriptFlow) ApiTaskWebhook(e *core.RequestEvent) error {
+	taskId := e.Request.PathValue("taskId")
+
+	body, err := io.ReadAll(http.MaxBytesReader(e.Response, e.Request.Body, WebhookMaxPayloadSize))
+	if err != nil {
+		return e.BadRequestError("failed to read request body", err)
+	}
+	task, err := sf.app.FindRecordById(CollectionTasks, taskId)
+	if err != nil {
+		return e.UnauthorizedError("invalid webhook token or signature", nil)
+	}
+	signature := e.Request.Header.Get(WebhookSignatureHeader)
+	timestamp := e.Request.Header.Get(WebhookTimestampHeader)
+	if !verifyWebhook(task.GetString("webhook_token"), task.GetBool("webhook_signed"), e.Request.PathValue("token"), signature, timestamp, body, time.Now()) {
+		return e.UnauthorizedError("invalid webhook token or signature", nil)
+	}
+	if !task.GetBool("active") {
+		return e.BadRequestError("task is not active", nil)
+	}
+
+	mapping := map[string]string{}
+	if err := task.UnmarshalJSONField("webhook_env", &mapping); err != nil {
+		return e.InternalServerError("invalid webhook env of the task", err)
+	}
+	env, err := webhookEnv(mapping, body)
+	if err != nil {
+		return e.BadRequestError(err.Error(), nil)
+	}
+
+	trigger := RunTrigger{RunId: core.GenerateDefaultRandomId(), Type: TriggerWebhook, Env: env}
+	// the run is polled by its id right away, also while the trigger waits for a slot of the task
+	if _, err := sf.recordUnexecutedRun(task, trigger, RunStatusQueued, ""); err != nil {
+		return e.InternalServerError("failed to save run record", err)
+	}
+	go sf.triggerTask(taskId, trigger)
+	return e.JSON(http.StatusOK, map[string]string{"status": "started", "taskId": taskId, "runId": trigger.RunId})
+}
+
+// ApiTaskWebhookToken generates a new webhook token of the task, the previous one stops working.
+// Body: {"signed": true} to require signed requests. The token is returned only once, it is hidden from the API.
+func (sf *ScriptFlow) ApiTaskWebhookToken(e *core.RequestEvent) error {
+	taskId := e.Request.PathValue("taskId")
+
+	task, err := sf.app.FindRecordById(CollectionTasks, taskId)
+	if err != nil {
+		return e.NotFoundError("task not found", nil)
+	}
+	var body struct {
+		Signed bool `json:"signed"`
+	}
+	if err := e.BindBody(&body); err != nil {
+		return e.BadRequestError("invalid request body", err)
+	}
+
+	token := security.RandomString(WebhookTokenLength)
+	task.Set("webhook_token", token)
+	task.Set("webhook_signed", body.Signed)
+	if err := sf.app.Save(task); err != nil {
+		return e.InternalServerError("failed to save task", err)
+	}
+
+	url := sf.app.Settings().Meta.AppURL + "/api/scriptflow/webhook/" + taskId
+	if !body.Signed {
+		url += "/" + token
+	}
+	return e.JSON(http.StatusOK, map[string]any{"taskId": taskId, "token": token, "signed": body.Signed, "url": url})
+}
+
+// ApiDeleteTaskWebhookToken disables the webhook of the task
+func (sf *ScriptFlow) ApiDeleteTaskWebhookToken(e *core.RequestEvent) error {
+	taskId := e.Request.PathValue("taskId")
+
+	task, err := sf.app.FindRecordById(CollectionTasks, taskId)
+	if err != nil {
+		return e.NotFoundError("task not found", nil)
+	}
+	task.Set("webhook_token", "")
+	if err := sf.app.Save(task); err != nil {
+		return e.InternalServerError("failed to save task", err)
+	}
+	return e.NoContent(http.StatusNoContent)
+}
+
+// ApiRunPipeline runs the pipeline in background, the pipeline run is killed with the run kill endpoint
+func (sf *ScriptFlow) ApiRunPipeline(e *core.RequestEvent) error {
+	pipelineId := e.Request.PathValue("pipelineId")
+
+	pipeline, err := sf.app.FindRecordById(CollectionPipelines, pipelineId)
+	if err != nil {
+		return e.NotFoundError("pipeline not found", nil)
+	}
+	if !pipeline.GetBool("active") {
+		return e.BadRequestError("pipeline is not active", nil)
+	}
+	if sf.runningTaskCount(pipelineLockKey(pipelineId)) > 0 {
+		return e.JSON(http.StatusConflict, map[string]string{"message": SkipReasonPipelineRunning})
+	}
+	go sf.runPipeline(pipelineId)
+	return e.JSON(http.StatusOK, map[string]string{"status": "started", "pipelineId": pipelineId})
+}
+
+// ApiRunTaskAt schedules one-time run of the task. Body: {"at": "<RFC3339>"} or {"delay": "<duration>"}.
+// The pending run is stored in the scheduled_runs collection, deleting the record cancels it.
+func (sf *ScriptFlow) ApiRunTaskAt(e *core.RequestEvent) error {
+	taskId := e.Request.PathValue("taskId")
+
+	task, err := sf.app.FindRecordById(CollectionTasks, taskId)
+	if err != nil {
+		return e.NotFoundError("task not found", nil)
+	}
+	if !task.GetBool("active") {
+		return e.BadRequestError("task is not active", nil)
+	}
+
+	var body struct {
+		At    string `json:"at"`
+		Delay string `json:"delay"`
+	}
+	if err := e.BindBody(&body); err != nil {
+		return e.BadRequestError("invalid request body", err)
+	}
+	var at time.Time
+	switch {
+	case body.At != "" && body.Delay == "":
+		if at, err = time.Parse(time.RFC3339, body.At); err != nil {
+			return e.BadRequestError("invalid at, RFC3339 time expected", nil)
+		}
+	case body.Delay != "" && body.At == "":
+		delay, err := time.ParseDuration(body.Delay)
+		if err != nil || delay <= 0 {
+			return e.BadRequestError("invalid delay, positive duration expected", nil)
+		}
+		at = time.Now().Add(delay)
+	default:
+		return e.BadRequestError("either at or delay is required", nil)
+	}
+	if !at.After(time.Now()) {
+		return e.BadRequestError("at must be in the future", nil)
+	}
+
+	record, err := sf.createScheduledRun(task, at)
+	if err != nil {
+		return e.InternalServerError("failed to schedule run", err)
+	}
+	return e.JSON(http.StatusOK, scheduledRunItem(record))
 }
 
 func (sf *ScriptFlow) ApiKillRun(e *core.RequestEvent) error {
@@ -199,6 +393,51 @@ func (sf *ScriptFlow) ApiKillRun(e *core.RequestEvent) error {
 	return e.JSON(http.StatusOK, map[string]string{"status": "killed", "runId": runId})
 }
 
+// ApiNodeQueue returns number of runs executing on the node and runs waiting for a free slot in queue order
+func (sf *ScriptFlow) ApiNodeQueue(e *core.RequestEvent) error {
+	nodeId := e.Request.PathValue("nodeId")
+
+	node, err := sf.app.FindRecordById(CollectionNodes, nodeId)
+	if err != nil {
+		return e.NotFoundError("node not found", nil)
+	}
+	return e.JSON(http.StatusOK, sf.nodeQueueItem(node))
+}
+
+// ApiSchedulePreview validates the schedule and returns its next fire times.
+// Query params: schedule, taskId (optional, seeds H notation and provides the time zone),
+// timezone (optional, overrides the task zone), count (default 5, max 100).
+func (sf *ScriptFlow) ApiSchedulePreview(e *core.RequestEvent) error {
+	q := e.Request.URL.Query()
+	schedule := strings.TrimSpace(q.Get("schedule"))
+	if schedule == "" {
+		return e.BadRequestError("schedule is required", nil)
+	}
+	count := parseQueryInt(q.Get("count"), 5, 1, 100)
+
+	seed := ""
+	timezone := q.Get("timezone")
+	if taskId := q.Get("taskId"); taskId != "" {
+		task, err := sf.app.FindRecordById(CollectionTasks, taskId)
+		if err != nil {
+			return e.NotFoundError("task not found", nil)
+		}
+		seed = task.Id
+		if timezone == "" {
+			timezone = sf.taskTimezone(task)
+		}
+	}
+	if !isValidTimezone(timezone) {
+		return e.BadRequestError("invalid time zone", nil)
+	}
+
+	preview, err := previewSchedule(schedule, seed, timezone, time.Now(), count)
+	if err != nil {
+		return e.BadRequestError(err.Error(), nil)
+	}
+	return e.JSON(http.StatusOK, preview)
+}
+
 // ApiLatestRuns returns the most recent run per task in a single query,
 // replacing N individual SDK calls from the frontend task list view.
 func (sf *ScriptFlow) ApiLatestRuns(e *core.RequestEvent) error {
@@ -254,7 +493,7 @@ func appendWithRollingWindow(logs []string, line string, maxLines int) []string
 }
 
 func extractLogsForRun(logFilePath, runId string) ([]string, error) {
-	file, err := os.Open(logFilePath)
+	file, err := openLogReader(logFilePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open log file: %w", err)
 	}
@@ -283,19 +522,8 @@ func extractLogsForRun(logFilePath, runId string) ([]string, error) {
 	return logs, nil
 }
 
-// Read and send the last N lines of the file
-func sendLastLines(conn *websocket.Conn, filePath string, n int) error {
-	file, err := os.Open(filePath)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	lines, err := readLastLines(file, n)
-	if err != nil {
-		return err
-	}
-
+// Send the lines, one message per line
+func sendLines(conn *websocket.Conn, lines []string) error {
 	for _, line := range lines {
 		message := line + "\n"
 		if err := conn.WriteMessage(websocket.TextMessage, []byte(message)); err != nil {
@@ -305,50 +533,6 @@ func sendLastLines(conn *websocket.Conn, filePath string, n int) error {
 	return nil
 }
 
-// Watch for changes in the log file and send new content
-func watchFileChanges(conn *websocket.Conn, filePath string, app *pocketbase.PocketBase) error {
-	watcher, err := fsnotify.NewWatcher()
-	if err != nil {
-		return err
-	}
-	defer watcher.Close()
-
-	if err := watcher.Add(filePath); err != nil {
-		return err
-	}
-
-	file, err := os.Open(filePath)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	// Start at the end of the file
-	offset, err := file.Seek(0, io.SeekEnd)
-	if err != nil {
-		return err
-	}
-	app.Logger().Debug("watchFileChanges", slog.Int64("offset", offset))
-
-	for {
-		select {
-		case event := <-watcher.Events:
-			if event.Op&fsnotify.Write == fsnotify.Write {
-				// Read and send new lines
-				newOffset, err := streamNewLines(file, conn, offset)
-				if err != nil {
-					log.Printf("Error streaming new lines: %v", err)
-					return err
-				}
-				offset = newOffset
-			}
-		case err := <-watcher.Errors:
-			log.Printf("Watcher error: %v", err)
-			return err
-		}
-	}
-}
-
 // Helper function to read and send new lines from the file
 func streamNewLines(file *os.File, conn *websocket.Conn, startOffset int64) (int64, error) {
 	// Seek to the last offset
@@ -429,14 +613,10 @@ func readLastLines(file *os.File, n int) ([]string, error) {
 	return lines, nil
 }
 
-// readLinesPage returns up to limit lines ending at (total - offset) from the end.
+// linesPage returns up to limit of the last offset+limit+1 lines ending at (total - offset) from the end.
 // offset=0 → last limit lines; offset=100 → lines 100–199 from end.
-// hasMore=true means there are older lines before this page.
-func readLinesPage(file *os.File, offset, limit int) ([]string, bool, error) {
-	all, err := readLastLines(file, offset+limit+1)
-	if err != nil {
-		return nil, false, err
-	}
+// hasMore=true means there are older lines before the page.
+func linesPage(all []string, offset, limit int) ([]string, bool) {
 	hasMore := len(all) > offset+limit
 	if hasMore {
 		all = all[1:] // drop the sentinel
@@ -445,12 +625,12 @@ func readLinesPage(file *os.File, offset, limit int) ([]string, bool, error) {
 	if len(all) > offset {
 		all = all[:len(all)-offset]
 	} else {
-		return nil, false, nil
+		return nil, false
 	}
 	if len(all) > limit {
 		all = all[len(all)-limit:]
 	}
-	return all, hasMore, nil
+	return all, hasMore
 }
 
 // parseQueryInt parses v as an int with bounds [minVal, maxVal].
@@ -466,7 +646,7 @@ func parseQueryInt(v string, defaultVal, minVal, maxVal int) int {
 	return n
 }
 
-// ApiTaskLogLines serves paginated log lines for the current day's task log.
+// ApiTaskLogLines serves paginated lines of the task log, the logs of its runs one after another.
 // Query params: offset (default 100), limit (default 100, max 500).
 func (sf *ScriptFlow) ApiTaskLogLines(e *core.RequestEvent) error {
 	taskId := e.Request.PathValue("taskId")
@@ -474,19 +654,12 @@ func (sf *ScriptFlow) ApiTaskLogLines(e *core.RequestEvent) error {
 	offset := parseQueryInt(q.Get("offset"), 100, 0, 0)
 	limit := parseQueryInt(q.Get("limit"), 100, 1, 500)
 
-	logFilePath := sf.taskTodayLogFilePath(taskId)
-	file, err := os.Open(logFilePath)
+	lines, hasMore, err := sf.taskLogLinesPage(taskId, offset, limit)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return e.JSON(http.StatusOK, map[string]any{"lines": []string{}, "has_more": false})
-		}
 		return e.InternalServerError(err.Error(), nil)
 	}
-	defer file.Close()
-
-	lines, hasMore, err := readLinesPage(file, offset, limit)
-	if err != nil {
-		return e.InternalServerError(err.Error(), nil)
+	if lines == nil {
+		lines = []string{}
 	}
 	return e.JSON(http.StatusOK, map[string]any{"lines": lines, "has_more": hasMore})
 }
@@ -179,7 +179,8 @@ func TestReadLastLines(t *testing.T) {
 	}
 }
 
-func TestReadLinesPage(t *testing.T) {
+// TestLinesPageOfLogFile pages the last lines of a log file, the way taskLogLinesPage pages the task log
+func TestLinesPageOfLogFile(t *testing.T) {
 	// Both forms: without and with trailing newline (real log file format)
 	content10 := strings.Join([]string{"L1", "L2", "L3", "L4", "L5", "L6", "L7", "L8", "L9", "L10"}, "\n")
 	content10NL := content10 + "\n" // real log format
@@ -281,8 +282,9 @@ func TestReadLinesPage(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			f := writeTempFile(t, tt.content)
-			gotLines, gotHasMore, err := readLinesPage(f, tt.offset, tt.limit)
+			all, err := readLastLines(f, tt.offset+tt.limit+1)
 			require.NoError(t, err)
+			gotLines, gotHasMore := linesPage(all, tt.offset, tt.limit)
 			assert.Equal(t, tt.wantLines, gotLines)
 			assert.Equal(t, tt.wantHasMore, gotHasMore)
 		})
@@ -351,8 +353,9 @@ func simulateScrollAll(t *testing.T, file *os.File, wsPageSize, apiPageSize int)
 
 	var olderLines []string
 	for {
-		page, hasMore, err := readLinesPage(file, liveLineOffset, apiPageSize)
+		all, err := readLastLines(file, liveLineOffset+apiPageSize+1)
 		require.NoError(t, err)
+		page, hasMore := linesPage(all, liveLineOffset, apiPageSize)
 		if len(page) > 0 {
 			olderLines = append(page, olderLines...)
 			liveLineOffset += len(page)
@@ -381,7 +384,7 @@ func TestApiTaskLogLinesScrollInvariant(t *testing.T) {
 		{"one line beyond ws", 101, 100, 100},
 		// WS page + one full API page
 		{"ws + one api page", 200, 100, 100},
-		// boundary: readLinesPage sentinel edge (offset+limit+1 == file size)
+		// boundary: linesPage sentinel edge (offset+limit+1 == file size)
 		{"ws + api page + 1", 201, 100, 100},
 		// multiple full API pages
 		{"many pages", 1000, 100, 100},
@@ -470,8 +473,9 @@ func TestApiTaskLogLinesScrollWithLiveGrowth(t *testing.T) {
 
 			var olderLines []string
 			for {
-				page, hasMore, err := readLinesPage(f, liveLineOffset, tt.apiPageSize)
+				all, err := readLastLines(f, liveLineOffset+tt.apiPageSize+1)
 				require.NoError(t, err)
+				page, hasMore := linesPage(all, liveLineOffset, tt.apiPageSize)
 				if len(page) > 0 {
 					olderLines = append(page, olderLines...)
 					liveLineOffset += len(page)
@@ -516,8 +520,9 @@ func TestApiTaskLogLinesHasMoreBoundary(t *testing.T) {
 				lines[i] = fmt.Sprintf("L%05d", i+1)
 			}
 			f := writeTempFile(t, strings.Join(lines, "\n"))
-			_, hasMore, err := readLinesPage(f, 100, 100)
+			all, err := readLastLines(f, 100+100+1)
 			require.NoError(t, err)
+			_, hasMore := linesPage(all, 100, 100)
 			assert.Equal(t, tt.wantHasMore, hasMore,
 				"hasMore for %d-line file at offset=100 limit=100", tt.totalLines)
 		})
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// calendarDays maps lower case weekday names and their three letter abbreviations to weekdays
+var calendarDays = func() map[string]time.Weekday {
+	days := map[string]time.Weekday{}
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		name := strings.ToLower(day.String())
+		days[name] = day
+		days[name[:3]] = day
+	}
+	return days
+}()
+
+// CalendarRange is a date range of a calendar. Bounds are RFC3339 times or dates, a date as the end bound
+// includes the whole day.
+type CalendarRange struct {
+	Start string `json:"start" yaml:"start"`
+	End   string `json:"end" yaml:"end"`
+}
+
+// CalendarWindow is a weekly recurring window of a calendar. Start and end are "HH:MM" times of the days,
+// a window ending at or before its start ends the next day. Empty days means every day.
+type CalendarWindow struct {
+	Days  []string `json:"days" yaml:"days"`
+	Start string   `json:"start" yaml:"start"`
+	End   string   `json:"end" yaml:"end"`
+}
+
+// Calendar is a parsed calendar, a time is inside it when it is inside any of its ranges or windows
+type Calendar struct {
+	Name     string
+	location *time.Location
+	ranges   []calendarRange
+	windows  []calendarWindow
+}
+
+type calendarRange struct {
+	start, end time.Time
+}
+
+type calendarWindow struct {
+	days       [7]bool
+	start, end time.Duration // offsets from midnight
+}
+
+// parseCalendar validates ranges and windows of the calendar, times without offset are in the time zone,
+// empty time zone means server local zone
+func parseCalendar(name string, timezone string, ranges []CalendarRange, windows []CalendarWindow) (*Calendar, error) {
+	location := time.Local
+	if timezone != "" {
+		var err error
+		if location, err = time.LoadLocation(timezone); err != nil {
+			return nil, fmt.Errorf("invalid time zone %q: %w", timezone, err)
+		}
+	}
+
+	calendar := &Calendar{Name: name, location: location}
+	for _, r := range ranges {
+		start, _, err := parseCalendarTime(r.Start, location)
+		if err != nil {
+			return nil, fmt.Errorf("invalid range start: %w", err)
+		}
+		end, isDate, err := parseCalendarTime(r.End, location)
+		if err != nil {
+			return nil, fmt.Errorf("invalid range end: %w", err)
+		}
+		if isDate {
+			end = end.AddDate(0, 0, 1)
+		}
+		if !end.After(start) {
+			return nil, fmt.Errorf("range end %q is not after its start %q", r.End, r.Start)
+		}
+		calendar.ranges = append(calendar.ranges, calendarRange{start: start, end: end})
+	}
+
+	for _, w := range windows {
+		var window calendarWindow
+		for _, day := range w.Days {
+			weekday, ok := calendarDays[strings.ToLower(strings.TrimSpace(day))]
+			if !ok {
+				return nil, fmt.Errorf("invalid window day: %q", day)
+			}
+			window.days[weekday] = true
+		}
+		if len(w.Days) == 0 {
+			window.days = [7]bool{true, true, true, true, true, true, true}
+		}
+		var err error
+		if window.start, err = parseClock(w.Start); err != nil {
+			return nil, fmt.Errorf("invalid window start: %w", err)
+		}
+		if window.end, err = parseClock(w.End); err != nil {
+			return nil, fmt.Errorf("invalid window end: %w", err)
+		}
+		calendar.windows = append(calendar.windows, window)
+	}
+	return calendar, nil
+}
+
+// parseCalendarTime parses RFC3339 time or date in the location, isDate reports whether s is a date
+func parseCalendarTime(s string, location *time.Location) (t time.Time, isDate bool, err error) {
+	if t, err = time.Parse(time.RFC3339, s); err == nil {
+		return t, false, nil
+	}
+	if t, err = time.ParseInLocation(time.DateOnly, s, location); err == nil {
+		return t, true, nil
+	}
+	return time.Time{}, false, fmt.Errorf("%q is neither RFC3339 time nor date", s)
+}
+
+// parseClock parses "HH:MM" time of day as offset from midnight
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not HH:MM time", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Contains reports whether t is inside any range or window of the calendar
+func (c *Calendar) Contains(t time.Time) bool {
+	for _, r := range c.ranges {
+		if !t.Before(r.start) && t.Before(r.end) {
+			return true
+		}
+	}
+
+	t = t.In(c.location)
+	for _, w := range c.windows {
+		// a window containing t started on the same day or, if it ends the next day, on the previous one
+		for _, daysBack := range []int{0, 1} {
+			year, month, day := t.AddDate(0, 0, -daysBack).Date()
+			if !w.days[time.Date(year, month, day, 0, 0, 0, 0, c.location).Weekday()] {
+				continue
+			}
+			// times are built from the wall clock, so that windows keep their local time across DST changes
+			start := clockTime(year, month, day, w.start, c.location)
+			end := clockTime(year, month, day, w.end, c.location)
+			if w.end <= w.start {
+				end = clockTime(year, month, day+1, w.end, c.location)
+			}
+			if !t.Before(start) && t.Before(end) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// clockTime returns time of the day at the offset from midnight in the location
+func clockTime(year int, month time.Month, day int, offset time.Duration, location *time.Location) time.Time {
+	return time.Date(year, month, day, int(offset/time.Hour), int(offset%time.Hour/time.Minute), 0, 0, location)
+}
+
+// calendarFromRecord parses the calendar record
+func calendarFromRecord(record *core.Record) (*Calendar, error) {
+	var ranges []CalendarRange
+	if err := record.UnmarshalJSONField("ranges", &ranges); err != nil {
+		return nil, fmt.Errorf("invalid ranges: %w", err)
+	}
+	var windows []CalendarWindow
+	if err := record.UnmarshalJSONField("windows", &windows); err != nil {
+		return nil, fmt.Errorf("invalid windows: %w", err)
+	}
+	return parseCalendar(record.GetString("name"), record.GetString("timezone"), ranges, windows)
+}
+
+// validateCalendarRecord rejects calendar record with invalid time zone, ranges or windows
+func validateCalendarRecord(record *core.Record) error {
+	timezone := record.GetString("timezone")
+	if !isValidTimezone(timezone) {
+		return validation.Errors{"timezone": validation.NewError("validation_invalid_timezone", "invalid time zone")}
+	}
+	var ranges []CalendarRange
+	if err := record.UnmarshalJSONField("ranges", &ranges); err != nil {
+		return validation.Errors{"ranges": validation.NewError("validation_invalid_ranges", err.Error())}
+	}
+	if _, err := parseCalendar("", timezone, ranges, nil); err != nil {
+		return validation.Errors{"ranges": validation.NewError("validation_invalid_ranges", err.Error())}
+	}
+	var windows []CalendarWindow
+	if err := record.UnmarshalJSONField("windows", &windows); err != nil {
+		return validation.Errors{"windows": validation.NewError("validation_invalid_windows", err.Error())}
+	}
+	if _, err := parseCalendar("", timezone, nil, windows); err != nil {
+		return validation.Errors{"windows": validation.NewError("validation_invalid_windows", err.Error())}
+	}
+	return nil
+}
+
+// calendarSkipReason returns why a trigger of the task at t is not executed because of calendars of the task
+// and its project, empty string means the task can run. Exclude calendars of both are applied,
+// include calendars of the task replace those of the project.
+func (sf *ScriptFlow) calendarSkipReason(task *core.Record, t time.Time) (string, error) {
+	excludeIds := task.GetStringSlice("exclude_calendars")
+	includeIds := task.GetStringSlice("include_calendars")
+	project, err := sf.app.FindRecordById(CollectionProjects, task.GetString("project"))
+	if err != nil {
+		return "", fmt.Errorf("failed to find project: %w", err)
+	}
+	excludeIds = append(excludeIds, project.GetStringSlice("exclude_calendars")...)
+	if len(includeIds) == 0 {
+		includeIds = project.GetStringSlice("include_calendars")
+	}
+	if len(excludeIds) == 0 && len(includeIds) == 0 {
+		return "", nil
+	}
+
+	calendars, err := sf.findCalendars(excludeIds)
+	if err != nil {
+		return "", err
+	}
+	for _, calendar := range calendars {
+		if calendar.Contains(t) {
+			sf.app.Logger().Info("task trigger is inside exclude calendar", taskAttrs(task), slog.String("calendar", calendar.Name))
+			return SkipReasonBlackout, nil
+		}
+	}
+
+	if len(includeIds) == 0 {
+		return "", nil
+	}
+	calendars, err = sf.findCalendars(includeIds)
+	if err != nil {
+		return "", err
+	}
+	for _, calendar := range calendars {
+		if calendar.Contains(t) {
+			return "", nil
+		}
+	}
+	return SkipReasonOutsideCalendar, nil
+}
+
+// findCalendars finds and parses calendars by their ids
+func (sf *ScriptFlow) findCalendars(ids []string) ([]*Calendar, error) {
+	records, err := sf.app.FindRecordsByIds(CollectionCalendars, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find calendars: %w", err)
+	}
+	calendars := make([]*Calendar, 0, len(records))
+	for _, record := range records {
+		calendar, err := calendarFromRecord(record)
+		if err != nil {
+			return nil, fmt.Errorf("invalid calendar %s: %w", record.Id, err)
+		}
+		calendars = append(calendars, calendar)
+	}
+	return calendars, nil
+}
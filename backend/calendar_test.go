@@ -0,0 +1,85 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCalendar(t *testing.T) {
+	_, err := parseCalendar("ok", "Europe/Berlin",
+		[]CalendarRange{{Start: "2025-12-20", End: "2026-01-05"}, {Start: "2025-06-01T10:00:00Z", End: "2025-06-01T12:00:00Z"}},
+		[]CalendarWindow{{Days: []string{"Sun", "saturday"}, Start: "02:00", End: "04:00"}},
+	)
+	assert.NoError(t, err)
+
+	invalid := []struct {
+		name     string
+		timezone string
+		ranges   []CalendarRange
+		windows  []CalendarWindow
+	}{
+		{"time zone", "Mars/Olympus", nil, nil},
+		{"range start", "", []CalendarRange{{Start: "20.12.2025", End: "2026-01-05"}}, nil},
+		{"range end before start", "", []CalendarRange{{Start: "2026-01-05", End: "2025-12-20"}}, nil},
+		{"day", "", nil, []CalendarWindow{{Days: []string{"someday"}, Start: "02:00", End: "04:00"}}},
+		{"window start", "", nil, []CalendarWindow{{Start: "2am", End: "04:00"}}},
+		{"window end", "", nil, []CalendarWindow{{Start: "02:00", End: "25:00"}}},
+	}
+	for _, tc := range invalid {
+		_, err := parseCalendar(tc.name, tc.timezone, tc.ranges, tc.windows)
+		assert.Error(t, err, tc.name)
+	}
+}
+
+func TestCalendarContainsRange(t *testing.T) {
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	calendar, err := parseCalendar("freeze", "Europe/Berlin", []CalendarRange{{Start: "2025-12-20", End: "2026-01-05"}}, nil)
+	assert.NoError(t, err)
+
+	assert.False(t, calendar.Contains(time.Date(2025, 12, 19, 23, 59, 0, 0, berlin)))
+	assert.True(t, calendar.Contains(time.Date(2025, 12, 20, 0, 0, 0, 0, berlin)))
+	assert.True(t, calendar.Contains(time.Date(2026, 1, 5, 23, 59, 0, 0, berlin)), "date end includes the whole day")
+	assert.False(t, calendar.Contains(time.Date(2026, 1, 6, 0, 0, 0, 0, berlin)))
+	assert.True(t, calendar.Contains(time.Date(2025, 12, 19, 23, 30, 0, 0, time.UTC)), "dates are in the calendar time zone")
+}
+
+func TestCalendarContainsWindow(t *testing.T) {
+	calendar, err := parseCalendar("maintenance", "UTC", nil, []CalendarWindow{
+		{Days: []string{"sun"}, Start: "02:00", End: "04:00"},
+		{Days: []string{"fri"}, Start: "22:00", End: "01:00"},
+	})
+	assert.NoError(t, err)
+
+	// 2025-06-01 is Sunday
+	assert.True(t, calendar.Contains(time.Date(2025, 6, 1, 2, 0, 0, 0, time.UTC)))
+	assert.True(t, calendar.Contains(time.Date(2025, 6, 1, 3, 59, 0, 0, time.UTC)))
+	assert.False(t, calendar.Contains(time.Date(2025, 6, 1, 4, 0, 0, 0, time.UTC)))
+	assert.False(t, calendar.Contains(time.Date(2025, 6, 2, 3, 0, 0, 0, time.UTC)), "Monday is not a window day")
+
+	// the Friday window ends on Saturday
+	assert.True(t, calendar.Contains(time.Date(2025, 5, 30, 23, 0, 0, 0, time.UTC)))
+	assert.True(t, calendar.Contains(time.Date(2025, 5, 31, 0, 30, 0, 0, time.UTC)))
+	assert.False(t, calendar.Contains(time.Date(2025, 5, 31, 1, 0, 0, 0, time.UTC)))
+	assert.False(t, calendar.Contains(time.Date(2025, 5, 31, 23, 0, 0, 0, time.UTC)), "Saturday window doesn't exist")
+
+	everyDay, err := parseCalendar("night", "UTC", nil, []CalendarWindow{{Start: "00:00", End: "00:00"}})
+	assert.NoError(t, err)
+	assert.True(t, everyDay.Contains(time.Date(2025, 6, 3, 12, 0, 0, 0, time.UTC)), "equal start and end is the whole day")
+}
+
+func TestCalendarWindowKeepsLocalTimeAcrossDST(t *testing.T) {
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	calendar, err := parseCalendar("maintenance", "Europe/Berlin", nil, []CalendarWindow{{Start: "09:00", End: "10:00"}})
+	assert.NoError(t, err)
+
+	for _, date := range []time.Time{
+		time.Date(2025, 3, 29, 0, 0, 0, 0, berlin), // CET
+		time.Date(2025, 3, 31, 0, 0, 0, 0, berlin), // CEST
+	} {
+		year, month, day := date.Date()
+		assert.True(t, calendar.Contains(time.Date(year, month, day, 9, 30, 0, 0, berlin)), date)
+		assert.False(t, calendar.Contains(time.Date(year, month, day, 10, 30, 0, 0, berlin)), date)
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/odemakov/sshrun"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// sudoUserPattern matches valid unix user names
+var sudoUserPattern = regexp.MustCompile(`^[a-z_][a-z0-9_-]*\$?$`)
+
+// CommandOptions describes how the task command is executed on the node
+type CommandOptions struct {
+	Workdir  string
+	Shell    string
+	SudoUser string
+}
+
+// taskCommandOptions returns command options of the task
+func taskCommandOptions(task *core.Record) CommandOptions {
+	return CommandOptions{
+		Workdir:  task.GetString("workdir"),
+		Shell:    strings.TrimSpace(task.GetString("shell")),
+		SudoUser: task.GetString("sudo_user"),
+	}
+}
+
+// IsDefault reports whether the command runs in the home directory under the login shell of the SSH user
+func (o CommandOptions) IsDefault() bool {
+	return o.Workdir == "" && o.Shell == "" && o.SudoUser == ""
+}
+
+// interpreter returns the shell executable, "sh" is used when only sudo user is set
+func (o CommandOptions) interpreter() string {
+	if o.Shell == "" {
+		return "sh"
+	}
+	return strings.Fields(o.Shell)[0]
+}
+
+// envFromStdin makes the remote shell export the env written to stdin of the command
+const envFromStdin = `eval "$(cat)"; `
+
+// wrapCommand builds remote command which runs command under the shell, in the working directory and as
+// the sudo user of the options, and the input to write to its stdin. The remote command reads the env from
+// the input, as the command line can be seen by any user of the node, e.g. with ps.
+func wrapCommand(command string, env map[string]string, opts CommandOptions) (string, string) {
+	input := envExportCommand(env)
+	readEnv := ""
+	if input != "" {
+		readEnv = envFromStdin
+	}
+	if opts.IsDefault() {
+		return readEnv + command, input
+	}
+
+	var b strings.Builder
+	if opts.Workdir != "" {
+		b.WriteString("cd " + shellQuote(opts.Workdir) + " || exit 1; ")
+	}
+	// only the working directory is set, the command keeps running under the login shell
+	if opts.Shell == "" && opts.SudoUser == "" {
+		b.WriteString(readEnv + command)
+		return b.String(), input
+	}
+
+	shell := opts.Shell
+	if shell == "" {
+		shell = opts.interpreter()
+	}
+	shellCommand := shell + " -c " + shellQuote(command)
+
+	if opts.SudoUser == "" {
+		b.WriteString(readEnv)
+		b.WriteString(shellCommand)
+		return b.String(), input
+	}
+
+	// sudo resets environment, so variables are read by the shell started by sudo, stdin is passed through
+	b.WriteString("sudo -n -u " + shellQuote(opts.SudoUser) + " -- ")
+	if readEnv != "" {
+		b.WriteString("sh -c " + shellQuote(readEnv+shellCommand))
+	} else {
+		b.WriteString(shellCommand)
+	}
+	return b.String(), input
+}
+
+// preflightCommand returns remote command checking that the working directory, the interpreter and
+// the sudo user of the options are usable. On failure it prints the reason and exits with non-zero code.
+func preflightCommand(opts CommandOptions) string {
+	var checks []string
+	if opts.SudoUser != "" {
+		checks = append(checks, fmt.Sprintf(
+			"sudo -n -u %s -- true >/dev/null 2>&1 || { echo %s; exit 1; }",
+			shellQuote(opts.SudoUser),
+			shellQuote(fmt.Sprintf("cannot run commands as user %s, check sudo configuration", opts.SudoUser)),
+		))
+	}
+	if opts.Workdir != "" {
+		checks = append(checks, fmt.Sprintf(
+			"cd %s >/dev/null 2>&1 || { echo %s; exit 1; }",
+			shellQuote(opts.Workdir),
+			shellQuote(fmt.Sprintf("working directory %s does not exist or is not accessible", opts.Workdir)),
+		))
+	}
+	if opts.Shell != "" || opts.SudoUser != "" {
+		lookup := "command -v " + shellQuote(opts.interpreter())
+		if opts.SudoUser != "" {
+			lookup = "sudo -n -u " + shellQuote(opts.SudoUser) + " -- sh -c " + shellQuote(lookup)
+		}
+		checks = append(checks, fmt.Sprintf(
+			"%s >/dev/null 2>&1 || { echo %s; exit 1; }",
+			lookup,
+			shellQuote(fmt.Sprintf("interpreter %s not found", opts.interpreter())),
+		))
+	}
+	return strings.Join(checks, "; ")
+}
+
+// validateCommandOptions checks options which can be validated without the node
+func validateCommandOptions(opts CommandOptions) error {
+	if opts.SudoUser != "" && !sudoUserPattern.MatchString(opts.SudoUser) {
+		return fmt.Errorf("invalid sudo user: %q", opts.SudoUser)
+	}
+	return nil
+}
+
+// checkCommandOptions verifies on the node that the task command can be started with the options.
+// Returns ScriptFlowError with the reason if it can't.
+func (sf *ScriptFlow) checkCommandOptions(ctx context.Context, sshCfg *sshrun.SSHConfig, opts CommandOptions) error {
+	if opts.IsDefault() {
+		return nil
+	}
+	if err := validateCommandOptions(opts); err != nil {
+		return &ScriptFlowError{err.Error()}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	var output []string
+	_, err := sf.sshPool.RunContext(
+		ctx,
+		sshCfg,
+		preflightCommand(opts),
+		func(out string) { output = append(output, strings.TrimSpace(out)) },
+		func(string) {},
+	)
+	if _, ok := err.(*sshrun.CommandError); ok {
+		return &ScriptFlowError{strings.Join(output, "; ")}
+	}
+	return err
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWrapCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		command  string
+		env      map[string]string
+		opts     CommandOptions
+		expected string
+		input    string
+	}{
+		{
+			name:     "default options",
+			command:  "echo hi",
+			expected: "echo hi",
+		},
+		{
+			name:     "default options with env",
+			command:  "echo $A",
+			env:      map[string]string{"A": "1"},
+			expected: `eval "$(cat)"; echo $A`,
+			input:    "export A='1'; ",
+		},
+		{
+			name:     "workdir",
+			command:  "ls",
+			opts:     CommandOptions{Workdir: "/srv/app"},
+			expected: "cd '/srv/app' || exit 1; ls",
+		},
+		{
+			name:     "workdir with env",
+			command:  "[[ -n $A ]] && ls",
+			env:      map[string]string{"A": "1"},
+			opts:     CommandOptions{Workdir: "/srv/app"},
+			expected: `cd '/srv/app' || exit 1; eval "$(cat)"; [[ -n $A ]] && ls`,
+			input:    "export A='1'; ",
+		},
+		{
+			name:     "shell with options",
+			command:  "false | true",
+			opts:     CommandOptions{Shell: "bash -euo pipefail"},
+			expected: "bash -euo pipefail -c 'false | true'",
+		},
+		{
+			name:     "shell with env",
+			command:  "import os; print(os.environ['A'])",
+			env:      map[string]string{"A": "1"},
+			opts:     CommandOptions{Shell: "python3"},
+			expected: `eval "$(cat)"; python3 -c 'import os; print(os.environ['\''A'\''])'`,
+			input:    "export A='1'; ",
+		},
+		{
+			name:     "sudo user",
+			command:  "whoami",
+			opts:     CommandOptions{SudoUser: "deployer"},
+			expected: "sudo -n -u 'deployer' -- sh -c 'whoami'",
+		},
+		{
+			name:     "all options with env",
+			command:  "echo $A $B",
+			env:      map[string]string{"B": "2", "A": "1"},
+			opts:     CommandOptions{Workdir: "/tmp", Shell: "bash", SudoUser: "deployer"},
+			expected: `cd '/tmp' || exit 1; sudo -n -u 'deployer' -- sh -c 'eval "$(cat)"; bash -c '\''echo $A $B'\'''`,
+			input:    "export A='1' B='2'; ",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			command, input := wrapCommand(tt.command, tt.env, tt.opts)
+			assert.Equal(t, tt.expected, command)
+			assert.Equal(t, tt.input, input)
+		})
+	}
+}
+
+func TestWrapCommandKeepsEnvOffCommandLine(t *testing.T) {
+	secret := "s3cr3t-value"
+	env := map[string]string{"API_TOKEN": secret}
+	for _, opts := range []CommandOptions{
+		{},
+		{Workdir: "/srv/app"},
+		{Shell: "bash"},
+		{SudoUser: "deployer"},
+		{Workdir: "/srv/app", Shell: "bash", SudoUser: "deployer"},
+	} {
+		command, input := wrapCommand("deploy.sh", env, opts)
+		assert.NotContains(t, command, secret, "command line with %+v", opts)
+		assert.NotContains(t, wrapCommandWithPidFile(command, "run"), secret)
+		assert.Contains(t, input, secret, "env is passed on stdin")
+	}
+}
+
+func TestPreflightCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     CommandOptions
+		expected string
+	}{
+		{
+			name:     "no options",
+			expected: "",
+		},
+		{
+			name:     "workdir",
+			opts:     CommandOptions{Workdir: "/srv/app"},
+			expected: "cd '/srv/app' >/dev/null 2>&1 || { echo 'working directory /srv/app does not exist or is not accessible'; exit 1; }",
+		},
+		{
+			name:     "shell",
+			opts:     CommandOptions{Shell: "bash -eu"},
+			expected: "command -v 'bash' >/dev/null 2>&1 || { echo 'interpreter bash not found'; exit 1; }",
+		},
+		{
+			name: "sudo user",
+			opts: CommandOptions{SudoUser: "deployer"},
+			expected: "sudo -n -u 'deployer' -- true >/dev/null 2>&1 || { echo 'cannot run commands as user deployer, check sudo configuration'; exit 1; }; " +
+				`sudo -n -u 'deployer' -- sh -c 'command -v '\''sh'\''' >/dev/null 2>&1 || { echo 'interpreter sh not found'; exit 1; }`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, preflightCommand(tt.opts))
+		})
+	}
+}
+
+func TestValidateCommandOptions(t *testing.T) {
+	assert.NoError(t, validateCommandOptions(CommandOptions{}))
+	assert.NoError(t, validateCommandOptions(CommandOptions{SudoUser: "deployer"}))
+	assert.NoError(t, validateCommandOptions(CommandOptions{SudoUser: "_svc-app"}))
+	assert.Error(t, validateCommandOptions(CommandOptions{SudoUser: "root; rm -rf /"}))
+	assert.Error(t, validateCommandOptions(CommandOptions{SudoUser: "Deployer"}))
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// taskConcurrencyPolicy returns concurrency policy of the task, skip is the default
+func taskConcurrencyPolicy(task *core.Record) string {
+	policy := task.GetString("concurrency_policy")
+	if policy == "" {
+		return ConcurrencyPolicySkip
+	}
+	return policy
+}
+
+// validateConcurrencyPolicy checks concurrency policy and max parallel runs of the task
+func validateConcurrencyPolicy(policy string, maxParallel int) error {
+	if policy != "" && !slices.Contains(ConcurrencyPolicies, policy) {
+		return fmt.Errorf("invalid concurrency policy: %q", policy)
+	}
+	if maxParallel < 0 {
+		return fmt.Errorf("invalid max parallel runs: %d", maxParallel)
+	}
+	return nil
+}
+
+// acquireTaskSlot takes a run slot of the task according to its concurrency policy:
+//   - skip: the trigger is skipped while the task is running
+//   - queue: the trigger waits until the running instance finishes
+//   - replace: running instances are killed and the trigger waits until they finish
+//   - allow: up to max_parallel instances run at once, 0 means no limit
+//
+// Returns false if the trigger was skipped, the skipped run is recorded then.
+// On success the slot must be released with unlockTask.
+func (sf *ScriptFlow) acquireTaskSlot(task *core.Record, trigger RunTrigger) bool {
+	var reason string
+	switch taskConcurrencyPolicy(task) {
+	case ConcurrencyPolicyQueue:
+		if sf.waitLockTask(task.Id, 1, TaskQueueMaxSize) {
+			return true
+		}
+		reason = SkipReasonQueueFull
+	case ConcurrencyPolicyReplace:
+		if sf.tryLockTask(task.Id, 1) {
+			return true
+		}
+		sf.killTaskRuns(task)
+		if sf.waitLockTask(task.Id, 1, TaskQueueMaxSize) {
+			return true
+		}
+		reason = SkipReasonQueueFull
+	case ConcurrencyPolicyAllow:
+		if sf.tryLockTask(task.Id, task.GetInt("max_parallel")) {
+			return true
+		}
+		reason = SkipReasonMaxParallel
+	default:
+		if sf.tryLockTask(task.Id, 1) {
+			return true
+		}
+		reason = SkipReasonTaskRunning
+	}
+
+	// waiting for a slot was interrupted by shutdown, nothing to record
+	if sf.ctx.Err() != nil {
+		return false
+	}
+	sf.skipTrigger(task, trigger, reason)
+	return false
+}
+
+// killTaskRuns kills all started and queued runs of the task, as well as runs waiting for a retry
+func (sf *ScriptFlow) killTaskRuns(task *core.Record) {
+	runs, err := sf.app.FindAllRecords(CollectionRuns, dbx.HashExp{"task": task.Id}, dbx.Or(
+		dbx.HashExp{"status": []any{RunStatusStarted, RunStatusQueued}},
+		dbx.HashExp{"retrying": true},
+	))
+	if err != nil {
+		sf.app.Logger().Error("failed to find running runs", taskAttrs(task), slog.Any("error", err))
+		return
+	}
+	for _, run := range runs {
+		// queued runs of triggers waiting for the task slot are not active, they run in turn
+		if !sf.isActiveRun(run.Id) {
+			continue
+		}
+		if err := sf.KillRun(run.Id); err != nil {
+			sf.app.Logger().Warn("failed to kill run", taskAttrs(task), slog.String("runId", run.Id), slog.Any("error", err))
+		}
+	}
+}
+
+// wouldSkipTrigger reports whether a trigger of the task would be skipped right now
+func (sf *ScriptFlow) wouldSkipTrigger(task *core.Record) bool {
+	running := sf.runningTaskCount(task.Id)
+	switch taskConcurrencyPolicy(task) {
+	case ConcurrencyPolicyQueue, ConcurrencyPolicyReplace:
+		return false
+	case ConcurrencyPolicyAllow:
+		maxParallel := task.GetInt("max_parallel")
+		return maxParallel > 0 && running >= maxParallel
+	default:
+		return running > 0
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTaskSlotsScriptFlow(ctx context.Context) *ScriptFlow {
+	sf := &ScriptFlow{
+		ctx:          ctx,
+		runningTasks: make(map[string]int),
+		taskWaiters:  make(map[string][]chan struct{}),
+	}
+	return sf
+}
+
+// waitingTriggers returns number of triggers waiting for a run slot of the task
+func waitingTriggers(sf *ScriptFlow, taskId string) int {
+	sf.taskRunMutex.Lock()
+	defer sf.taskRunMutex.Unlock()
+	return len(sf.taskWaiters[taskId])
+}
+
+func TestTryLockTask(t *testing.T) {
+	sf := newTaskSlotsScriptFlow(context.Background())
+
+	assert.True(t, sf.tryLockTask("a", 1))
+	assert.False(t, sf.tryLockTask("a", 1), "single slot is taken")
+	assert.True(t, sf.tryLockTask("b", 1), "slots are per task")
+
+	assert.True(t, sf.tryLockTask("a", 2), "second slot is free")
+	assert.False(t, sf.tryLockTask("a", 2))
+	assert.True(t, sf.tryLockTask("a", 0), "zero limit means no limit")
+	assert.Equal(t, 3, sf.runningTaskCount("a"))
+
+	sf.unlockTask("a")
+	sf.unlockTask("a")
+	sf.unlockTask("a")
+	assert.Equal(t, 0, sf.runningTaskCount("a"))
+	assert.True(t, sf.tryLockTask("a", 1))
+}
+
+func TestWaitLockTask(t *testing.T) {
+	sf := newTaskSlotsScriptFlow(context.Background())
+	assert.True(t, sf.waitLockTask("a", 1, 1), "free slot is taken without waiting")
+
+	acquired := make(chan bool)
+	go func() { acquired <- sf.waitLockTask("a", 1, 1) }()
+
+	// wait until the trigger is queued
+	assert.Eventually(t, func() bool { return waitingTriggers(sf, "a") == 1 }, time.Second, time.Millisecond)
+	assert.False(t, sf.waitLockTask("a", 1, 1), "queue is full")
+
+	sf.unlockTask("a")
+	select {
+	case ok := <-acquired:
+		assert.True(t, ok)
+	case <-time.After(time.Second):
+		t.Fatal("queued trigger did not get the slot")
+	}
+	assert.Equal(t, 1, sf.runningTaskCount("a"))
+}
+
+func TestWaitLockTaskShutdown(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sf := newTaskSlotsScriptFlow(ctx)
+	assert.True(t, sf.tryLockTask("a", 1))
+
+	acquired := make(chan bool)
+	go func() { acquired <- sf.waitLockTask("a", 1, 1) }()
+	assert.Eventually(t, func() bool { return waitingTriggers(sf, "a") == 1 }, time.Second, time.Millisecond)
+
+	cancel()
+
+	select {
+	case ok := <-acquired:
+		assert.False(t, ok)
+	case <-time.After(time.Second):
+		t.Fatal("queued trigger was not released on shutdown")
+	}
+	assert.Equal(t, 1, sf.runningTaskCount("a"))
+	assert.Equal(t, 0, waitingTriggers(sf, "a"))
+}
+
+func TestWaitLockTaskInArrivalOrder(t *testing.T) {
+	sf := newTaskSlotsScriptFlow(context.Background())
+	assert.True(t, sf.tryLockTask("a", 1))
+
+	var mutex sync.Mutex
+	var order []int
+	var wg sync.WaitGroup
+	for i := range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if sf.waitLockTask("a", 1, 10) {
+				mutex.Lock()
+				order = append(order, i)
+				mutex.Unlock()
+				sf.unlockTask("a")
+			}
+		}()
+		// queue the triggers one after another
+		assert.Eventually(t, func() bool { return waitingTriggers(sf, "a") == i+1 }, time.Second, time.Millisecond)
+	}
+	sf.unlockTask("a")
+	wg.Wait()
+	assert.Equal(t, []int{0, 1, 2, 3, 4}, order)
+	assert.Equal(t, 0, sf.runningTaskCount("a"))
+}
+
+func TestValidateConcurrencyPolicy(t *testing.T) {
+	assert.NoError(t, validateConcurrencyPolicy("", 0))
+	for _, policy := range ConcurrencyPolicies {
+		assert.NoError(t, validateConcurrencyPolicy(policy, 2), policy)
+	}
+	assert.Error(t, validateConcurrencyPolicy("parallel", 0))
+	assert.Error(t, validateConcurrencyPolicy(ConcurrencyPolicyAllow, -1))
+}
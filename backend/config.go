@@ -8,44 +8,105 @@ import (
 	"regexp"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/pocketbase/dbx"
 	"gopkg.in/yaml.v2"
 )
 
 type Config struct {
+	Calendars     []ConfigCalendar      `yaml:"calendars"`
 	Projects      []ConfigProject       `yaml:"projects"`
 	Nodes         []ConfigNode          `yaml:"nodes"`
 	Tasks         []ConfigTask          `yaml:"tasks"`
+	Pipelines     []ConfigPipeline      `yaml:"pipelines"`
 	Channels      []ConfigChannel       `yaml:"channels"`
 	Subscriptions []ConfigSubscriptions `yaml:"subscriptions"`
 }
 
+type ConfigCalendar struct {
+	Id       string           `yaml:"id"`
+	Name     string           `yaml:"name"`
+	Timezone string           `yaml:"timezone"`
+	Ranges   []CalendarRange  `yaml:"ranges"`
+	Windows  []CalendarWindow `yaml:"windows"`
+}
+
 type ConfigProject struct {
-	Id     string              `yaml:"id"`
-	Name   string              `yaml:"name"`
-	Config ConfigProjectConfig `yaml:"config"`
+	Id               string              `yaml:"id"`
+	Name             string              `yaml:"name"`
+	Config           ConfigProjectConfig `yaml:"config"`
+	Env              map[string]string   `yaml:"env"`
+	ExcludeCalendars []string            `yaml:"exclude_calendars"`
+	IncludeCalendars []string            `yaml:"include_calendars"`
 }
 
 type ConfigProjectConfig struct {
-	LogsMaxDays int `yaml:"logs_max_days" json:"logsMaxDays,omitempty"`
+	LogsMaxDays          int    `yaml:"logs_max_days" json:"logsMaxDays,omitempty"`
+	LogsUncompressedDays int    `yaml:"logs_uncompressed_days" json:"logsUncompressedDays,omitempty"`
+	MaxOutputBytes       int64  `yaml:"max_output_bytes" json:"maxOutputBytes,omitempty"`
+	MaxOutputLines       int    `yaml:"max_output_lines" json:"maxOutputLines,omitempty"`
+	Timezone             string `yaml:"timezone" json:"timezone,omitempty"`
 }
 
 type ConfigNode struct {
-	Id         string `yaml:"id"`
-	Host       string `yaml:"host"`
-	Username   string `yaml:"username"`
-	PrivateKey string `yaml:"private_key"`
+	Id                string            `yaml:"id"`
+	Host              string            `yaml:"host"`
+	Username          string            `yaml:"username"`
+	PrivateKey        string            `yaml:"private_key"`
+	Env               map[string]string `yaml:"env"`
+	MaxConcurrentRuns int               `yaml:"max_concurrent_runs"`
+	Labels            []string          `yaml:"labels"`
 }
 
 type ConfigTask struct {
-	Id       string `yaml:"id"`
-	Name     string `yaml:"name"`
-	Command  string `yaml:"command"`
-	Schedule string `yaml:"schedule"`
-	Node     string `yaml:"node"`
-	Project  string `yaml:"project"`
-	Active   bool   `yaml:"active"`
+	Id                string            `yaml:"id"`
+	Name              string            `yaml:"name"`
+	Command           string            `yaml:"command"`
+	Schedule          string            `yaml:"schedule"`
+	Node              string            `yaml:"node"`
+	Project           string            `yaml:"project"`
+	Active            bool              `yaml:"active"`
+	Timeout           string            `yaml:"timeout"`
+	TimeoutGrace      string            `yaml:"timeout_grace"`
+	Retries           int               `yaml:"retries"`
+	RetryDelay        string            `yaml:"retry_delay"`
+	RetryBackoff      float64           `yaml:"retry_backoff"`
+	Env               map[string]string `yaml:"env"`
+	Workdir           string            `yaml:"workdir"`
+	Shell             string            `yaml:"shell"`
+	SudoUser          string            `yaml:"sudo_user"`
+	ConcurrencyPolicy string            `yaml:"concurrency_policy"`
+	MaxParallel       int               `yaml:"max_parallel"`
+	NodeSelector      string            `yaml:"node_selector"`
+	NodeMode          string            `yaml:"node_mode"`
+	NodeCount         int               `yaml:"node_count"`
+	FallbackNodes     []string          `yaml:"fallback_nodes"`
+	Catchup           string            `yaml:"catchup"`
+	Timezone          string            `yaml:"timezone"`
+	ExcludeCalendars  []string          `yaml:"exclude_calendars"`
+	IncludeCalendars  []string          `yaml:"include_calendars"`
+	WebhookToken      string            `yaml:"webhook_token"`
+	WebhookSigned     bool              `yaml:"webhook_signed"`
+	WebhookEnv        map[string]string `yaml:"webhook_env"`
+	Params            []TaskParam       `yaml:"params"`
+	OnRunOf           []string          `yaml:"on_run_of"`
+	OnRunStatuses     []string          `yaml:"on_run_statuses"`
+	OnRemoteFile      string            `yaml:"on_remote_file"`
+	MaxOutputBytes    int64             `yaml:"max_output_bytes"`
+	MaxOutputLines    int               `yaml:"max_output_lines"`
+	OutputLimitKill   bool              `yaml:"output_limit_kill"`
+}
+
+type ConfigPipeline struct {
+	Id       string         `yaml:"id"`
+	Name     string         `yaml:"name"`
+	Project  string         `yaml:"project"`
+	Tasks    []string       `yaml:"tasks"`
+	Steps    []PipelineStep `yaml:"steps"`
+	Schedule string         `yaml:"schedule"`
+	Timezone string         `yaml:"timezone"`
+	Active   bool           `yaml:"active"`
 }
 
 type ConfigChannel struct {
@@ -65,6 +126,7 @@ type ConfigSubscriptions struct {
 	Id        string   `yaml:"id"`
 	Name      string   `yaml:"name"`
 	Task      string   `yaml:"task"`
+	Pipeline  string   `yaml:"pipeline"`
 	Channel   string   `yaml:"channel"`
 	Events    []string `yaml:"events"`
 	Threshold int      `yaml:"threshold"`
@@ -94,14 +156,58 @@ func (sf *ScriptFlow) UpdateFromConfig() error {
 	sf.configMutex.RLock()
 	defer sf.configMutex.RUnlock()
 
+	sf.updateFromConfigCalendars()
 	sf.updateFromConfigProject()
 	sf.updateFromConfigNode()
 	sf.updateFromConfigTasks()
+	sf.updateFromConfigPipelines()
 	sf.updateFromConfigChannels()
 	sf.updateFromConfigSubscriptions()
 	return nil
 }
 
+func (sf *ScriptFlow) updateFromConfigCalendars() {
+	// insert or update calendars
+	for _, calendar := range sf.config.Calendars {
+		// skip empty name
+		if calendar.Name == "" {
+			sf.app.Logger().Warn("[config] calendar name is empty", slog.Any("calendar", calendar))
+			continue
+		}
+		if calendar.Id == "" {
+			calendar.Id = generateIdFromName(calendar.Name)
+		}
+		if !isValidUUID(calendar.Id) {
+			sf.app.Logger().Warn("[config] calendar id is not a valid UUID", slog.Any("calendar", calendar))
+			continue
+		}
+		if _, err := parseCalendar(calendar.Name, calendar.Timezone, calendar.Ranges, calendar.Windows); err != nil {
+			sf.app.Logger().Warn("[config] calendar is invalid", slog.Any("error", err), slog.Any("calendar", calendar))
+			continue
+		}
+		rangesJSON, err := json.Marshal(calendar.Ranges)
+		if err != nil {
+			sf.app.Logger().Error("[config] failed to marshal calendar ranges to JSON", slog.Any("error", err))
+			continue
+		}
+		windowsJSON, err := json.Marshal(calendar.Windows)
+		if err != nil {
+			sf.app.Logger().Error("[config] failed to marshal calendar windows to JSON", slog.Any("error", err))
+			continue
+		}
+		err = sf.insertOrUpdate(CollectionCalendars, dbx.Params{
+			"id":       calendar.Id,
+			"name":     calendar.Name,
+			"timezone": calendar.Timezone,
+			"ranges":   string(rangesJSON),
+			"windows":  string(windowsJSON),
+		}, "name", "timezone", "ranges", "windows")
+		if err != nil {
+			sf.app.Logger().Error("[config] failed to insert or update calendar", slog.Any("error", err))
+		}
+	}
+}
+
 func (sf *ScriptFlow) updateFromConfigProject() {
 	// insert or update projects
 	for _, project := range sf.config.Projects {
@@ -117,17 +223,39 @@ func (sf *ScriptFlow) updateFromConfigProject() {
 			sf.app.Logger().Warn("[config] project id is not a valid UUID", slog.Any("project", project))
 			continue
 		}
+		if !isValidTimezone(project.Config.Timezone) {
+			sf.app.Logger().Warn("[config] project timezone is not valid", slog.Any("project", project))
+			continue
+		}
 		// format config as JSON string
 		configJSON, err := json.Marshal(project.Config)
 		if err != nil {
 			sf.app.Logger().Error("[config] failed to marshal project config to JSON", slog.Any("error", err))
 			continue
 		}
+		envJSON, err := json.Marshal(project.Env)
+		if err != nil {
+			sf.app.Logger().Error("[config] failed to marshal project env to JSON", slog.Any("error", err))
+			continue
+		}
+		excludeCalendarsJSON, err := json.Marshal(project.ExcludeCalendars)
+		if err != nil {
+			sf.app.Logger().Error("[config] failed to marshal project exclude calendars to JSON", slog.Any("error", err))
+			continue
+		}
+		includeCalendarsJSON, err := json.Marshal(project.IncludeCalendars)
+		if err != nil {
+			sf.app.Logger().Error("[config] failed to marshal project include calendars to JSON", slog.Any("error", err))
+			continue
+		}
 		err = sf.insertOrUpdate(CollectionProjects, dbx.Params{
-			"id":     project.Id,
-			"name":   project.Name,
-			"config": string(configJSON),
-		}, "name", "config")
+			"id":                project.Id,
+			"name":              project.Name,
+			"config":            string(configJSON),
+			"env":               string(envJSON),
+			"exclude_calendars": string(excludeCalendarsJSON),
+			"include_calendars": string(includeCalendarsJSON),
+		}, "name", "config", "env", "exclude_calendars", "include_calendars")
 		if err != nil {
 			sf.app.Logger().Error("[config] failed to insert or update project", slog.Any("error", err))
 		}
@@ -150,12 +278,33 @@ func (sf *ScriptFlow) updateFromConfigNode() {
 			sf.app.Logger().Warn("[config] node id is not a valid UUID", slog.Any("node", node))
 			continue
 		}
-		err := sf.insertOrUpdate(CollectionNodes, dbx.Params{
-			"id":          node.Id,
-			"host":        node.Host,
-			"username":    node.Username,
-			"private_key": node.PrivateKey,
-		}, "host", "username", "private_key")
+		if node.MaxConcurrentRuns < 0 {
+			sf.app.Logger().Warn("[config] node max_concurrent_runs is negative", slog.Any("node", node))
+			continue
+		}
+		if err := validateNodeLabels(node.Labels); err != nil {
+			sf.app.Logger().Warn("[config] node labels are invalid", slog.Any("error", err), slog.Any("node", node))
+			continue
+		}
+		envJSON, err := json.Marshal(node.Env)
+		if err != nil {
+			sf.app.Logger().Error("[config] failed to marshal node env to JSON", slog.Any("error", err))
+			continue
+		}
+		labelsJSON, err := json.Marshal(node.Labels)
+		if err != nil {
+			sf.app.Logger().Error("[config] failed to marshal node labels to JSON", slog.Any("error", err))
+			continue
+		}
+		err = sf.insertOrUpdate(CollectionNodes, dbx.Params{
+			"id":                  node.Id,
+			"host":                node.Host,
+			"username":            node.Username,
+			"private_key":         node.PrivateKey,
+			"env":                 string(envJSON),
+			"max_concurrent_runs": node.MaxConcurrentRuns,
+			"labels":              string(labelsJSON),
+		}, "host", "username", "private_key", "env", "max_concurrent_runs", "labels")
 		if err != nil {
 			sf.app.Logger().Error("[config] failed to insert or update node", slog.Any("error", err))
 		}
@@ -165,9 +314,9 @@ func (sf *ScriptFlow) updateFromConfigNode() {
 func (sf *ScriptFlow) updateFromConfigTasks() {
 	// insert or update tasks
 	for _, task := range sf.config.Tasks {
-		// skip empty name, command, schedule, node, project
-		if task.Name == "" || task.Command == "" || task.Schedule == "" || task.Node == "" || task.Project == "" {
-			sf.app.Logger().Warn("[config] task id, name, command, schedule, node or project is empty", slog.Any("task", task))
+		// skip empty name, command, node and node selector, project. Tasks without schedule run only by their triggers
+		if task.Name == "" || task.Command == "" || (task.Node == "" && task.NodeSelector == "") || task.Project == "" {
+			sf.app.Logger().Warn("[config] task id, name, command, node or project is empty", slog.Any("task", task))
 			continue
 		}
 		if task.Id == "" {
@@ -177,21 +326,212 @@ func (sf *ScriptFlow) updateFromConfigTasks() {
 			sf.app.Logger().Warn("[config] task id is not a valid UUID", slog.Any("task", task))
 			continue
 		}
-		err := sf.insertOrUpdate(CollectionTasks, dbx.Params{
-			"id":       task.Id,
-			"name":     task.Name,
-			"command":  task.Command,
-			"schedule": task.Schedule,
-			"node":     task.Node,
-			"project":  task.Project,
-			"active":   task.Active,
-		}, "name", "command", "schedule", "node", "project", "active")
+		if !isValidDuration(task.Timeout) || !isValidDuration(task.TimeoutGrace) || !isValidDuration(task.RetryDelay) {
+			sf.app.Logger().Warn("[config] task timeout, timeout_grace or retry_delay is not a valid duration", slog.Any("task", task))
+			continue
+		}
+		if task.MaxOutputBytes < 0 || task.MaxOutputLines < 0 {
+			sf.app.Logger().Warn("[config] task max_output_bytes or max_output_lines is negative", slog.Any("task", task))
+			continue
+		}
+		if err := validateCommandOptions(CommandOptions{Workdir: task.Workdir, Shell: task.Shell, SudoUser: task.SudoUser}); err != nil {
+			sf.app.Logger().Warn("[config] task command options are invalid", slog.Any("error", err), slog.Any("task", task))
+			continue
+		}
+		if err := validateConcurrencyPolicy(task.ConcurrencyPolicy, task.MaxParallel); err != nil {
+			sf.app.Logger().Warn("[config] task concurrency policy is invalid", slog.Any("error", err), slog.Any("task", task))
+			continue
+		}
+		if !isValidTimezone(task.Timezone) {
+			sf.app.Logger().Warn("[config] task timezone is not valid", slog.Any("task", task))
+			continue
+		}
+		if task.Schedule != "" {
+			if err := validateSchedule(task.Schedule, task.Id, task.Timezone); err != nil {
+				sf.app.Logger().Warn("[config] task schedule is invalid", slog.Any("error", err), slog.Any("task", task))
+				continue
+			}
+		}
+		if err := validateCatchupPolicy(task.Catchup); err != nil {
+			sf.app.Logger().Warn("[config] task catchup policy is invalid", slog.Any("error", err), slog.Any("task", task))
+			continue
+		}
+		if err := validateNodeSelector(task.NodeSelector, task.NodeMode, task.NodeCount); err != nil {
+			sf.app.Logger().Warn("[config] task node selector is invalid", slog.Any("error", err), slog.Any("task", task))
+			continue
+		}
+		if err := validateWebhookEnv(task.WebhookEnv); err != nil {
+			sf.app.Logger().Warn("[config] task webhook env is invalid", slog.Any("error", err), slog.Any("task", task))
+			continue
+		}
+		if err := validateTaskParams(task.Params); err != nil {
+			sf.app.Logger().Warn("[config] task params are invalid", slog.Any("error", err), slog.Any("task", task))
+			continue
+		}
+		if err := validateTaskTriggers(task.Id, task.Node, task.OnRunOf, task.OnRunStatuses, task.OnRemoteFile); err != nil {
+			sf.app.Logger().Warn("[config] task triggers are invalid", slog.Any("error", err), slog.Any("task", task))
+			continue
+		}
+		if err := sf.findTriggerCycle(task.Id, task.OnRunOf); err != nil {
+			sf.app.Logger().Warn("[config] task triggers are invalid", slog.Any("error", err), slog.Any("task", task))
+			continue
+		}
+		envJSON, err := json.Marshal(task.Env)
+		if err != nil {
+			sf.app.Logger().Error("[config] failed to marshal task env to JSON", slog.Any("error", err))
+			continue
+		}
+		webhookEnvJSON, err := json.Marshal(task.WebhookEnv)
+		if err != nil {
+			sf.app.Logger().Error("[config] failed to marshal task webhook env to JSON", slog.Any("error", err))
+			continue
+		}
+		paramsJSON, err := json.Marshal(task.Params)
+		if err != nil {
+			sf.app.Logger().Error("[config] failed to marshal task params to JSON", slog.Any("error", err))
+			continue
+		}
+		onRunOfJSON, err := json.Marshal(task.OnRunOf)
+		if err != nil {
+			sf.app.Logger().Error("[config] failed to marshal task on_run_of to JSON", slog.Any("error", err))
+			continue
+		}
+		onRunStatusesJSON, err := json.Marshal(task.OnRunStatuses)
+		if err != nil {
+			sf.app.Logger().Error("[config] failed to marshal task on_run_statuses to JSON", slog.Any("error", err))
+			continue
+		}
+		fallbackNodesJSON, err := json.Marshal(task.FallbackNodes)
+		if err != nil {
+			sf.app.Logger().Error("[config] failed to marshal task fallback nodes to JSON", slog.Any("error", err))
+			continue
+		}
+		excludeCalendarsJSON, err := json.Marshal(task.ExcludeCalendars)
+		if err != nil {
+			sf.app.Logger().Error("[config] failed to marshal task exclude calendars to JSON", slog.Any("error", err))
+			continue
+		}
+		includeCalendarsJSON, err := json.Marshal(task.IncludeCalendars)
+		if err != nil {
+			sf.app.Logger().Error("[config] failed to marshal task include calendars to JSON", slog.Any("error", err))
+			continue
+		}
+		updateColumns := []string{"name", "command", "schedule", "node", "project", "active", "timeout", "timeout_grace",
+			"retries", "retry_delay", "retry_backoff", "env", "workdir", "shell", "sudo_user",
+			"concurrency_policy", "max_parallel", "node_selector", "node_mode", "node_count", "fallback_nodes", "catchup", "timezone",
+			"exclude_calendars", "include_calendars", "webhook_env", "params",
+			"on_run_of", "on_run_statuses", "on_remote_file", "max_output_bytes", "max_output_lines", "output_limit_kill"}
+		// without a token in the config the webhook is managed by the API, the token issued there is kept
+		if task.WebhookToken != "" {
+			updateColumns = append(updateColumns, "webhook_token", "webhook_signed")
+		}
+		err = sf.insertOrUpdate(CollectionTasks, dbx.Params{
+			"id":                 task.Id,
+			"name":               task.Name,
+			"command":            task.Command,
+			"schedule":           task.Schedule,
+			"node":               task.Node,
+			"project":            task.Project,
+			"active":             task.Active,
+			"timeout":            task.Timeout,
+			"timeout_grace":      task.TimeoutGrace,
+			"retries":            task.Retries,
+			"retry_delay":        task.RetryDelay,
+			"retry_backoff":      task.RetryBackoff,
+			"env":                string(envJSON),
+			"workdir":            task.Workdir,
+			"shell":              task.Shell,
+			"sudo_user":          task.SudoUser,
+			"concurrency_policy": task.ConcurrencyPolicy,
+			"max_parallel":       task.MaxParallel,
+			"node_selector":      task.NodeSelector,
+			"node_mode":          task.NodeMode,
+			"node_count":         task.NodeCount,
+			"fallback_nodes":     string(fallbackNodesJSON),
+			"catchup":            task.Catchup,
+			"timezone":           task.Timezone,
+			"exclude_calendars":  string(excludeCalendarsJSON),
+			"include_calendars":  string(includeCalendarsJSON),
+			"webhook_token":      task.WebhookToken,
+			"webhook_signed":     task.WebhookSigned,
+			"webhook_env":        string(webhookEnvJSON),
+			"params":             string(paramsJSON),
+			"on_run_of":          string(onRunOfJSON),
+			"on_run_statuses":    string(onRunStatusesJSON),
+			"on_remote_file":     task.OnRemoteFile,
+			"max_output_bytes":   task.MaxOutputBytes,
+			"max_output_lines":   task.MaxOutputLines,
+			"output_limit_kill":  task.OutputLimitKill,
+		}, updateColumns...)
 		if err != nil {
 			sf.app.Logger().Error("[config] failed to insert or update task", slog.Any("error", err))
 		}
 	}
 }
 
+func (sf *ScriptFlow) updateFromConfigPipelines() {
+	// insert or update pipelines
+	for _, pipeline := range sf.config.Pipelines {
+		// skip empty name, project, tasks
+		if pipeline.Name == "" || pipeline.Project == "" || len(pipeline.Tasks) == 0 {
+			sf.app.Logger().Warn("[config] pipeline name, project or tasks is empty", slog.Any("pipeline", pipeline))
+			continue
+		}
+		if pipeline.Id == "" {
+			pipeline.Id = generateIdFromName(pipeline.Name)
+		}
+		if !isValidUUID(pipeline.Id) {
+			sf.app.Logger().Warn("[config] pipeline id is not a valid UUID", slog.Any("pipeline", pipeline))
+			continue
+		}
+		if !isValidTimezone(pipeline.Timezone) {
+			sf.app.Logger().Warn("[config] pipeline timezone is not valid", slog.Any("pipeline", pipeline))
+			continue
+		}
+		if pipeline.Schedule != "" {
+			if _, isAt, _ := parseAtSchedule(pipeline.Schedule); isAt {
+				sf.app.Logger().Warn("[config] pipeline schedule can't be one-time", slog.Any("pipeline", pipeline))
+				continue
+			}
+			if pipeline.Schedule == RebootSchedule {
+				sf.app.Logger().Warn("[config] pipeline schedule can't be @reboot", slog.Any("pipeline", pipeline))
+				continue
+			}
+			if err := validateSchedule(pipeline.Schedule, pipeline.Id, pipeline.Timezone); err != nil {
+				sf.app.Logger().Warn("[config] pipeline schedule is invalid", slog.Any("error", err), slog.Any("pipeline", pipeline))
+				continue
+			}
+		}
+		if err := validatePipelineSteps(pipeline.Tasks, pipeline.Steps); err != nil {
+			sf.app.Logger().Warn("[config] pipeline steps are invalid", slog.Any("error", err), slog.Any("pipeline", pipeline))
+			continue
+		}
+		tasksJSON, err := json.Marshal(pipeline.Tasks)
+		if err != nil {
+			sf.app.Logger().Error("[config] failed to marshal pipeline tasks to JSON", slog.Any("error", err))
+			continue
+		}
+		stepsJSON, err := json.Marshal(pipeline.Steps)
+		if err != nil {
+			sf.app.Logger().Error("[config] failed to marshal pipeline steps to JSON", slog.Any("error", err))
+			continue
+		}
+		err = sf.insertOrUpdate(CollectionPipelines, dbx.Params{
+			"id":       pipeline.Id,
+			"name":     pipeline.Name,
+			"project":  pipeline.Project,
+			"tasks":    string(tasksJSON),
+			"steps":    string(stepsJSON),
+			"schedule": pipeline.Schedule,
+			"timezone": pipeline.Timezone,
+			"active":   pipeline.Active,
+		}, "name", "project", "tasks", "steps", "schedule", "timezone", "active")
+		if err != nil {
+			sf.app.Logger().Error("[config] failed to insert or update pipeline", slog.Any("error", err))
+		}
+	}
+}
+
 func (sf *ScriptFlow) updateFromConfigChannels() {
 	// insert or update channels
 	for _, channel := range sf.config.Channels {
@@ -230,13 +570,13 @@ func (sf *ScriptFlow) updateFromConfigChannels() {
 }
 
 func (sf *ScriptFlow) updateFromConfigSubscriptions() {
-	events := []string{RunStatusStarted, RunStatusError, RunStatusCompleted, RunStatusInterrupted, RunStatusInternalError}
+	events := []string{RunStatusStarted, RunStatusError, RunStatusCompleted, RunStatusInterrupted, RunStatusInternalError, RunStatusKilled, RunStatusTimeout, RunStatusSkipped, RunStatusMissed}
 
 	// insert or update subscriptions
 	for _, subscription := range sf.config.Subscriptions {
-		// skip empty name, channel
-		if subscription.Name == "" || subscription.Channel == "" || subscription.Task == "" {
-			sf.app.Logger().Warn("[config] subscription id, name, channel or task is empty", slog.Any("subscription", subscription))
+		// skip empty name, channel, and subscriptions without exactly one of task and pipeline
+		if subscription.Name == "" || subscription.Channel == "" || (subscription.Task == "") == (subscription.Pipeline == "") {
+			sf.app.Logger().Warn("[config] subscription id, name, channel or task/pipeline is empty", slog.Any("subscription", subscription))
 			continue
 		}
 		if subscription.Id == "" {
@@ -257,11 +597,12 @@ func (sf *ScriptFlow) updateFromConfigSubscriptions() {
 			"id":        subscription.Id,
 			"name":      subscription.Name,
 			"task":      subscription.Task,
+			"pipeline":  subscription.Pipeline,
 			"channel":   subscription.Channel,
 			"events":    string(eventsList),
 			"threshold": subscription.Threshold,
 			"active":    subscription.Active,
-		}, "name", "task", "channel", "threshold", "active")
+		}, "name", "task", "pipeline", "channel", "threshold", "active")
 		if err != nil {
 			sf.app.Logger().Error("[config] failed to insert or update subscription", slog.Any("error", err))
 		}
@@ -292,13 +633,21 @@ func (sf *ScriptFlow) insertOrUpdate(table string, params dbx.Params, updateColu
 		setClause[i] = fmt.Sprintf("%s={:%s}", col, col)
 	}
 
+	// updated is kept when the config didn't change the record, so that it tells when the record last changed.
+	// Expressions of SET see the values before the update.
+	changed := make([]string, len(updateColumns))
+	for i, col := range updateColumns {
+		changed[i] = fmt.Sprintf("%s IS NOT {:%s}", col, col)
+	}
+
 	k := sortedKeys(params)
 	query := sf.app.DB().NewQuery(fmt.Sprintf(
-		`INSERT INTO %s (%s,created,updated) VALUES (%s,CURRENT_TIMESTAMP,CURRENT_TIMESTAMP) ON CONFLICT (id) DO UPDATE SET %s,updated=CURRENT_TIMESTAMP`,
+		`INSERT INTO %s (%s,created,updated) VALUES (%s,CURRENT_TIMESTAMP,CURRENT_TIMESTAMP) ON CONFLICT (id) DO UPDATE SET %s,updated=CASE WHEN %s THEN CURRENT_TIMESTAMP ELSE updated END`,
 		table,
 		strings.Join(k, ","),
 		strings.Join(placeholderKeys(k), ","),
 		strings.Join(setClause, ","),
+		strings.Join(changed, " OR "),
 	))
 	query.Bind(params)
 	_, err := query.Execute()
@@ -322,6 +671,24 @@ func placeholderKeys(keys []string) []string {
 	return placeholders
 }
 
+// isValidDuration reports whether s is empty or a valid Go duration string
+func isValidDuration(s string) bool {
+	if s == "" {
+		return true
+	}
+	_, err := time.ParseDuration(s)
+	return err == nil
+}
+
+// isValidTimezone checks that s is an IANA time zone name, empty string is valid
+func isValidTimezone(s string) bool {
+	if s == "" {
+		return true
+	}
+	_, err := time.LoadLocation(s)
+	return err == nil
+}
+
 func isValidUUID(s string) bool {
 	re := regexp.MustCompile(`^[a-z][a-z0-9-]{5,}$`)
 	return re.MatchString(s)
@@ -202,3 +202,45 @@ func TestGenerateIdFromName(t *testing.T) {
 		})
 	}
 }
+
+func TestIsValidDuration(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"", true},
+		{"30s", true},
+		{"1h30m", true},
+		{"30", false},
+		{"ten minutes", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := isValidDuration(tt.input); got != tt.expected {
+				t.Errorf("isValidDuration(%q) = %v, want %v", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestIsValidTimezone(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"", true},
+		{"UTC", true},
+		{"Europe/Berlin", true},
+		{"Mars/Olympus", false},
+		{"+02:00", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := isValidTimezone(tt.input); got != tt.expected {
+				t.Errorf("isValidTimezone(%q) = %v, want %v", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
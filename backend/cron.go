@@ -0,0 +1,333 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-co-op/gocron/v2"
+)
+
+// cronMacros are predefined schedules expanded to their crontab
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// cronMaxSkippedDays limits how far extendedCron looks for a day matching the L, W and # modifiers
+const cronMaxSkippedDays = 5 * 366
+
+// cronWeekdays are names of days of the week accepted in the day of week field
+var cronWeekdays = map[string]int{"SUN": 0, "MON": 1, "TUE": 2, "WED": 3, "THU": 4, "FRI": 5, "SAT": 6}
+
+// extendedCron is the gocron cron implementation which accepts an optional seconds field and the L, W and #
+// day modifiers. The modifiers are evaluated here, the rest of the crontab by the default implementation.
+// It keeps the parsed schedule, so each job needs its own instance.
+type extendedCron struct {
+	base     gocron.Cron
+	days     *cronDays // nil if the crontab has no day modifiers
+	location *time.Location
+}
+
+var _ gocron.Cron = (*extendedCron)(nil)
+
+// IsValid parses the crontab, optionally prefixed with CRON_TZ= or TZ=, and checks that it fires after now
+func (c *extendedCron) IsValid(crontab string, location *time.Location, now time.Time) error {
+	zone, schedule := splitCronTimezone(crontab)
+	if zone != "" {
+		var err error
+		location, err = time.LoadLocation(zone)
+		if err != nil {
+			return fmt.Errorf("invalid time zone %q: %w", zone, err)
+		}
+	}
+	c.location = location
+	c.days = nil
+
+	fields := strings.Fields(schedule)
+	if len(fields) == 5 || len(fields) == 6 {
+		dom, dow := len(fields)-3, len(fields)-1
+		if hasDayModifiers(fields[dom], fields[dow]) {
+			days, err := parseCronDays(fields[dom], fields[dow])
+			if err != nil {
+				return err
+			}
+			c.days = days
+			// the default implementation only filters the time of day and the month
+			fields[dom], fields[dow] = "*", "*"
+			schedule = strings.Join(fields, " ")
+		}
+	}
+
+	c.base = gocron.NewDefaultCron(true)
+	if err := c.base.IsValid("CRON_TZ="+location.String()+" "+schedule, location, now); err != nil {
+		return err
+	}
+	if c.Next(now).IsZero() {
+		return gocron.ErrCronJobInvalid
+	}
+	return nil
+}
+
+// Next returns the first fire time after lastRun, zero time if there is none
+func (c *extendedCron) Next(lastRun time.Time) time.Time {
+	next := c.base.Next(lastRun)
+	if c.days == nil {
+		return next
+	}
+	for range cronMaxSkippedDays {
+		if next.IsZero() {
+			return next
+		}
+		local := next.In(c.location)
+		if c.days.match(local) {
+			return next
+		}
+		// continue from the last second of the day
+		endOfDay := time.Date(local.Year(), local.Month(), local.Day()+1, 0, 0, 0, 0, c.location).Add(-time.Second)
+		next = c.base.Next(endOfDay)
+	}
+	return time.Time{}
+}
+
+// hasDayModifiers reports whether the day of month or the day of week field uses the L, W or # modifiers
+func hasDayModifiers(dom string, dow string) bool {
+	return strings.ContainsAny(strings.ToUpper(dom), "LW") || strings.ContainsAny(strings.ToUpper(dow), "L#")
+}
+
+// cronDayMatcher reports whether the day matches an element of the day of month or day of week field
+type cronDayMatcher func(day time.Time) bool
+
+// cronDays matches days by the day of month and the day of week fields. Like in the standard cron,
+// a day matches either field, unless one of them is "*" and the day must match both.
+type cronDays struct {
+	dom, dow         []cronDayMatcher
+	domStar, dowStar bool
+}
+
+// match reports whether the day of the time matches the fields
+func (d *cronDays) match(t time.Time) bool {
+	domMatch := d.domStar || matchAny(d.dom, t)
+	dowMatch := d.dowStar || matchAny(d.dow, t)
+	if d.domStar || d.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+func matchAny(matchers []cronDayMatcher, t time.Time) bool {
+	for _, match := range matchers {
+		if match(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseCronDays parses the day of month and the day of week fields
+func parseCronDays(dom string, dow string) (*cronDays, error) {
+	days := &cronDays{}
+	for element := range strings.SplitSeq(strings.ToUpper(dom), ",") {
+		if element == "*" || element == "?" {
+			days.domStar = true
+			continue
+		}
+		matcher, err := parseDomElement(element)
+		if err != nil {
+			return nil, fmt.Errorf("invalid day of month %q: %w", dom, err)
+		}
+		days.dom = append(days.dom, matcher)
+	}
+	for element := range strings.SplitSeq(strings.ToUpper(dow), ",") {
+		if element == "*" || element == "?" {
+			days.dowStar = true
+			continue
+		}
+		matcher, err := parseDowElement(element)
+		if err != nil {
+			return nil, fmt.Errorf("invalid day of week %q: %w", dow, err)
+		}
+		days.dow = append(days.dow, matcher)
+	}
+	return days, nil
+}
+
+// parseDomElement parses an element of the day of month field:
+// L - last day of the month, L-3 - third day before the last one, 15W - weekday nearest to the 15th,
+// LW - last weekday of the month, or a number, range or step
+func parseDomElement(element string) (cronDayMatcher, error) {
+	switch {
+	case element == "LW":
+		return func(day time.Time) bool {
+			return day.Day() == nearestWeekday(day, daysInMonth(day))
+		}, nil
+	case element == "L":
+		return func(day time.Time) bool {
+			return day.Day() == daysInMonth(day)
+		}, nil
+	case strings.HasPrefix(element, "L-"):
+		offset, err := parseCronNumber(element[2:], 0, 30)
+		if err != nil {
+			return nil, err
+		}
+		return func(day time.Time) bool {
+			return day.Day() == daysInMonth(day)-offset
+		}, nil
+	case strings.HasSuffix(element, "W"):
+		target, err := parseCronNumber(strings.TrimSuffix(element, "W"), 1, 31)
+		if err != nil {
+			return nil, err
+		}
+		return func(day time.Time) bool {
+			// the target day is beyond the end of the month
+			if target > daysInMonth(day) {
+				return false
+			}
+			return day.Day() == nearestWeekday(day, target)
+		}, nil
+	}
+	values, err := parseCronRange(element, 1, 31, nil)
+	if err != nil {
+		return nil, err
+	}
+	return func(day time.Time) bool {
+		return values[day.Day()]
+	}, nil
+}
+
+// parseDowElement parses an element of the day of week field:
+// 5L - last Friday of the month, 1#2 - second Monday of the month, or a number, name, range or step
+func parseDowElement(element string) (cronDayMatcher, error) {
+	if weekday, nth, ok := strings.Cut(element, "#"); ok {
+		value, err := parseCronWeekday(weekday)
+		if err != nil {
+			return nil, err
+		}
+		n, err := parseCronNumber(nth, 1, 5)
+		if err != nil {
+			return nil, err
+		}
+		return func(day time.Time) bool {
+			return int(day.Weekday()) == value && (day.Day()-1)/7+1 == n
+		}, nil
+	}
+	if weekday, ok := strings.CutSuffix(element, "L"); ok {
+		value, err := parseCronWeekday(weekday)
+		if err != nil {
+			return nil, err
+		}
+		return func(day time.Time) bool {
+			return int(day.Weekday()) == value && day.Day()+7 > daysInMonth(day)
+		}, nil
+	}
+	values, err := parseCronRange(element, 0, 7, cronWeekdays)
+	if err != nil {
+		return nil, err
+	}
+	return func(day time.Time) bool {
+		// 7 is Sunday as well
+		return values[int(day.Weekday())] || (day.Weekday() == time.Sunday && values[7])
+	}, nil
+}
+
+// parseCronRange parses a number, "a-b" range or "*" of the field, optionally followed by "/step",
+// and returns the set of matching values
+func parseCronRange(element string, lower, upper int, names map[string]int) (map[int]bool, error) {
+	rangeExpr, stepExpr, hasStep := strings.Cut(element, "/")
+	step := 1
+	if hasStep {
+		var err error
+		step, err = parseCronNumber(stepExpr, 1, upper)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	start, end := lower, upper
+	if rangeExpr != "*" {
+		first, last, isRange := strings.Cut(rangeExpr, "-")
+		var err error
+		start, err = parseCronValue(first, lower, upper, names)
+		if err != nil {
+			return nil, err
+		}
+		end = start
+		if isRange {
+			end, err = parseCronValue(last, lower, upper, names)
+			if err != nil {
+				return nil, err
+			}
+		} else if hasStep {
+			// "a/step" means from a to the end of the range
+			end = upper
+		}
+		if start > end {
+			return nil, fmt.Errorf("invalid range %q", rangeExpr)
+		}
+	}
+
+	values := make(map[int]bool)
+	for value := start; value <= end; value += step {
+		values[value] = true
+	}
+	return values, nil
+}
+
+// parseCronValue parses a number or a name of the value of the field
+func parseCronValue(s string, lower, upper int, names map[string]int) (int, error) {
+	if value, ok := names[s]; ok {
+		return value, nil
+	}
+	return parseCronNumber(s, lower, upper)
+}
+
+// parseCronWeekday parses a day of week, 0 or 7 is Sunday
+func parseCronWeekday(s string) (int, error) {
+	value, err := parseCronValue(s, 0, 7, cronWeekdays)
+	if err != nil {
+		return 0, err
+	}
+	return value % 7, nil
+}
+
+// parseCronNumber parses the number and checks it is within the bounds
+func parseCronNumber(s string, lower, upper int) (int, error) {
+	value, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", s)
+	}
+	if value < lower || value > upper {
+		return 0, fmt.Errorf("%d is out of range %d-%d", value, lower, upper)
+	}
+	return value, nil
+}
+
+// daysInMonth returns the number of days in the month of the time
+func daysInMonth(t time.Time) int {
+	return time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, t.Location()).Day()
+}
+
+// nearestWeekday returns the weekday of the month nearest to the target day of the month of the time,
+// without crossing into another month
+func nearestWeekday(t time.Time, target int) int {
+	day := time.Date(t.Year(), t.Month(), target, 0, 0, 0, 0, t.Location())
+	switch day.Weekday() {
+	case time.Saturday:
+		if target == 1 {
+			return target + 2
+		}
+		return target - 1
+	case time.Sunday:
+		if target == daysInMonth(t) {
+			return target - 2
+		}
+		return target + 1
+	}
+	return target
+}
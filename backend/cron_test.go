@@ -0,0 +1,144 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// cronNext returns the next count fire times of the crontab after from, evaluated in UTC
+func cronNext(t *testing.T, crontab string, from time.Time, count int) []time.Time {
+	t.Helper()
+	cron := &extendedCron{}
+	if !assert.NoError(t, cron.IsValid(crontab, time.UTC, from), crontab) {
+		return nil
+	}
+	var times []time.Time
+	for next := from; len(times) < count; {
+		next = cron.Next(next)
+		if next.IsZero() {
+			break
+		}
+		times = append(times, next.UTC())
+	}
+	return times
+}
+
+func utcTime(year int, month time.Month, d, hour, min, sec int) time.Time {
+	return time.Date(year, month, d, hour, min, sec, 0, time.UTC)
+}
+
+func TestExtendedCronSeconds(t *testing.T) {
+	from := utcTime(2025, 1, 1, 10, 0, 0)
+	assert.Equal(t, []time.Time{utcTime(2025, 1, 1, 10, 0, 15), utcTime(2025, 1, 1, 10, 0, 45)}, cronNext(t, "15,45 * * * * *", from, 2))
+	assert.Equal(t, []time.Time{utcTime(2025, 1, 1, 10, 1, 0)}, cronNext(t, "* * * * *", from, 1), "5-field crons fire at second 0")
+}
+
+func TestExtendedCronDayOfMonthModifiers(t *testing.T) {
+	from := utcTime(2025, 1, 10, 0, 0, 0)
+
+	assert.Equal(t, []time.Time{utcTime(2025, 1, 31, 0, 0, 0), utcTime(2025, 2, 28, 0, 0, 0), utcTime(2025, 3, 31, 0, 0, 0)},
+		cronNext(t, "0 0 L * *", from, 3), "last day of the month")
+	assert.Equal(t, []time.Time{utcTime(2025, 1, 29, 0, 0, 0), utcTime(2025, 2, 26, 0, 0, 0)},
+		cronNext(t, "0 0 L-2 * *", from, 2), "second day before the last one")
+	// 2025-02-15 is Saturday, 2025-06-15 is Sunday
+	assert.Equal(t, []time.Time{utcTime(2025, 2, 14, 0, 0, 0)}, cronNext(t, "0 0 15W 2 *", from, 1), "Friday before Saturday")
+	assert.Equal(t, []time.Time{utcTime(2025, 6, 16, 0, 0, 0)}, cronNext(t, "0 0 15W 6 *", from, 1), "Monday after Sunday")
+	// 2025-02-01 is Saturday, the nearest weekday of the month is Monday the 3rd
+	assert.Equal(t, []time.Time{utcTime(2025, 2, 3, 0, 0, 0)}, cronNext(t, "0 0 1W 2 *", from, 1), "month is not crossed")
+	// 2025-05-31 is Saturday
+	assert.Equal(t, []time.Time{utcTime(2025, 5, 30, 0, 0, 0)}, cronNext(t, "0 0 LW 5 *", from, 1), "last weekday of the month")
+	assert.Equal(t, []time.Time{utcTime(2025, 1, 31, 0, 0, 0), utcTime(2025, 3, 31, 0, 0, 0)},
+		cronNext(t, "0 0 31W * *", from, 2), "months without the day are skipped")
+}
+
+func TestExtendedCronDayOfWeekModifiers(t *testing.T) {
+	from := utcTime(2025, 1, 1, 0, 0, 0)
+
+	assert.Equal(t, []time.Time{utcTime(2025, 1, 31, 18, 0, 0), utcTime(2025, 2, 28, 18, 0, 0)},
+		cronNext(t, "0 18 * * 5L", from, 2), "last Friday of the month")
+	assert.Equal(t, []time.Time{utcTime(2025, 1, 26, 0, 0, 0)}, cronNext(t, "0 0 * * 7L", from, 1), "7 is Sunday")
+	assert.Equal(t, []time.Time{utcTime(2025, 1, 13, 9, 0, 0), utcTime(2025, 2, 10, 9, 0, 0)},
+		cronNext(t, "0 9 * * MON#2", from, 2), "second Monday of the month")
+	// 2025 has a fifth Wednesday in January, April, July, October and December
+	assert.Equal(t, []time.Time{utcTime(2025, 1, 29, 0, 0, 0), utcTime(2025, 4, 30, 0, 0, 0)},
+		cronNext(t, "0 0 * * 3#5", from, 2), "months without the fifth Wednesday are skipped")
+	assert.Equal(t, []time.Time{utcTime(2025, 1, 1, 0, 0, 30), utcTime(2025, 1, 3, 0, 0, 30)},
+		cronNext(t, "30 0 0 * * 3#1,FRI", from.Add(time.Second), 2), "modifiers combine with other elements")
+}
+
+func TestExtendedCronDayFieldsCombination(t *testing.T) {
+	from := utcTime(2025, 1, 1, 0, 0, 0)
+	// either field matches if neither is "*": the last day of the month or the first Monday
+	assert.Equal(t, []time.Time{utcTime(2025, 1, 6, 0, 0, 0), utcTime(2025, 1, 31, 0, 0, 0), utcTime(2025, 2, 3, 0, 0, 0)},
+		cronNext(t, "0 0 L * 1#1", from, 3))
+	// both fields match if one is "*"
+	assert.Equal(t, []time.Time{utcTime(2025, 1, 31, 0, 0, 0), utcTime(2025, 2, 28, 0, 0, 0)},
+		cronNext(t, "0 0 L * *", from, 2))
+}
+
+func TestExtendedCronTimezone(t *testing.T) {
+	tokyo, _ := time.LoadLocation("Asia/Tokyo")
+	times := cronNext(t, "CRON_TZ=Asia/Tokyo 0 9 L * *", utcTime(2025, 1, 1, 0, 0, 0), 1)
+	if assert.Len(t, times, 1) {
+		assert.Equal(t, time.Date(2025, 1, 31, 9, 0, 0, 0, tokyo), times[0].In(tokyo))
+	}
+}
+
+func TestExtendedCronInvalid(t *testing.T) {
+	now := utcTime(2025, 1, 1, 0, 0, 0)
+	for _, crontab := range []string{
+		"0 0 L-31 * *", "0 0 32W * *", "0 0 XW * *", "0 0 * * 8L", "0 0 * * 1#6", "0 0 * * MON#0",
+		"0 0 * * 5-1", "0 0 30 2 *", "0 0 30W 2 *", "61 0 0 * * *", "* * * * * * *",
+	} {
+		assert.Error(t, (&extendedCron{}).IsValid(crontab, time.UTC, now), crontab)
+	}
+}
+
+func TestCronMacros(t *testing.T) {
+	now := utcTime(2025, 1, 1, 10, 30, 0)
+	for macro, next := range map[string]time.Time{
+		"@hourly":  utcTime(2025, 1, 1, 11, 0, 0),
+		"@daily":   utcTime(2025, 1, 2, 0, 0, 0),
+		"@weekly":  utcTime(2025, 1, 5, 0, 0, 0),
+		"@monthly": utcTime(2025, 2, 1, 0, 0, 0),
+		"@yearly":  utcTime(2026, 1, 1, 0, 0, 0),
+	} {
+		preview, err := previewSchedule(macro, "task", "UTC", now, 1)
+		if assert.NoError(t, err, macro) && assert.Len(t, preview.Next, 1, macro) {
+			assert.Equal(t, next, preview.Next[0].UTC(), macro)
+		}
+	}
+
+	crontab, _, err := scheduleCrontab("CRON_TZ=Asia/Tokyo @daily", "task", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "CRON_TZ=Asia/Tokyo 0 0 * * *", crontab)
+}
+
+func TestResolveHashedScheduleSeconds(t *testing.T) {
+	fiveFields, err := resolveHashedSchedule("H H * * *", "task")
+	assert.NoError(t, err)
+	sixFields, err := resolveHashedSchedule("H H H * * *", "task")
+	assert.NoError(t, err)
+	assert.Equal(t, fiveFields, sixFields[len(sixFields)-len(fiveFields):], "seconds don't change the other fields")
+
+	again, _ := resolveHashedSchedule("H H H * * *", "task")
+	assert.Equal(t, sixFields, again, "resolution is deterministic")
+
+	resolved, err := resolveHashedSchedule("H(10-19) 0 * * * *", "task")
+	assert.NoError(t, err)
+	assert.Regexp(t, `^1\d 0 \* \* \* \*$`, resolved)
+
+	_, err = resolveHashedSchedule("H(0-60) * * * * *", "task")
+	assert.Error(t, err)
+}
+
+func TestRebootSchedule(t *testing.T) {
+	assert.NoError(t, validateSchedule(RebootSchedule, "task", ""))
+	assert.Error(t, validateSchedule(RebootSchedule, "task", "Mars/Olympus"))
+
+	preview, err := previewSchedule(RebootSchedule, "task", "UTC", time.Now(), 3)
+	assert.NoError(t, err)
+	assert.Empty(t, preview.Next)
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/security"
+)
+
+// envNamePattern matches valid shell environment variable names
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// TaskEnv holds environment variables of a run and secret values to be masked in its output
+type TaskEnv struct {
+	Vars    map[string]string
+	Secrets []string
+}
+
+// resolveTaskEnv merges env maps of the node, the project and the task, the task has the highest priority.
+// Node may be nil for tasks targeting a node selector.
+// Values prefixed with SecretRefPrefix are replaced by decrypted values of the secrets collection.
+func (sf *ScriptFlow) resolveTaskEnv(node *core.Record, task *core.Record) (*TaskEnv, error) {
+	project, err := sf.app.FindRecordById(CollectionProjects, task.GetString("project"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find project: %w", err)
+	}
+
+	merged := map[string]string{}
+	for _, record := range []*core.Record{node, project, task} {
+		if record == nil {
+			continue
+		}
+		recordEnv := map[string]string{}
+		if err := record.UnmarshalJSONField("env", &recordEnv); err != nil {
+			return nil, fmt.Errorf("invalid env of %s %s: %w", record.Collection().Name, record.Id, err)
+		}
+		for name, value := range recordEnv {
+			merged[name] = value
+		}
+	}
+
+	env := &TaskEnv{Vars: make(map[string]string, len(merged))}
+	for name, value := range merged {
+		if !envNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("invalid env variable name: %q", name)
+		}
+		secretName, isSecret := strings.CutPrefix(value, SecretRefPrefix)
+		if !isSecret {
+			env.Vars[name] = value
+			continue
+		}
+		secretValue, err := sf.findSecretValue(secretName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve secret %q of env variable %s: %w", secretName, name, err)
+		}
+		env.Vars[name] = secretValue
+		env.Secrets = append(env.Secrets, secretValue)
+	}
+	return env, nil
+}
+
+// resolveRunEnv returns env of the task extended by values of the task parameters and env variables passed
+// by the trigger of the run, which have the highest priority
+func (sf *ScriptFlow) resolveRunEnv(node *core.Record, task *core.Record, run *core.Record) (*TaskEnv, error) {
+	env, err := sf.resolveTaskEnv(node, task)
+	if err != nil {
+		return nil, err
+	}
+	params := map[string]string{}
+	if err := run.UnmarshalJSONField("params", &params); err != nil {
+		return nil, fmt.Errorf("invalid params of run %s: %w", run.Id, err)
+	}
+	for name, value := range params {
+		env.Vars[name] = value
+	}
+	runEnv := map[string]string{}
+	if err := run.UnmarshalJSONField("env", &runEnv); err != nil {
+		return nil, fmt.Errorf("invalid env of run %s: %w", run.Id, err)
+	}
+	for name, value := range runEnv {
+		env.Vars[name] = value
+	}
+	return env, nil
+}
+
+// taskSecrets returns secret values referenced by env of the task, its project and node
+func (sf *ScriptFlow) taskSecrets(task *core.Record) []string {
+	var node *core.Record
+	if nodeId := task.GetString("node"); nodeId != "" {
+		var err error
+		node, err = sf.app.FindRecordById(CollectionNodes, nodeId)
+		if err != nil {
+			sf.app.Logger().Error("failed to find node", taskAttrs(task), slog.Any("error", err))
+			return nil
+		}
+	}
+	env, err := sf.resolveTaskEnv(node, task)
+	if err != nil {
+		sf.app.Logger().Error("failed to resolve task env", taskAttrs(task), slog.Any("error", err))
+		return nil
+	}
+	return env.Secrets
+}
+
+// findSecretValue returns decrypted value of the secret by its name
+func (sf *ScriptFlow) findSecretValue(name string) (string, error) {
+	secret, err := sf.app.FindFirstRecordByFilter(CollectionSecrets, "name={:name}", dbx.Params{"name": name})
+	if err != nil {
+		return "", err
+	}
+	key, err := secretsKey()
+	if err != nil {
+		return "", err
+	}
+	value, err := security.Decrypt(secret.GetString("value"), key)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt secret: %w", err)
+	}
+	return string(value), nil
+}
+
+// encryptSecretValue encrypts value of the secret record before it is stored,
+// value is encrypted only when it was changed, so that already encrypted value is kept as is
+func encryptSecretValue(record *core.Record) error {
+	value := record.GetString("value")
+	if !record.IsNew() && value == record.Original().GetString("value") {
+		return nil
+	}
+	key, err := secretsKey()
+	if err != nil {
+		return err
+	}
+	encrypted, err := security.Encrypt([]byte(value), key)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt secret: %w", err)
+	}
+	record.Set("value", encrypted)
+	return nil
+}
+
+// secretsKey returns AES key used to encrypt secrets, it has to be exactly 32 characters long
+func secretsKey() (string, error) {
+	key := os.Getenv(SecretsKeyEnv)
+	if len(key) != 32 {
+		return "", NewSecretsKeyNotConfiguredError()
+	}
+	return key, nil
+}
+
+// envExportCommand returns shell statement exporting variables, variables are sorted by name
+func envExportCommand(vars map[string]string) string {
+	if len(vars) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	assignments := make([]string, len(names))
+	for i, name := range names {
+		assignments[i] = name + "=" + shellQuote(vars[name])
+	}
+	return "export " + strings.Join(assignments, " ") + "; "
+}
+
+// shellQuote quotes s for POSIX shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// maskSecrets replaces every secret value found in s with SecretMask
+func maskSecrets(s string, secrets []string) string {
+	if len(secrets) == 0 {
+		return s
+	}
+	// replace longer secrets first, so that a secret containing another one is fully masked
+	sorted := append([]string(nil), secrets...)
+	sort.Slice(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+	for _, secret := range sorted {
+		if secret == "" {
+			continue
+		}
+		s = strings.ReplaceAll(s, secret, SecretMask)
+	}
+	return s
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/security"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnvExportCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		vars     map[string]string
+		expected string
+	}{
+		{"no vars", map[string]string{}, ""},
+		{"single var", map[string]string{"A": "1"}, "export A='1'; "},
+		{"sorted by name", map[string]string{"B": "2", "A": "1"}, "export A='1' B='2'; "},
+		{"value with spaces", map[string]string{"MSG": "hello world"}, "export MSG='hello world'; "},
+		{"value with quote", map[string]string{"MSG": "it's"}, `export MSG='it'\''s'; `},
+		{"value with shell chars", map[string]string{"X": "$(rm -rf /); `id`"}, "export X='$(rm -rf /); `id`'; "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, envExportCommand(tt.vars))
+		})
+	}
+}
+
+func TestMaskSecrets(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		secrets  []string
+		expected string
+	}{
+		{"no secrets", "password is s3cr3t", nil, "password is s3cr3t"},
+		{"single secret", "password is s3cr3t", []string{"s3cr3t"}, "password is ***"},
+		{"multiple occurrences", "s3cr3t:s3cr3t", []string{"s3cr3t"}, "***:***"},
+		{"empty secret ignored", "value", []string{""}, "value"},
+		{"longer secret first", "token abc123", []string{"abc", "abc123"}, "token ***"},
+		{"several secrets", "user=admin pass=qwerty", []string{"admin", "qwerty"}, "user=*** pass=***"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, maskSecrets(tt.input, tt.secrets))
+		})
+	}
+}
+
+func TestEncryptSecretValue(t *testing.T) {
+	collection := core.NewBaseCollection(CollectionSecrets)
+	collection.Fields.Add(&core.TextField{Name: "value"})
+
+	t.Run("key is not configured", func(t *testing.T) {
+		t.Setenv(SecretsKeyEnv, "")
+		record := core.NewRecord(collection)
+		record.Set("value", "s3cr3t")
+		assert.Error(t, encryptSecretValue(record))
+	})
+
+	t.Run("value is encrypted", func(t *testing.T) {
+		key := "0123456789abcdef0123456789abcdef"
+		t.Setenv(SecretsKeyEnv, key)
+		record := core.NewRecord(collection)
+		record.Set("value", "s3cr3t")
+		require.NoError(t, encryptSecretValue(record))
+		assert.NotEqual(t, "s3cr3t", record.GetString("value"))
+
+		decrypted, err := security.Decrypt(record.GetString("value"), key)
+		require.NoError(t, err)
+		assert.Equal(t, "s3cr3t", string(decrypted))
+	})
+}
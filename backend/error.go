@@ -15,11 +15,21 @@ func NewNodeStatusNotOnlineError() error {
 	return &ScriptFlowError{"node status is not online"}
 }
 
+// node not found error
+func NewNodeNotFoundError() error {
+	return &ScriptFlowError{"node not found"}
+}
+
 // task not active error
 func NewTaskNotActiveError() error {
 	return &ScriptFlowError{"task is not active"}
 }
 
+// secrets encryption key is not configured error
+func NewSecretsKeyNotConfiguredError() error {
+	return &ScriptFlowError{"secrets encryption key is not configured, set " + SecretsKeyEnv + " to 32 characters"}
+}
+
 // failed create log file directory error
 func NewFailedCreateLogFileDirectoryError() error {
 	return &ScriptFlowError{"failed to create log file directory"}
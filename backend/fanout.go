@@ -0,0 +1,272 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// nodeLabelPattern matches valid node labels
+var nodeLabelPattern = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+
+// parseNodeSelector returns labels of the selector, e.g. "web,eu" selects nodes having both labels
+func parseNodeSelector(selector string) []string {
+	var labels []string
+	for _, label := range strings.Split(selector, ",") {
+		if label = strings.TrimSpace(label); label != "" {
+			labels = append(labels, label)
+		}
+	}
+	return labels
+}
+
+// validateNodeSelector checks node selector, mode and count of the task
+func validateNodeSelector(selector string, mode string, count int) error {
+	for _, label := range parseNodeSelector(selector) {
+		if !nodeLabelPattern.MatchString(label) {
+			return fmt.Errorf("invalid node label: %q", label)
+		}
+	}
+	if mode != "" && !slices.Contains(NodeModes, mode) {
+		return fmt.Errorf("invalid node mode: %q", mode)
+	}
+	if count < 0 {
+		return fmt.Errorf("invalid node count: %d", count)
+	}
+	return nil
+}
+
+// validateNodeLabels checks labels of the node
+func validateNodeLabels(labels []string) error {
+	for _, label := range labels {
+		if !nodeLabelPattern.MatchString(label) {
+			return fmt.Errorf("invalid node label: %q", label)
+		}
+	}
+	return nil
+}
+
+// nodeMatchesSelector reports whether the node has all labels of the selector
+func nodeMatchesSelector(node *core.Record, selector []string) bool {
+	var labels []string
+	if err := node.UnmarshalJSONField("labels", &labels); err != nil {
+		return false
+	}
+	for _, label := range selector {
+		if !slices.Contains(labels, label) {
+			return false
+		}
+	}
+	return true
+}
+
+// taskNodeCount returns number of nodes the task runs on in a single execution, 0 means all matching nodes
+func taskNodeCount(task *core.Record) int {
+	switch task.GetString("node_mode") {
+	case NodeModeAnyOne:
+		return 1
+	case NodeModeNOf:
+		return max(task.GetInt("node_count"), 1)
+	default:
+		return 0
+	}
+}
+
+// pickNodes returns count nodes starting at offset and wrapping around, all nodes if count is 0 or exceeds their number
+func pickNodes(nodes []*core.Record, count int, offset int) []*core.Record {
+	if count == 0 || count >= len(nodes) {
+		return nodes
+	}
+	picked := make([]*core.Record, 0, count)
+	for i := range count {
+		picked = append(picked, nodes[(offset+i)%len(nodes)])
+	}
+	return picked
+}
+
+// nextRoundRobinOffset returns offset of the first node to pick for the task and moves it by count
+func (sf *ScriptFlow) nextRoundRobinOffset(taskId string, count int, total int) int {
+	sf.roundRobinMutex.Lock()
+	defer sf.roundRobinMutex.Unlock()
+	offset := sf.roundRobin[taskId] % total
+	sf.roundRobin[taskId] = (offset + count) % total
+	return offset
+}
+
+// selectTaskNodes returns nodes matching node selector of the task to run it on,
+// and matching nodes which are offline. Nodes are ordered by host and username.
+// In "any-one" and "n-of" modes online nodes are picked round-robin between executions.
+func (sf *ScriptFlow) selectTaskNodes(task *core.Record) ([]*core.Record, []*core.Record, error) {
+	nodes, err := sf.app.FindAllRecords(CollectionNodes)
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].GetString("host") != nodes[j].GetString("host") {
+			return nodes[i].GetString("host") < nodes[j].GetString("host")
+		}
+		return nodes[i].GetString("username") < nodes[j].GetString("username")
+	})
+
+	selector := parseNodeSelector(task.GetString("node_selector"))
+	var online, offline []*core.Record
+	for _, node := range nodes {
+		if !nodeMatchesSelector(node, selector) {
+			continue
+		}
+		if node.GetString("status") == NodeStatusOnline {
+			online = append(online, node)
+		} else {
+			offline = append(offline, node)
+		}
+	}
+
+	count := taskNodeCount(task)
+	if count == 0 || len(online) == 0 {
+		return online, offline, nil
+	}
+	if count > len(online) {
+		sf.app.Logger().Warn("not enough online nodes match selector", taskAttrs(task), slog.Int("count", count), slog.Int("online", len(online)))
+	}
+	// nodes which are not picked in this execution are neither run nor reported as offline
+	return pickNodes(online, count, sf.nextRoundRobinOffset(task.Id, count, len(online))), nil, nil
+}
+
+// runExecution runs the task on the nodes selected by its node selector. A parent run is created for
+// the execution with a child run per node, the parent status is aggregated from the child runs.
+func (sf *ScriptFlow) runExecution(task *core.Record, trigger RunTrigger) {
+	if !task.GetBool("active") {
+		sf.skipTrigger(task, trigger, NewTaskNotActiveError().Error())
+		return
+	}
+
+	nodes, offlineNodes, err := sf.selectTaskNodes(task)
+	if err != nil {
+		sf.app.Logger().Error("failed to select nodes", taskAttrs(task), slog.Any("error", err))
+		sf.skipTrigger(task, trigger, SkipReasonInternalError)
+		return
+	}
+	if len(nodes) == 0 {
+		sf.skipTrigger(task, trigger, SkipReasonNoNodeMatches)
+		return
+	}
+
+	execution, err := sf.newRunRecord(nil, task, trigger)
+	if err != nil {
+		sf.app.Logger().Error("failed to create record", slog.Any("error", err))
+		return
+	}
+	execution.Set("host", "selector: "+task.GetString("node_selector"))
+	if err := sf.app.Save(execution); err != nil {
+		sf.app.Logger().Error("failed to save run record", slog.Any("error", err))
+		return
+	}
+
+	// killing the execution kills all its child runs
+	sf.registerActiveRun(execution.Id, func() { sf.killExecutionRuns(execution.Id) })
+	defer sf.unregisterActiveRun(execution.Id)
+
+	// nodes which are offline are recorded as skipped runs of the execution
+	for _, node := range offlineNodes {
+		run, err := sf.newRunRecord(node, task, RunTrigger{Type: trigger.Type, Env: trigger.Env, Params: trigger.Params, SourceRun: trigger.SourceRun, Execution: execution.Id})
+		if err != nil {
+			sf.app.Logger().Error("failed to create record", slog.Any("error", err))
+			continue
+		}
+		run.Set("status", RunStatusSkipped)
+		run.Set("reason", NewNodeStatusNotOnlineError().Error())
+		if err := sf.app.Save(run); err != nil {
+			sf.app.Logger().Error("failed to save run record", slog.Any("error", err))
+		}
+	}
+
+	var wg sync.WaitGroup
+	for _, node := range nodes {
+		run, err := sf.newRunRecord(node, task, RunTrigger{Type: trigger.Type, Env: trigger.Env, Params: trigger.Params, SourceRun: trigger.SourceRun, Execution: execution.Id})
+		if err != nil {
+			sf.app.Logger().Error("failed to create record", slog.Any("error", err))
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sf.runWithRetries(node, task, run)
+		}()
+	}
+	wg.Wait()
+
+	status, err := sf.executionStatus(execution.Id)
+	if err != nil {
+		sf.app.Logger().Error("failed to aggregate execution status", taskAttrs(task), slog.Any("error", err))
+		status = RunStatusInternalError
+	}
+	execution.Set("status", status)
+	if err := sf.app.Save(execution); err != nil {
+		sf.app.Logger().Error("failed to save run record", slog.Any("error", err))
+	}
+}
+
+// isExecutionChild reports whether the run is a child run of a fan-out execution, which is reported and counted
+// by the execution itself. Step runs are linked to their pipeline run the same way, but they are runs of their task.
+func isExecutionChild(run *core.Record) bool {
+	return run.GetString("execution") != "" && run.GetString("pipeline") == ""
+}
+
+// killExecutionRuns kills all started and queued child runs of the execution, as well as runs waiting for a retry
+func (sf *ScriptFlow) killExecutionRuns(executionId string) {
+	runs, err := sf.app.FindAllRecords(CollectionRuns, dbx.HashExp{"execution": executionId}, dbx.Or(
+		dbx.HashExp{"status": []any{RunStatusStarted, RunStatusQueued}},
+		dbx.HashExp{"retrying": true},
+	))
+	if err != nil {
+		sf.app.Logger().Error("failed to find execution runs", slog.String("runId", executionId), slog.Any("error", err))
+		return
+	}
+	for _, run := range runs {
+		if err := sf.KillRun(run.Id); err != nil {
+			sf.app.Logger().Warn("failed to kill run", slog.String("runId", run.Id), slog.Any("error", err))
+		}
+	}
+}
+
+// executionStatus returns status of the execution aggregated from final attempts of its child runs
+func (sf *ScriptFlow) executionStatus(executionId string) (string, error) {
+	runs, err := sf.app.FindAllRecords(CollectionRuns, dbx.HashExp{"execution": executionId, "retrying": false})
+	if err != nil {
+		return "", err
+	}
+	statuses := make([]string, len(runs))
+	for i, run := range runs {
+		statuses[i] = run.GetString("status")
+	}
+	return aggregateRunStatus(statuses), nil
+}
+
+// aggregateRunStatus returns status of an execution from statuses of its child runs: a pending run keeps
+// the execution started, any failure fails it, skipped runs count only when nothing else was run
+func aggregateRunStatus(statuses []string) string {
+	has := func(values ...string) bool {
+		return slices.ContainsFunc(statuses, func(status string) bool { return slices.Contains(values, status) })
+	}
+	switch {
+	case has(RunStatusStarted, RunStatusQueued):
+		return RunStatusStarted
+	case has(RunStatusError, RunStatusInternalError, RunStatusTimeout):
+		return RunStatusError
+	case has(RunStatusInterrupted):
+		return RunStatusInterrupted
+	case has(RunStatusKilled):
+		return RunStatusKilled
+	case has(RunStatusCompleted):
+		return RunStatusCompleted
+	default:
+		return RunStatusSkipped
+	}
+}
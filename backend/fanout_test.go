@@ -0,0 +1,95 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestNode(id string, labels string) *core.Record {
+	collection := core.NewBaseCollection(CollectionNodes)
+	collection.Fields.Add(&core.JSONField{Name: "labels"})
+	node := core.NewRecord(collection)
+	node.Id = id
+	node.Set("labels", labels)
+	return node
+}
+
+func nodeIds(nodes []*core.Record) []string {
+	ids := make([]string, len(nodes))
+	for i, node := range nodes {
+		ids[i] = node.Id
+	}
+	return ids
+}
+
+func TestParseNodeSelector(t *testing.T) {
+	assert.Equal(t, []string{"web"}, parseNodeSelector("web"))
+	assert.Equal(t, []string{"web", "eu"}, parseNodeSelector(" web, eu ,"))
+	assert.Empty(t, parseNodeSelector(""))
+}
+
+func TestValidateNodeSelector(t *testing.T) {
+	assert.NoError(t, validateNodeSelector("", "", 0))
+	assert.NoError(t, validateNodeSelector("web,eu-1", NodeModeNOf, 2))
+	assert.Error(t, validateNodeSelector("web servers", "", 0))
+	assert.Error(t, validateNodeSelector("web", "some", 0))
+	assert.Error(t, validateNodeSelector("web", NodeModeNOf, -1))
+	assert.NoError(t, validateNodeLabels([]string{"web", "eu"}))
+	assert.Error(t, validateNodeLabels([]string{"web,eu"}))
+}
+
+func TestNodeMatchesSelector(t *testing.T) {
+	node := newTestNode("n1", `["web","eu"]`)
+	assert.True(t, nodeMatchesSelector(node, []string{"web"}))
+	assert.True(t, nodeMatchesSelector(node, []string{"web", "eu"}))
+	assert.False(t, nodeMatchesSelector(node, []string{"web", "us"}))
+	assert.False(t, nodeMatchesSelector(newTestNode("n2", ""), []string{"web"}), "node without labels")
+}
+
+func TestPickNodes(t *testing.T) {
+	nodes := []*core.Record{newTestNode("a", ""), newTestNode("b", ""), newTestNode("c", "")}
+
+	assert.Equal(t, []string{"a", "b", "c"}, nodeIds(pickNodes(nodes, 0, 1)), "all nodes")
+	assert.Equal(t, []string{"a", "b", "c"}, nodeIds(pickNodes(nodes, 5, 1)), "count exceeds nodes")
+	assert.Equal(t, []string{"b"}, nodeIds(pickNodes(nodes, 1, 1)))
+	assert.Equal(t, []string{"c", "a"}, nodeIds(pickNodes(nodes, 2, 2)), "wraps around")
+}
+
+func TestNextRoundRobinOffset(t *testing.T) {
+	sf := &ScriptFlow{roundRobin: make(map[string]int)}
+
+	assert.Equal(t, 0, sf.nextRoundRobinOffset("t", 1, 3))
+	assert.Equal(t, 1, sf.nextRoundRobinOffset("t", 1, 3))
+	assert.Equal(t, 2, sf.nextRoundRobinOffset("t", 1, 3))
+	assert.Equal(t, 0, sf.nextRoundRobinOffset("t", 1, 3))
+	assert.Equal(t, 0, sf.nextRoundRobinOffset("other", 2, 3), "offsets are per task")
+	// number of online nodes shrank
+	assert.Equal(t, 1, sf.nextRoundRobinOffset("t", 1, 2))
+}
+
+func TestAggregateRunStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []string
+		expected string
+	}{
+		{"all completed", []string{RunStatusCompleted, RunStatusCompleted}, RunStatusCompleted},
+		{"still running", []string{RunStatusCompleted, RunStatusStarted, RunStatusError}, RunStatusStarted},
+		{"still queued", []string{RunStatusCompleted, RunStatusQueued}, RunStatusStarted},
+		{"one failed", []string{RunStatusCompleted, RunStatusError}, RunStatusError},
+		{"one timed out", []string{RunStatusCompleted, RunStatusTimeout}, RunStatusError},
+		{"internal error", []string{RunStatusInternalError, RunStatusInterrupted}, RunStatusError},
+		{"interrupted", []string{RunStatusCompleted, RunStatusInterrupted, RunStatusKilled}, RunStatusInterrupted},
+		{"killed", []string{RunStatusCompleted, RunStatusKilled}, RunStatusKilled},
+		{"offline node skipped", []string{RunStatusCompleted, RunStatusSkipped}, RunStatusCompleted},
+		{"all skipped", []string{RunStatusSkipped}, RunStatusSkipped},
+		{"no runs", nil, RunStatusSkipped},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, aggregateRunStatus(tt.statuses))
+		})
+	}
+}
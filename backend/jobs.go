@@ -6,6 +6,7 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/pocketbase/dbx"
@@ -70,6 +71,9 @@ func (sf *ScriptFlow) RemoveTaskLogs(taskId string) error {
 }
 
 func (sf *ScriptFlow) JobRemoveOutdatedLogs() {
+	sf.logFilesMutex.Lock()
+	defer sf.logFilesMutex.Unlock()
+
 	projects, err := sf.getProjects()
 	if err != nil {
 		return
@@ -92,23 +96,25 @@ func (sf *ScriptFlow) JobRemoveOutdatedLogs() {
 				continue
 			}
 
-			// Iterate over files and remove outdated logs
+			// Iterate over day directories of run log files and remove outdated ones,
+			// daily log files are left by earlier versions if they couldn't be migrated
 			for _, file := range files {
+				fileName := file.Name()
+				var fileDate time.Time
 				if file.IsDir() {
-					continue
+					fileDate, err = logDirDate(fileName)
+				} else {
+					fileDate, err = sf.taskFileDate(strings.TrimSuffix(fileName, CompressedLogExt))
 				}
-
-				fileName := file.Name()
-				fileDate, err := sf.taskFileDate(fileName)
 				if err != nil {
 					sf.app.Logger().Error("failed to parse log file name", slog.Any("fileName", fileName), slog.Any("error", err))
 					continue
 				}
 
-				// Remove file if older than logsMaxDays
+				// Remove logs older than logsMaxDays
 				if fileDate.Before(cutoff) {
 					filePath := filepath.Join(logDir, fileName)
-					err := os.Remove(filePath)
+					err := os.RemoveAll(filePath)
 					if err != nil {
 						sf.app.Logger().Error("failed to remove outdated log file", slog.String("filePath", filePath), slog.Any("error", err))
 					} else {
@@ -156,6 +162,35 @@ func (sf *ScriptFlow) JobRemoveOutdatedRecords() {
 				)
 			}
 		}
+
+		// pipeline runs have no task, runs of their steps are removed with the runs of the tasks
+		pipelines, err := sf.app.FindAllRecords(CollectionPipelines, dbx.HashExp{"project": project.Id})
+		if err != nil {
+			sf.app.Logger().Error("failed to query pipelines collection", slog.Any("error", err))
+			continue
+		}
+		for _, pipeline := range pipelines {
+			query := sf.app.DB().Delete(
+				CollectionRuns,
+				dbx.NewExp(
+					"pipeline = {:pipeline} AND task = '' AND created < {:created}",
+					dbx.Params{"pipeline": pipeline.Id, "created": cutoff},
+				))
+			result, err := query.Execute()
+			if err != nil {
+				sf.app.Logger().Error("failed to delete runs", slog.Any("error", err))
+				continue
+			}
+
+			affected, _ := result.RowsAffected()
+			if affected > 0 {
+				sf.app.Logger().Info("deleted outdated pipeline run records",
+					slog.Int64("count", affected),
+					slog.String("pipelineId", pipeline.Id),
+					slog.Time("olderThan", cutoff),
+				)
+			}
+		}
 	}
 }
 
@@ -218,14 +253,26 @@ func (sf *ScriptFlow) JobSendNotifications() {
 			sf.app.Logger().Error("failed to find run", slog.Any("error", err))
 			continue
 		}
-		// retrieve task
-		task, err := sf.app.FindRecordById(CollectionTasks, run.GetString("task"))
-		if err != nil {
-			sf.app.Logger().Error("failed to find task", slog.Any("error", err))
-			continue
+		// retrieve task, or pipeline of a pipeline run
+		var task, pipeline *core.Record
+		var projectId string
+		if run.GetString("task") == "" {
+			pipeline, err = sf.app.FindRecordById(CollectionPipelines, run.GetString("pipeline"))
+			if err != nil {
+				sf.app.Logger().Error("failed to find pipeline", slog.Any("error", err))
+				continue
+			}
+			projectId = pipeline.GetString("project")
+		} else {
+			task, err = sf.app.FindRecordById(CollectionTasks, run.GetString("task"))
+			if err != nil {
+				sf.app.Logger().Error("failed to find task", slog.Any("error", err))
+				continue
+			}
+			projectId = task.GetString("project")
 		}
 		// retrieve project
-		project, err := sf.app.FindRecordById(CollectionProjects, task.GetString("project"))
+		project, err := sf.app.FindRecordById(CollectionProjects, projectId)
 		if err != nil {
 			sf.app.Logger().Error("failed to find project", slog.Any("error", err))
 			continue
@@ -246,6 +293,7 @@ func (sf *ScriptFlow) JobSendNotifications() {
 		err = sf.sendNotification(NotificationContext{
 			Project:      project,
 			Task:         task,
+			Pipeline:     pipeline,
 			Run:          run,
 			Notification: notification,
 			Subscription: subscription,
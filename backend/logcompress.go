@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// CompressedLogExt is appended to the name of compressed log files
+const CompressedLogExt = ".gz"
+
+// gzipReadCloser closes the gzip reader along with the underlying file
+type gzipReadCloser struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (r *gzipReadCloser) Close() error {
+	return errors.Join(r.Reader.Close(), r.file.Close())
+}
+
+// openLogReader opens the log file for reading, or its compressed version if the file was compressed
+func openLogReader(filePath string) (io.ReadCloser, error) {
+	file, err := os.Open(filePath)
+	if !errors.Is(err, fs.ErrNotExist) {
+		return file, err
+	}
+	file, err = os.Open(filePath + CompressedLogExt)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to read compressed log file: %w", err)
+	}
+	return &gzipReadCloser{Reader: reader, file: file}, nil
+}
+
+// readLastLinesCompressed returns up to n last lines of the reader, read from the beginning
+func readLastLinesCompressed(reader io.Reader, n int) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		lines = appendWithRollingWindow(lines, scanner.Text(), n)
+	}
+	return lines, scanner.Err()
+}
+
+// getProjectUncompressedDays returns for how many days log files of the project stay uncompressed
+func (sf *ScriptFlow) getProjectUncompressedDays(project *core.Record) int {
+	days, _ := GetCollectionConfigAttr(project, "logsUncompressedDays", LogsUncompressedDays)
+	if daysInt, ok := days.(int); ok && daysInt >= 0 {
+		return daysInt
+	}
+	return LogsUncompressedDays
+}
+
+// JobCompressLogs compresses log files of finished runs once their day is older than the project's
+// logsUncompressedDays. Readers of the logs open the compressed files transparently.
+func (sf *ScriptFlow) JobCompressLogs() {
+	sf.logFilesMutex.Lock()
+	defer sf.logFilesMutex.Unlock()
+
+	projects, err := sf.getProjects()
+	if err != nil {
+		return
+	}
+
+	for _, project := range projects {
+		_, tasks, err := sf.getProjectRetentionDetails(project)
+		if err != nil {
+			continue
+		}
+		// the day of a directory starts at its date, so the day is over one day later
+		cutoff := time.Now().AddDate(0, 0, -sf.getProjectUncompressedDays(project)-1)
+
+		for _, task := range tasks {
+			compressed, err := sf.compressTaskLogs(task.Id, cutoff)
+			if err != nil {
+				sf.app.Logger().Error("failed to compress task logs", taskAttrs(task), slog.Any("error", err))
+			}
+			if compressed > 0 {
+				sf.app.Logger().Info("compressed task log files", taskAttrs(task), slog.Int("files", compressed))
+			}
+		}
+	}
+}
+
+// compressTaskLogs compresses log files in day directories of the task older than cutoff, as well as daily
+// log files of earlier versions, and returns the number of compressed files. Files of running runs are left.
+func (sf *ScriptFlow) compressTaskLogs(taskId string, cutoff time.Time) (int, error) {
+	logDir := sf.taskLogRootDir(taskId)
+	entries, err := os.ReadDir(logDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var files []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			if fileDate, err := sf.taskFileDate(entry.Name()); err == nil && fileDate.Before(cutoff) {
+				files = append(files, filepath.Join(logDir, entry.Name()))
+			}
+			continue
+		}
+		dirDate, err := logDirDate(entry.Name())
+		if err != nil || !dirDate.Before(cutoff) {
+			continue
+		}
+		dayDir := filepath.Join(logDir, entry.Name())
+		runFiles, err := os.ReadDir(dayDir)
+		if err != nil {
+			return 0, err
+		}
+		for _, runFile := range runFiles {
+			runId, isLog := strings.CutSuffix(runFile.Name(), ".log")
+			if runFile.IsDir() || !isLog || sf.isActiveRun(runId) {
+				continue
+			}
+			files = append(files, filepath.Join(dayDir, runFile.Name()))
+		}
+	}
+
+	compressed := 0
+	for _, filePath := range files {
+		err := compressLogFile(filePath)
+		// the temporary file of another pass, e.g. one interrupted by a crash, is left alone
+		if errors.Is(err, fs.ErrExist) {
+			sf.app.Logger().Warn("skip log file being compressed", slog.String("file", filePath))
+			continue
+		}
+		if err != nil {
+			return compressed, err
+		}
+		compressed++
+	}
+	return compressed, nil
+}
+
+// compressLogFile replaces the log file with its compressed version. The compressed file is written
+// under a temporary name first, so readers see either the plain or the complete compressed file.
+// The temporary file is created exclusively, a file left by another pass is not written over nor removed.
+func compressLogFile(filePath string) error {
+	src, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmpPath := filePath + CompressedLogExt + ".tmp"
+	dst, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	// removes the temporary file if it was not renamed
+	defer os.Remove(tmpPath)
+
+	writer := gzip.NewWriter(dst)
+	if _, err := io.Copy(writer, src); err != nil {
+		dst.Close()
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		dst.Close()
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, filePath+CompressedLogExt); err != nil {
+		return err
+	}
+	return os.Remove(filePath)
+}
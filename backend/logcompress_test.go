@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompressLogFile(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "run.log")
+	content := "[2025-01-01T00:00:00Z] [scriptflow] run run\nline 1\nline 2\n"
+	require.NoError(t, os.WriteFile(filePath, []byte(content), 0o644))
+
+	require.NoError(t, compressLogFile(filePath))
+	assert.NoFileExists(t, filePath)
+	assert.FileExists(t, filePath+CompressedLogExt)
+	assert.NoFileExists(t, filePath+CompressedLogExt+".tmp")
+
+	reader, err := openLogReader(filePath)
+	require.NoError(t, err)
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	assert.NoError(t, err)
+	assert.Equal(t, content, string(data), "compressed file is read transparently")
+
+	lines, err := readLastLinesOfFile(filePath, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"line 1", "line 2"}, lines)
+
+	logs, err := extractLogsForRun(filePath, "run")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"[2025-01-01T00:00:00Z] [scriptflow] run run", "line 1", "line 2"}, logs)
+
+	_, err = openLogReader(filepath.Join(t.TempDir(), "missing.log"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	// the temporary file of another pass is neither written over nor removed
+	otherPath := filepath.Join(t.TempDir(), "other.log")
+	require.NoError(t, os.WriteFile(otherPath, []byte(content), 0o644))
+	require.NoError(t, os.WriteFile(otherPath+CompressedLogExt+".tmp", []byte("partial"), 0o644))
+	assert.ErrorIs(t, compressLogFile(otherPath), os.ErrExist)
+	assert.FileExists(t, otherPath)
+	tmpData, err := os.ReadFile(otherPath + CompressedLogExt + ".tmp")
+	assert.NoError(t, err)
+	assert.Equal(t, "partial", string(tmpData))
+}
+
+func TestCompressTaskLogs(t *testing.T) {
+	sf := &ScriptFlow{logsDir: t.TempDir(), activeRuns: make(map[string]context.CancelFunc)}
+	now := time.Now()
+	oldDay := logDirName(now.AddDate(0, 0, -5))
+	today := logDirName(now)
+	files := []string{
+		filepath.Join(oldDay, "finished.log"),
+		filepath.Join(oldDay, "running.log"),
+		filepath.Join(today, "recent.log"),
+		oldDay + ".log", // daily file of earlier versions
+	}
+	for _, file := range files {
+		filePath := filepath.Join(sf.taskLogRootDir("task"), file)
+		require.NoError(t, os.MkdirAll(filepath.Dir(filePath), os.ModePerm))
+		require.NoError(t, os.WriteFile(filePath, []byte(fmt.Sprintln("output of", file)), 0o644))
+	}
+	sf.registerActiveRun("running", func() {})
+
+	compressed, err := sf.compressTaskLogs("task", now.AddDate(0, 0, -2))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, compressed)
+
+	root := sf.taskLogRootDir("task")
+	assert.FileExists(t, filepath.Join(root, oldDay, "finished.log"+CompressedLogExt))
+	assert.FileExists(t, filepath.Join(root, oldDay+".log"+CompressedLogExt))
+	assert.FileExists(t, filepath.Join(root, oldDay, "running.log"), "log of a running run is still written")
+	assert.FileExists(t, filepath.Join(root, today, "recent.log"))
+
+	compressed, err = sf.compressTaskLogs("missing", now)
+	assert.NoError(t, err)
+	assert.Zero(t, compressed)
+}
@@ -0,0 +1,436 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/gorilla/websocket"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// runLogBatchSize is the number of runs looked up at once when the task log is read across runs
+const runLogBatchSize = 100
+
+// {year}{month}{day}
+func logDirName(date time.Time) string {
+	return date.UTC().Format("20060102")
+}
+
+// logDirDate returns the date of the YYYYMMDD directory of run log files
+func logDirDate(dirName string) (time.Time, error) {
+	if len(dirName) != 8 {
+		return time.Time{}, NewInvalidLogFileNameError()
+	}
+	date, err := time.Parse("20060102", dirName)
+	if err != nil {
+		return time.Time{}, NewFailedParseDateFromLogFileNameError()
+	}
+	return date, nil
+}
+
+// {taskLogRootDir}/{YYYYMMDD}/{runId}.log, the date is the UTC day the run was created
+func (sf *ScriptFlow) runLogFilePath(taskId string, runId string, created time.Time) string {
+	return filepath.Join(
+		sf.taskLogRootDir(taskId),
+		logDirName(created),
+		runId+".log",
+	)
+}
+
+// runRecordLogFilePath returns the path of the log file of the run record
+func (sf *ScriptFlow) runRecordLogFilePath(run *core.Record) string {
+	return sf.runLogFilePath(run.GetString("task"), run.Id, run.GetDateTime("created").Time())
+}
+
+// createRunLogFile creates or opens for appending the log file of the run
+func (sf *ScriptFlow) createRunLogFile(run *core.Record) (*os.File, error) {
+	filePath := sf.runRecordLogFilePath(run)
+	if err := os.MkdirAll(filepath.Dir(filePath), os.ModePerm); err != nil {
+		return nil, NewFailedCreateLogFileDirectoryError()
+	}
+	return os.OpenFile(filePath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+}
+
+// readRunLog returns up to maxLogLines last lines of the run log, starting with the run mark.
+// Logs of runs not moved from the daily file of the task yet are looked up there.
+func (sf *ScriptFlow) readRunLog(run *core.Record) ([]string, error) {
+	file, err := openLogReader(sf.runRecordLogFilePath(run))
+	if errors.Is(err, fs.ErrNotExist) {
+		return extractLogsForRun(sf.taskLogFilePathDate(run.GetString("task"), run.GetDateTime("created").Time()), run.Id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log file: %w", err)
+	}
+	defer file.Close()
+
+	var logs []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		logs = appendWithRollingWindow(logs, scanner.Text(), maxLogLines)
+	}
+	return logs, scanner.Err()
+}
+
+// taskLogLines returns up to count last lines of the task log, which is the log files of its runs one after
+// another in the order the runs were created. Only runs since the oldest log file of the task are looked up,
+// runs which were not executed have no log file.
+func (sf *ScriptFlow) taskLogLines(taskId string, count int) ([]string, error) {
+	oldest, ok, err := sf.oldestTaskLogDate(taskId)
+	if err != nil || !ok {
+		return nil, err
+	}
+	filter := "task={:task} && created>={:from} && status!={:skipped} && status!={:missed}"
+	params := dbx.Params{
+		"task":    taskId,
+		"from":    oldest.UTC().Format(types.DefaultDateLayout),
+		"skipped": RunStatusSkipped,
+		"missed":  RunStatusMissed,
+	}
+
+	var lines []string
+	for page := 0; len(lines) < count; page++ {
+		runs, err := sf.app.FindRecordsByFilter(CollectionRuns, filter, "-created", runLogBatchSize, page*runLogBatchSize, params)
+		if err != nil {
+			return nil, err
+		}
+		for _, run := range runs {
+			runLines, err := sf.runLogLastLines(run, count-len(lines))
+			if err != nil {
+				return nil, err
+			}
+			lines = append(runLines, lines...)
+			if len(lines) >= count {
+				break
+			}
+		}
+		if len(runs) < runLogBatchSize {
+			break
+		}
+	}
+	return lines, nil
+}
+
+// oldestTaskLogDate returns the date of the oldest day directory or daily log file of the task,
+// ok is false if the task has no logs
+func (sf *ScriptFlow) oldestTaskLogDate(taskId string) (time.Time, bool, error) {
+	entries, err := os.ReadDir(sf.taskLogRootDir(taskId))
+	if errors.Is(err, fs.ErrNotExist) {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	var oldest time.Time
+	found := false
+	for _, entry := range entries {
+		var date time.Time
+		if entry.IsDir() {
+			date, err = logDirDate(entry.Name())
+		} else {
+			date, err = sf.taskFileDate(strings.TrimSuffix(entry.Name(), CompressedLogExt))
+		}
+		if err != nil {
+			continue
+		}
+		if !found || date.Before(oldest) {
+			oldest, found = date, true
+		}
+	}
+	return oldest, found, nil
+}
+
+// runLogLastLines returns up to n last lines of the run log. Logs of runs not moved from the daily file
+// of the task yet are looked up there, the same way as readRunLog does.
+func (sf *ScriptFlow) runLogLastLines(run *core.Record, n int) ([]string, error) {
+	lines, err := readLastLinesOfFile(sf.runRecordLogFilePath(run), n)
+	if err != nil || lines != nil {
+		return lines, err
+	}
+	logs, err := extractLogsForRun(sf.taskLogFilePathDate(run.GetString("task"), run.GetDateTime("created").Time()), run.Id)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(logs) > n {
+		logs = logs[len(logs)-n:]
+	}
+	return logs, nil
+}
+
+// readLastLinesOfFile returns up to n last lines of the file or of its compressed version,
+// a missing file has no lines
+func readLastLinesOfFile(filePath string, n int) ([]string, error) {
+	reader, err := openLogReader(filePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	// plain files are read backwards from the end, compressed ones from the beginning
+	if file, ok := reader.(*os.File); ok {
+		return readLastLines(file, n)
+	}
+	return readLastLinesCompressed(reader, n)
+}
+
+// taskLogLinesPage returns up to limit lines of the task log ending at (total - offset) from the end,
+// hasMore=true means there are older lines before the page
+func (sf *ScriptFlow) taskLogLinesPage(taskId string, offset, limit int) ([]string, bool, error) {
+	all, err := sf.taskLogLines(taskId, offset+limit+1)
+	if err != nil {
+		return nil, false, err
+	}
+	lines, hasMore := linesPage(all, offset, limit)
+	return lines, hasMore, nil
+}
+
+// migrateDailyLogs moves the output of runs from the daily log files of tasks, used by earlier versions,
+// to the log files of the runs. Daily files are removed once all their runs are moved.
+func (sf *ScriptFlow) migrateDailyLogs() {
+	sf.logFilesMutex.Lock()
+	defer sf.logFilesMutex.Unlock()
+
+	taskDirs, err := os.ReadDir(sf.logsDir)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			sf.app.Logger().Error("failed to read logs directory", slog.Any("error", err))
+		}
+		return
+	}
+	for _, taskDir := range taskDirs {
+		if !taskDir.IsDir() {
+			continue
+		}
+		if err := sf.migrateTaskDailyLogs(taskDir.Name()); err != nil {
+			sf.app.Logger().Error("failed to migrate daily log files", slog.String("taskId", taskDir.Name()), slog.Any("error", err))
+		}
+	}
+}
+
+// migrateTaskDailyLogs splits the daily log files of the task by the run marks into the log files of the runs.
+// Lines before the first run mark of a file are the tail of the last run of the previous file, e.g. of a run
+// which crossed midnight.
+func (sf *ScriptFlow) migrateTaskDailyLogs(taskId string) error {
+	rootDir := sf.taskLogRootDir(taskId)
+	entries, err := os.ReadDir(rootDir)
+	if err != nil {
+		return err
+	}
+	var dailyFiles []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			if _, err := sf.taskFileDate(entry.Name()); err == nil {
+				dailyFiles = append(dailyFiles, entry.Name())
+			}
+		}
+	}
+	if len(dailyFiles) == 0 {
+		return nil
+	}
+	// file names sort by date
+	slices.Sort(dailyFiles)
+
+	sf.app.Logger().Info("migrate daily log files to run log files", slog.String("taskId", taskId), slog.Int("files", len(dailyFiles)))
+	var runFile *os.File
+	defer func() {
+		if runFile != nil {
+			runFile.Close()
+		}
+	}()
+	for _, fileName := range dailyFiles {
+		fileDate, _ := sf.taskFileDate(fileName)
+		dailyPath := filepath.Join(rootDir, fileName)
+		err := forEachLine(dailyPath, func(line string) error {
+			if matches := logDelimiterRegex.FindStringSubmatch(line); matches != nil {
+				if runFile != nil {
+					runFile.Close()
+				}
+				var err error
+				runFile, err = sf.openMigratedRunLogFile(taskId, matches[1], fileDate)
+				if err != nil {
+					return err
+				}
+			}
+			// output of runs preceding the first run of the task is unknown
+			if runFile == nil {
+				return nil
+			}
+			_, err := runFile.WriteString(line + "\n")
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to migrate %s: %w", dailyPath, err)
+		}
+		if err := os.Remove(dailyPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// openMigratedRunLogFile creates the log file of the run, in the directory of the day the run was created,
+// or of the daily file if the run record was already removed. A file left by an interrupted migration is replaced.
+func (sf *ScriptFlow) openMigratedRunLogFile(taskId string, runId string, fileDate time.Time) (*os.File, error) {
+	created := fileDate
+	if run, err := sf.app.FindRecordById(CollectionRuns, runId); err == nil {
+		created = run.GetDateTime("created").Time()
+	}
+	filePath := sf.runLogFilePath(taskId, runId, created)
+	if err := os.MkdirAll(filepath.Dir(filePath), os.ModePerm); err != nil {
+		return nil, NewFailedCreateLogFileDirectoryError()
+	}
+	return os.Create(filePath)
+}
+
+// forEachLine calls fn for each line of the file until it returns an error
+func forEachLine(filePath string, fn func(line string) error) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			if fnErr := fn(strings.TrimSuffix(line, "\n")); fnErr != nil {
+				return fnErr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// watchTaskLogs sends lines appended to log files of the task runs, including the runs started later.
+// Log files of runs created since yesterday are watched, so that runs crossing midnight are followed.
+func watchTaskLogs(conn *websocket.Conn, rootDir string, logger *slog.Logger) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	// new day directories are watched once they are created
+	if err := os.MkdirAll(rootDir, os.ModePerm); err != nil {
+		return err
+	}
+	if err := watcher.Add(rootDir); err != nil {
+		return err
+	}
+
+	offsets := make(map[string]int64)
+	since := logDirName(time.Now().AddDate(0, 0, -1))
+	entries, err := os.ReadDir(rootDir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() || entry.Name() < since {
+			continue
+		}
+		files, err := watchLogDir(watcher, filepath.Join(rootDir, entry.Name()))
+		if err != nil {
+			return err
+		}
+		// the content up to now was sent with the last lines of the task log
+		for _, filePath := range files {
+			if info, err := os.Stat(filePath); err == nil {
+				offsets[filePath] = info.Size()
+			}
+		}
+	}
+	logger.Debug("watchTaskLogs", slog.String("dir", rootDir), slog.Int("files", len(offsets)))
+
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			info, err := os.Stat(event.Name)
+			if err != nil {
+				continue
+			}
+			if info.IsDir() {
+				if event.Has(fsnotify.Create) && filepath.Dir(event.Name) == rootDir {
+					files, err := watchLogDir(watcher, event.Name)
+					if err != nil {
+						return err
+					}
+					// files created along with the directory, before it was watched
+					for _, filePath := range files {
+						if offsets[filePath], err = streamFileLines(conn, filePath, 0); err != nil {
+							return err
+						}
+					}
+				}
+				continue
+			}
+			if !strings.HasSuffix(event.Name, ".log") || !event.Has(fsnotify.Create) && !event.Has(fsnotify.Write) {
+				continue
+			}
+			// files created after the watch started are sent from the beginning
+			offset, err := streamFileLines(conn, event.Name, offsets[event.Name])
+			if err != nil {
+				return err
+			}
+			offsets[event.Name] = offset
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			logger.Warn("watcher error", slog.Any("error", err))
+			return err
+		}
+	}
+}
+
+// watchLogDir adds the day directory to the watcher and returns paths of its log files
+func watchLogDir(watcher *fsnotify.Watcher, dir string) ([]string, error) {
+	if err := watcher.Add(dir); err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".log") {
+			files = append(files, filepath.Join(dir, entry.Name()))
+		}
+	}
+	return files, nil
+}
+
+// streamFileLines sends lines of the file from the offset and returns the offset of the next line to send
+func streamFileLines(conn *websocket.Conn, filePath string, offset int64) (int64, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return offset, err
+	}
+	defer file.Close()
+	return streamNewLines(file, conn, offset)
+}
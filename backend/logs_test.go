@@ -0,0 +1,68 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogDirDate(t *testing.T) {
+	date, err := logDirDate("20231201")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2023, 12, 1, 0, 0, 0, 0, time.UTC), date)
+
+	_, err = logDirDate("20231301")
+	assert.Equal(t, NewFailedParseDateFromLogFileNameError(), err)
+	_, err = logDirDate("20231201.log")
+	assert.Equal(t, NewInvalidLogFileNameError(), err)
+}
+
+func TestRunLogFilePath(t *testing.T) {
+	sf := &ScriptFlow{logsDir: "/logs"}
+	created := time.Date(2025, 1, 1, 23, 30, 0, 0, time.FixedZone("UTC-2", -2*60*60))
+	assert.Equal(t, "/logs/task/20250102/run.log", sf.runLogFilePath("task", "run", created), "day is in UTC")
+}
+
+func TestLinesPage(t *testing.T) {
+	all := []string{"L1", "L2", "L3", "L4", "L5"}
+
+	lines, hasMore := linesPage(all, 2, 2)
+	assert.Equal(t, []string{"L2", "L3"}, lines, "the first line is the sentinel of older lines")
+	assert.True(t, hasMore)
+
+	lines, hasMore = linesPage(all[3:], 0, 4)
+	assert.Equal(t, []string{"L4", "L5"}, lines)
+	assert.False(t, hasMore)
+
+	lines, hasMore = linesPage(all[3:], 2, 4)
+	assert.Empty(t, lines)
+	assert.False(t, hasMore)
+}
+
+func TestForEachLine(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "20250101.log")
+	assert.NoError(t, os.WriteFile(filePath, []byte("first\n\nlast without newline"), 0o644))
+
+	var lines []string
+	assert.NoError(t, forEachLine(filePath, func(line string) error {
+		lines = append(lines, line)
+		return nil
+	}))
+	assert.Equal(t, []string{"first", "", "last without newline"}, lines)
+}
+
+func TestReadLastLinesOfFile(t *testing.T) {
+	dir := t.TempDir()
+	lines, err := readLastLinesOfFile(filepath.Join(dir, "missing.log"), 10)
+	assert.NoError(t, err)
+	assert.Empty(t, lines, "runs which weren't executed have no log file")
+
+	filePath := filepath.Join(dir, "run.log")
+	assert.NoError(t, os.WriteFile(filePath, []byte("a\nb\nc\n"), 0o644))
+	lines, err = readLastLinesOfFile(filePath, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"b", "c"}, lines)
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+const (
+	LogSearchDefaultLimit  = 100
+	LogSearchMaxLimit      = 500
+	LogSearchMaxContext    = 10
+	logSearchMaxLineLength = 1 << 20 // longer lines fail the search of the run log
+	logSearchCheckInterval = 1000    // lines read between checks of the request cancellation
+)
+
+// logLinePattern matches "[<time>] [<stream>] <output>" output lines written by formatLogLine
+var logLinePattern = regexp.MustCompile(`^\[([^\]]*)\] \[(stdout|stderr)\] (.*)$`)
+
+// LogMatch is an output line of a run matching the log search query
+type LogMatch struct {
+	RunId     string   `json:"runId"`
+	TaskId    string   `json:"taskId"`
+	Line      int      `json:"line"` // line number in the run log, starting at 1
+	Timestamp string   `json:"timestamp"`
+	Stream    string   `json:"stream"`
+	Text      string   `json:"text"`
+	Before    []string `json:"before"` // context lines before the match
+	After     []string `json:"after"`  // context lines after the match
+}
+
+// LogSearchQuery is the query of the log search API
+type LogSearchQuery struct {
+	Match    func(text string) bool
+	Project  string
+	Task     string
+	Node     string
+	Statuses []string
+	From     time.Time
+	To       time.Time
+	Stream   string // stdout or stderr, empty means both
+	Context  int
+	Offset   int
+	Limit    int
+}
+
+// parseLogSearchQuery parses query params of the log search API. q is a substring, or a regular expression
+// with regex=true.
+func parseLogSearchQuery(q url.Values) (*LogSearchQuery, error) {
+	pattern := q.Get("q")
+	if pattern == "" {
+		return nil, fmt.Errorf("query is required")
+	}
+	query := &LogSearchQuery{
+		Project: q.Get("project"),
+		Task:    q.Get("task"),
+		Node:    q.Get("node"),
+		Stream:  q.Get("stream"),
+		Context: parseQueryInt(q.Get("context"), 0, 0, LogSearchMaxContext),
+		Offset:  parseQueryInt(q.Get("offset"), 0, 0, 0),
+		Limit:   parseQueryInt(q.Get("limit"), LogSearchDefaultLimit, 1, LogSearchMaxLimit),
+	}
+	if q.Get("regex") == "true" {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression: %w", err)
+		}
+		query.Match = re.MatchString
+	} else {
+		query.Match = func(text string) bool { return strings.Contains(text, pattern) }
+	}
+	if query.Stream != "" && query.Stream != "stdout" && query.Stream != "stderr" {
+		return nil, fmt.Errorf("invalid stream %q, stdout or stderr expected", query.Stream)
+	}
+	if status := q.Get("status"); status != "" {
+		query.Statuses = strings.Split(status, ",")
+	}
+	for name, value := range map[string]*time.Time{"from": &query.From, "to": &query.To} {
+		if v := q.Get(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s time, RFC3339 expected: %w", name, err)
+			}
+			*value = t
+		}
+	}
+	return query, nil
+}
+
+// runsFilter returns the filter of runs matching the query, runs without a task have no logs
+func (query *LogSearchQuery) runsFilter() (string, dbx.Params) {
+	filters := []string{"task != ''"}
+	params := dbx.Params{}
+	if query.Project != "" {
+		filters = append(filters, "task.project = {:project}")
+		params["project"] = query.Project
+	}
+	if query.Task != "" {
+		filters = append(filters, "task = {:task}")
+		params["task"] = query.Task
+	}
+	if query.Node != "" {
+		filters = append(filters, "node = {:node}")
+		params["node"] = query.Node
+	}
+	if len(query.Statuses) > 0 {
+		statusFilters := make([]string, len(query.Statuses))
+		for i, status := range query.Statuses {
+			key := fmt.Sprintf("status%d", i)
+			params[key] = status
+			statusFilters[i] = "status = {:" + key + "}"
+		}
+		filters = append(filters, "("+strings.Join(statusFilters, " || ")+")")
+	}
+	if !query.From.IsZero() {
+		filters = append(filters, "created >= {:from}")
+		params["from"] = query.From.UTC().Format(time.DateTime)
+	}
+	if !query.To.IsZero() {
+		filters = append(filters, "created <= {:to}")
+		params["to"] = query.To.UTC().Format(time.DateTime)
+	}
+	return strings.Join(filters, " && "), params
+}
+
+// searchLogLines calls emit for output lines of the reader matching the query, with up to contextLines lines
+// before and after each of them, until emit returns false. Run marks are not searched.
+func searchLogLines(ctx context.Context, reader io.Reader, query *LogSearchQuery, emit func(match LogMatch) bool) error {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), logSearchMaxLineLength)
+
+	var before []string
+	// matches waiting for their context lines after them
+	var pending []*LogMatch
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		if lineNumber%logSearchCheckInterval == 0 && ctx.Err() != nil {
+			return ctx.Err()
+		}
+		line := scanner.Text()
+
+		for len(pending) > 0 && len(pending[0].After) == query.Context {
+			if !emit(*pending[0]) {
+				return nil
+			}
+			pending = pending[1:]
+		}
+		for _, match := range pending {
+			match.After = append(match.After, line)
+		}
+
+		if parts := logLinePattern.FindStringSubmatch(line); parts != nil {
+			if (query.Stream == "" || query.Stream == parts[2]) && query.Match(parts[3]) {
+				pending = append(pending, &LogMatch{
+					Line:      lineNumber,
+					Timestamp: parts[1],
+					Stream:    parts[2],
+					Text:      parts[3],
+					Before:    append([]string{}, before...),
+					After:     []string{},
+				})
+			}
+		}
+
+		if query.Context > 0 {
+			before = appendWithRollingWindow(before, line, query.Context)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	for _, match := range pending {
+		if !emit(*match) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// searchLogs returns matches of the query in logs of the runs, newest runs first and lines of a run in order.
+// hasMore is true if there are matches after the page.
+func (sf *ScriptFlow) searchLogs(ctx context.Context, query *LogSearchQuery) ([]LogMatch, bool, error) {
+	filter, params := query.runsFilter()
+	matches := []LogMatch{}
+	skipped := 0
+	hasMore := false
+	emit := func(match LogMatch) bool {
+		if skipped < query.Offset {
+			skipped++
+			return true
+		}
+		if len(matches) == query.Limit {
+			hasMore = true
+			return false
+		}
+		matches = append(matches, match)
+		return true
+	}
+
+	for page := 0; !hasMore; page++ {
+		runs, err := sf.app.FindRecordsByFilter(CollectionRuns, filter, "-created", runLogBatchSize, page*runLogBatchSize, params)
+		if err != nil {
+			return nil, false, err
+		}
+		for _, run := range runs {
+			if err := ctx.Err(); err != nil {
+				return nil, false, err
+			}
+			err := sf.searchRunLog(ctx, run, query, emit)
+			if err != nil {
+				return nil, false, err
+			}
+			if hasMore {
+				break
+			}
+		}
+		if len(runs) < runLogBatchSize {
+			break
+		}
+	}
+	return matches, hasMore, nil
+}
+
+// searchRunLog calls emit for lines of the run log matching the query, runs which weren't executed have no log
+func (sf *ScriptFlow) searchRunLog(ctx context.Context, run *core.Record, query *LogSearchQuery, emit func(match LogMatch) bool) error {
+	reader, err := openLogReader(sf.runRecordLogFilePath(run))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	err = searchLogLines(ctx, reader, query, func(match LogMatch) bool {
+		match.RunId = run.Id
+		match.TaskId = run.GetString("task")
+		return emit(match)
+	})
+	if err != nil && ctx.Err() == nil {
+		return fmt.Errorf("failed to search log of run %s: %w", run.Id, err)
+	}
+	return err
+}
+
+// ApiLogSearch searches output lines of runs. Query params: q (substring, or regular expression with regex=true),
+// project, task, node, status (comma separated), from and to (RFC3339 times the runs were created), stream
+// (stdout or stderr), context (lines before and after a match, max 10), offset and limit (default 100, max 500).
+// The search stops when the client disconnects.
+func (sf *ScriptFlow) ApiLogSearch(e *core.RequestEvent) error {
+	query, err := parseLogSearchQuery(e.Request.URL.Query())
+	if err != nil {
+		return e.BadRequestError(err.Error(), nil)
+	}
+
+	ctx := e.Request.Context()
+	matches, hasMore, err := sf.searchLogs(ctx, query)
+	if ctx.Err() != nil {
+		// the client is gone, nobody reads the response
+		return nil
+	}
+	if err != nil {
+		return e.InternalServerError(err.Error(), nil)
+	}
+	return e.JSON(http.StatusOK, map[string]any{"matches": matches, "has_more": hasMore})
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const searchTestLog = `[2025-01-01T10:00:00Z] [scriptflow] run run1
+[2025-01-01T10:00:01Z] [stdout] connecting to db
+[2025-01-01T10:00:02Z] [stderr] error: connection refused
+[2025-01-01T10:00:03Z] [stdout] retrying
+[2025-01-01T10:00:04Z] [stderr] error: timeout
+[2025-01-01T10:00:05Z] [stdout] done
+`
+
+func searchTestLines(t *testing.T, params string) []LogMatch {
+	t.Helper()
+	values, err := url.ParseQuery(params)
+	require.NoError(t, err)
+	query, err := parseLogSearchQuery(values)
+	require.NoError(t, err)
+	var matches []LogMatch
+	err = searchLogLines(context.Background(), strings.NewReader(searchTestLog), query, func(match LogMatch) bool {
+		matches = append(matches, match)
+		return true
+	})
+	require.NoError(t, err)
+	return matches
+}
+
+func TestSearchLogLines(t *testing.T) {
+	matches := searchTestLines(t, "q=error")
+	if assert.Len(t, matches, 2) {
+		assert.Equal(t, LogMatch{Line: 3, Timestamp: "2025-01-01T10:00:02Z", Stream: "stderr", Text: "error: connection refused", Before: []string{}, After: []string{}}, matches[0])
+		assert.Equal(t, 5, matches[1].Line)
+	}
+
+	assert.Empty(t, searchTestLines(t, "q=scriptflow"), "run marks are not searched")
+	assert.Empty(t, searchTestLines(t, "q=error&stream=stdout"))
+	assert.Len(t, searchTestLines(t, "q=connect"), 2, "substring")
+
+	matches = searchTestLines(t, "q=^(retrying|done)$&regex=true")
+	if assert.Len(t, matches, 2) {
+		assert.Equal(t, "retrying", matches[0].Text)
+		assert.Equal(t, "done", matches[1].Text)
+	}
+
+	matches = searchTestLines(t, "q=error&context=1")
+	if assert.Len(t, matches, 2) {
+		assert.Equal(t, []string{"[2025-01-01T10:00:01Z] [stdout] connecting to db"}, matches[0].Before)
+		assert.Equal(t, []string{"[2025-01-01T10:00:03Z] [stdout] retrying"}, matches[0].After)
+		assert.Equal(t, []string{"[2025-01-01T10:00:05Z] [stdout] done"}, matches[1].After)
+	}
+
+	matches = searchTestLines(t, "q=done&context=3")
+	if assert.Len(t, matches, 1, "match at the end of the log") {
+		assert.Len(t, matches[0].Before, 3)
+		assert.Empty(t, matches[0].After)
+	}
+}
+
+func TestSearchLogLinesStops(t *testing.T) {
+	query, err := parseLogSearchQuery(url.Values{"q": {"error"}})
+	require.NoError(t, err)
+
+	calls := 0
+	err = searchLogLines(context.Background(), strings.NewReader(searchTestLog), query, func(LogMatch) bool {
+		calls++
+		return false
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, calls, "search stops when the page is full")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = searchLogLines(ctx, strings.NewReader(strings.Repeat(searchTestLog, logSearchCheckInterval)), query, func(LogMatch) bool { return true })
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestParseLogSearchQuery(t *testing.T) {
+	query, err := parseLogSearchQuery(url.Values{
+		"q": {"error"}, "status": {"error,timeout"}, "from": {"2025-01-01T00:00:00+02:00"}, "limit": {"1000"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"error", "timeout"}, query.Statuses)
+	assert.Equal(t, time.Date(2024, 12, 31, 22, 0, 0, 0, time.UTC), query.From.UTC())
+	assert.Equal(t, LogSearchDefaultLimit, query.Limit, "limit above the max uses default")
+
+	filter, params := query.runsFilter()
+	assert.Equal(t, "task != '' && (status = {:status0} || status = {:status1}) && created >= {:from}", filter)
+	assert.Equal(t, "2024-12-31 22:00:00", params["from"])
+
+	for _, values := range []url.Values{
+		{},
+		{"q": {"(unclosed"}, "regex": {"true"}},
+		{"q": {"error"}, "stream": {"stdin"}},
+		{"q": {"error"}, "to": {"yesterday"}},
+	} {
+		_, err := parseLogSearchQuery(values)
+		assert.Error(t, err, values.Encode())
+	}
+}
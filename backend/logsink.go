@@ -0,0 +1,130 @@
+package main
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// logFlushInterval bounds the delay of the output in the log file, the task log tail sees it about as late
+	logFlushInterval  = 500 * time.Millisecond
+	logSinkBufferSize = 64 * 1024
+)
+
+// logSink buffers lines written to the log file of a run and flushes them on an interval, so that chatty tasks
+// don't cost a disk sync per output line. A full buffer is written out right away, synced on the next flush.
+// Output callbacks of stdout and stderr may write concurrently, the file is written and synced without holding
+// the buffer, so that a slow disk doesn't block them.
+type logSink struct {
+	mutex  sync.Mutex // guards buffer, err and closed
+	buffer []byte
+	err    error // the first failed write, reported by the next writes
+	closed bool
+
+	flushMutex sync.Mutex // serializes writes to the file, so that lines keep their order
+	file       *os.File
+	spare      []byte // buffer swapped in on the next flush
+	dirty      bool   // written since the last sync
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// newLogSink starts flushing the buffered lines to the file every interval, until the sink is closed
+func newLogSink(file *os.File, interval time.Duration) *logSink {
+	sink := &logSink{
+		file:   file,
+		buffer: make([]byte, 0, logSinkBufferSize),
+		spare:  make([]byte, 0, logSinkBufferSize),
+		done:   make(chan struct{}),
+	}
+	sink.wg.Add(1)
+	go func() {
+		defer sink.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				// a failed flush is reported by the next write or by Close
+				_ = sink.Flush()
+			case <-sink.done:
+				return
+			}
+		}
+	}()
+	return sink
+}
+
+// WriteString buffers the line, a full buffer is written to the file
+func (s *logSink) WriteString(line string) (int, error) {
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		return 0, os.ErrClosed
+	}
+	if s.err != nil {
+		s.mutex.Unlock()
+		return 0, s.err
+	}
+	s.buffer = append(s.buffer, line...)
+	full := len(s.buffer) >= logSinkBufferSize
+	s.mutex.Unlock()
+
+	if full {
+		if err := s.flush(false); err != nil {
+			return 0, err
+		}
+	}
+	return len(line), nil
+}
+
+// Flush writes the buffered lines to the file and syncs it, if anything was written since the last flush
+func (s *logSink) Flush() error {
+	return s.flush(true)
+}
+
+// flush swaps the buffer out and writes it to the file, then syncs the file if sync is set
+func (s *logSink) flush(sync bool) error {
+	s.flushMutex.Lock()
+	defer s.flushMutex.Unlock()
+
+	s.mutex.Lock()
+	data := s.buffer
+	s.buffer = s.spare[:0]
+	s.mutex.Unlock()
+	s.spare = data
+
+	if len(data) > 0 {
+		s.dirty = true
+		if _, err := s.file.Write(data); err != nil {
+			s.mutex.Lock()
+			if s.err == nil {
+				s.err = err
+			}
+			s.mutex.Unlock()
+			return err
+		}
+	}
+	if !sync || !s.dirty {
+		return nil
+	}
+	s.dirty = false
+	return s.file.Sync()
+}
+
+// Close stops the interval flushes and flushes the rest of the output, the file stays open
+func (s *logSink) Close() error {
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.done)
+	s.mutex.Unlock()
+
+	s.wg.Wait()
+	return s.flush(true)
+}
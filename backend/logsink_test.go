@@ -0,0 +1,106 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func createTestLogFile(tb testing.TB) *os.File {
+	tb.Helper()
+	file, err := os.OpenFile(filepath.Join(tb.TempDir(), "run.log"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { file.Close() })
+	return file
+}
+
+func fileContent(t *testing.T, file *os.File) string {
+	t.Helper()
+	content, err := os.ReadFile(file.Name())
+	assert.NoError(t, err)
+	return string(content)
+}
+
+func TestLogSinkFlushesOnInterval(t *testing.T) {
+	file := createTestLogFile(t)
+	sink := newLogSink(file, 20*time.Millisecond)
+	defer sink.Close()
+
+	_, err := sink.WriteString("line 1\n")
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool { return fileContent(t, file) == "line 1\n" }, time.Second, 5*time.Millisecond,
+		"the tail sees the output without the end of the run")
+}
+
+func TestLogSinkClose(t *testing.T) {
+	file := createTestLogFile(t)
+	sink := newLogSink(file, time.Hour)
+
+	_, err := sink.WriteString("line 1\n")
+	assert.NoError(t, err)
+	_, err = sink.WriteString("line 2\n")
+	assert.NoError(t, err)
+	assert.Empty(t, fileContent(t, file), "lines are buffered until the flush")
+
+	assert.NoError(t, sink.Close())
+	assert.Equal(t, "line 1\nline 2\n", fileContent(t, file))
+	assert.NoError(t, sink.Close(), "closing twice is a no-op")
+
+	_, err = sink.WriteString("line 3\n")
+	assert.ErrorIs(t, err, os.ErrClosed)
+}
+
+func TestLogSinkWritesFullBuffer(t *testing.T) {
+	file := createTestLogFile(t)
+	sink := newLogSink(file, time.Hour)
+	defer sink.Close()
+
+	line := strings.Repeat("x", 1023) + "\n"
+	for range logSinkBufferSize / len(line) {
+		_, err := sink.WriteString(line)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, strings.Repeat(line, logSinkBufferSize/len(line)), fileContent(t, file),
+		"a full buffer is written without waiting for the flush")
+
+	_, err := sink.WriteString("last line\n")
+	assert.NoError(t, err)
+	assert.NoError(t, sink.Flush())
+	assert.True(t, strings.HasSuffix(fileContent(t, file), line+"last line\n"))
+}
+
+const benchmarkLogLine = "[2025-01-01T00:00:00Z] [stdout] processed item 12345 of 100000, elapsed 1.234s\n"
+
+// BenchmarkLogFileSyncPerLine is the baseline of writing the output with a sync after every line
+func BenchmarkLogFileSyncPerLine(b *testing.B) {
+	file := createTestLogFile(b)
+	b.SetBytes(int64(len(benchmarkLogLine)))
+	for b.Loop() {
+		if _, err := file.WriteString(benchmarkLogLine); err != nil {
+			b.Fatal(err)
+		}
+		if err := file.Sync(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkLogSink(b *testing.B) {
+	file := createTestLogFile(b)
+	sink := newLogSink(file, logFlushInterval)
+	b.SetBytes(int64(len(benchmarkLogLine)))
+	for b.Loop() {
+		if _, err := sink.WriteString(benchmarkLogLine); err != nil {
+			b.Fatal(err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		b.Fatal(err)
+	}
+}
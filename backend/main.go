@@ -210,18 +210,101 @@ func (sf *ScriptFlow) setupScheduler() {
 			sf.UpdateFromConfig()
 		}
 
+		// move run logs from daily log files of earlier versions to their own files, in background
+		// so that a large backlog doesn't delay the start, logs not moved yet are read from the daily files
+		go sf.migrateDailyLogs()
+
 		// mark all started tasks as interrupted, if any
 		sf.MarkAllRunningTasksAsInterrupted("app-started")
 
+		// record runs missed while the app was not running, before the tasks are scheduled again
+		sf.recordMissedRuns()
+
+		// schedule pending one-time runs, the ones which passed are recorded as missed
+		sf.schedulePendingRuns()
+
+		// the missed runs are recorded, the next start looks for them from now on
+		sf.JobHeartbeat()
+
 		// Schedule system tasks
 		sf.scheduleSystemTasks()
 
 		// Schedule existing tasks, each tasks will be scheduled in their own goroutine
 		sf.scheduleActiveTasks()
 
+		// Schedule active pipelines
+		sf.schedulePipelines()
+
 		// Start scheduler after all tasks are scheduled and PocketBase is fully ready
 		sf.scheduler.Start()
 
+		// Run @reboot tasks once the app is ready
+		sf.runRebootTasks()
+
+		return e.Next()
+	})
+
+	// Encrypt secret values before they are stored
+	sf.app.OnRecordCreate().BindFunc(func(e *core.RecordEvent) error {
+		if e.Record.Collection().Name == CollectionSecrets {
+			if err := encryptSecretValue(e.Record); err != nil {
+				return err
+			}
+		}
+		return e.Next()
+	})
+
+	sf.app.OnRecordUpdate().BindFunc(func(e *core.RecordEvent) error {
+		if e.Record.Collection().Name == CollectionSecrets {
+			if err := encryptSecretValue(e.Record); err != nil {
+				return err
+			}
+		}
+		return e.Next()
+	})
+
+	// Reject tasks with invalid schedule, webhook env, params or triggers, so that they can't be stored from the admin UI
+	sf.app.OnRecordValidate(CollectionTasks).BindFunc(func(e *core.RecordEvent) error {
+		if err := sf.validateTaskRecordSchedule(e.Record); err != nil {
+			return err
+		}
+		if err := validateTaskRecordWebhook(e.Record); err != nil {
+			return err
+		}
+		if err := validateTaskRecordParams(e.Record); err != nil {
+			return err
+		}
+		if err := sf.validateTaskRecordTriggers(e.Record); err != nil {
+			return err
+		}
+		return e.Next()
+	})
+
+	sf.app.OnRecordValidate(CollectionCalendars).BindFunc(func(e *core.RecordEvent) error {
+		if err := validateCalendarRecord(e.Record); err != nil {
+			return err
+		}
+		return e.Next()
+	})
+
+	sf.app.OnRecordValidate(CollectionScheduledRuns).BindFunc(func(e *core.RecordEvent) error {
+		if err := validateScheduledRunRecord(e.Record); err != nil {
+			return err
+		}
+		return e.Next()
+	})
+
+	sf.app.OnRecordValidate(CollectionPipelines).BindFunc(func(e *core.RecordEvent) error {
+		if err := sf.validatePipelineRecord(e.Record); err != nil {
+			return err
+		}
+		return e.Next()
+	})
+
+	sf.app.OnRecordDelete(CollectionPipelines).BindFunc(func(e *core.RecordEvent) error {
+		if err := deletePipelineRuns(e.App, e.Record.Id); err != nil {
+			return err
+		}
 		return e.Next()
 	})
 
@@ -230,9 +313,18 @@ func (sf *ScriptFlow) setupScheduler() {
 		if e.Record.Collection().Name == CollectionTasks {
 			go sf.ScheduleTask(e.Record)
 		}
-		// init notification for run
+		// init notification for run, runs recorded as skipped or missed trigger downstream tasks right away
 		if e.Record.Collection().Name == CollectionRuns {
 			go sf.ProcessRunNotification(e.Record)
+			go sf.ProcessRunTriggers(e.Record)
+		}
+		// Schedule pending one-time run
+		if e.Record.Collection().Name == CollectionScheduledRuns {
+			sf.scheduleScheduledRun(e.Record)
+		}
+		// Schedule new pipelines
+		if e.Record.Collection().Name == CollectionPipelines {
+			go sf.SchedulePipeline(e.Record)
 		}
 
 		return e.Next()
@@ -243,10 +335,15 @@ func (sf *ScriptFlow) setupScheduler() {
 		if e.Record.Collection().Name == CollectionTasks {
 			go sf.ScheduleTask(e.Record)
 		}
+		// Reschedule updated pipeline
+		if e.Record.Collection().Name == CollectionPipelines {
+			go sf.SchedulePipeline(e.Record)
+		}
 		// Handle run status changes
 		if e.Record.Collection().Name == CollectionRuns {
 			go sf.ProcessRunNotification(e.Record)
 			go sf.UpdateTaskFailureCount(e.Record)
+			go sf.ProcessRunTriggers(e.Record)
 		}
 		// Close node connection when node is updated, so that checkNodeStatus can attempt to reconnect with new params
 		if e.Record.Collection().Name == CollectionNodes {
@@ -273,6 +370,13 @@ func (sf *ScriptFlow) setupScheduler() {
 			// it can take a while to remove all task logs, so we will do it in background
 			go sf.RemoveTaskLogs(e.Record.Id)
 		}
+		// Remove job of cancelled or fired one-time run
+		if e.Record.Collection().Name == CollectionScheduledRuns {
+			sf.unscheduleScheduledRun(e.Record.Id)
+		}
+		if e.Record.Collection().Name == CollectionPipelines {
+			sf.UnschedulePipeline(e.Record.Id)
+		}
 		return e.Next()
 	})
 
@@ -292,6 +396,9 @@ func (sf *ScriptFlow) setupScheduler() {
 			sf.app.Logger().Info("marking all running tasks as interrupted")
 			sf.MarkAllRunningTasksAsInterrupted("app-terminated")
 
+			// fire times from now on are missed
+			sf.JobHeartbeat()
+
 			// Close all ssh connections thus terminate all running tasks, if any
 			// sf.app.Logger().Info("stoping SSH Pool")
 			// sf.sshPool.ClosePool()
@@ -307,9 +414,19 @@ func (sf *ScriptFlow) setupApi() {
 		e.Router.GET("/api/scriptflow/task/{taskId}/log-ws", sf.ApiTaskLogWebSocket)
 		e.Router.GET("/api/scriptflow/task/{taskId}/log", sf.ApiTaskLogLines).Bind(apis.RequireAuth())
 		e.Router.GET("/api/scriptflow/run/{runId}/log", sf.ApiRunLog).Bind(apis.RequireAuth())
+		e.Router.GET("/api/scriptflow/logs/search", sf.ApiLogSearch).Bind(apis.RequireAuth())
 		e.Router.POST("/api/scriptflow/task/{taskId}/run", sf.ApiRunTask).Bind(apis.RequireAuth())
+		e.Router.POST("/api/scriptflow/task/{taskId}/run-at", sf.ApiRunTaskAt).Bind(apis.RequireAuth())
+		e.Router.POST("/api/scriptflow/task/{taskId}/webhook", sf.ApiTaskWebhookToken).Bind(apis.RequireAuth())
+		e.Router.DELETE("/api/scriptflow/task/{taskId}/webhook", sf.ApiDeleteTaskWebhookToken).Bind(apis.RequireAuth())
+		// webhooks are authorized by the task webhook token instead of a user session
+		e.Router.POST("/api/scriptflow/webhook/{taskId}", sf.ApiTaskWebhook)
+		e.Router.POST("/api/scriptflow/webhook/{taskId}/{token}", sf.ApiTaskWebhook)
+		e.Router.POST("/api/scriptflow/pipeline/{pipelineId}/run", sf.ApiRunPipeline).Bind(apis.RequireAuth())
 		e.Router.POST("/api/scriptflow/run/{runId}/kill", sf.ApiKillRun).Bind(apis.RequireAuth())
 		e.Router.GET("/api/scriptflow/runs/latest", sf.ApiLatestRuns).Bind(apis.RequireAuth())
+		e.Router.GET("/api/scriptflow/node/{nodeId}/queue", sf.ApiNodeQueue).Bind(apis.RequireAuth())
+		e.Router.GET("/api/scriptflow/schedule/preview", sf.ApiSchedulePreview).Bind(apis.RequireAuth())
 		e.Router.GET("/api/scriptflow/stats", sf.ApiScriptFlowStats).Bind(apis.RequireAuth())
 		return e.Next()
 	})
@@ -0,0 +1,74 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		tasks, err := app.FindCollectionByNameOrId("tasks")
+		if err != nil {
+			return err
+		}
+
+		// Add timeout and timeout_grace fields, both hold Go duration strings (e.g. "30m")
+		tasks.Fields.Add(&core.TextField{
+			Name:     "timeout",
+			Required: false,
+		})
+		tasks.Fields.Add(&core.TextField{
+			Name:     "timeout_grace",
+			Required: false,
+		})
+		if err := app.Save(tasks); err != nil {
+			return err
+		}
+
+		runs, err := app.FindCollectionByNameOrId("runs")
+		if err != nil {
+			return err
+		}
+		addSelectValue(runs, "status", "timeout")
+		if err := app.Save(runs); err != nil {
+			return err
+		}
+
+		// Allow subscriptions to match killed and timed out runs
+		subscriptions, err := app.FindCollectionByNameOrId("subscriptions")
+		if err != nil {
+			return err
+		}
+		addSelectValue(subscriptions, "events", "killed")
+		addSelectValue(subscriptions, "events", "timeout")
+		return app.Save(subscriptions)
+	}, func(app core.App) error {
+		// Revert: remove timeout fields and "timeout" status
+		tasks, err := app.FindCollectionByNameOrId("tasks")
+		if err != nil {
+			return err
+		}
+		tasks.Fields.RemoveByName("timeout")
+		tasks.Fields.RemoveByName("timeout_grace")
+		if err := app.Save(tasks); err != nil {
+			return err
+		}
+
+		runs, err := app.FindCollectionByNameOrId("runs")
+		if err != nil {
+			return err
+		}
+		removeSelectValue(runs, "status", "timeout")
+		if err := app.Save(runs); err != nil {
+			return err
+		}
+
+		subscriptions, err := app.FindCollectionByNameOrId("subscriptions")
+		if err != nil {
+			return err
+		}
+		removeSelectValue(subscriptions, "events", "killed")
+		removeSelectValue(subscriptions, "events", "timeout")
+		return app.Save(subscriptions)
+	})
+}
@@ -0,0 +1,81 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		tasks, err := app.FindCollectionByNameOrId("tasks")
+		if err != nil {
+			return err
+		}
+
+		// Add retry policy fields: number of retries, delay (Go duration string) and backoff multiplier
+		tasks.Fields.Add(&core.NumberField{
+			Name:     "retries",
+			Min:      func() *float64 { v := 0.0; return &v }(),
+			OnlyInt:  true,
+			Required: false,
+		})
+		tasks.Fields.Add(&core.TextField{
+			Name:     "retry_delay",
+			Required: false,
+		})
+		tasks.Fields.Add(&core.NumberField{
+			Name:     "retry_backoff",
+			Min:      func() *float64 { v := 0.0; return &v }(),
+			Required: false,
+		})
+		if err := app.Save(tasks); err != nil {
+			return err
+		}
+
+		runs, err := app.FindCollectionByNameOrId("runs")
+		if err != nil {
+			return err
+		}
+
+		// Each attempt is its own run, linked to the first run of the chain
+		runs.Fields.Add(&core.NumberField{
+			Name:     "attempt",
+			Min:      func() *float64 { v := 0.0; return &v }(),
+			OnlyInt:  true,
+			Required: false,
+		})
+		runs.Fields.Add(&core.RelationField{
+			Name:          "parent_run",
+			CollectionId:  runs.Id,
+			CascadeDelete: false,
+			MaxSelect:     1,
+			Required:      false,
+		})
+		runs.Fields.Add(&core.BoolField{
+			Name:     "retrying",
+			Required: false,
+		})
+		return app.Save(runs)
+	}, func(app core.App) error {
+		// Revert: remove retry fields
+		tasks, err := app.FindCollectionByNameOrId("tasks")
+		if err != nil {
+			return err
+		}
+		tasks.Fields.RemoveByName("retries")
+		tasks.Fields.RemoveByName("retry_delay")
+		tasks.Fields.RemoveByName("retry_backoff")
+		if err := app.Save(tasks); err != nil {
+			return err
+		}
+
+		runs, err := app.FindCollectionByNameOrId("runs")
+		if err != nil {
+			return err
+		}
+		runs.Fields.RemoveByName("attempt")
+		runs.Fields.RemoveByName("parent_run")
+		runs.Fields.RemoveByName("retrying")
+		return app.Save(runs)
+	})
+}
@@ -0,0 +1,77 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		// Add env field (JSON map of environment variables) to tasks, projects and nodes
+		for _, name := range []string{"tasks", "projects", "nodes"} {
+			collection, err := app.FindCollectionByNameOrId(name)
+			if err != nil {
+				return err
+			}
+			collection.Fields.Add(&core.JSONField{
+				Name:     "env",
+				Required: false,
+			})
+			if err := app.Save(collection); err != nil {
+				return err
+			}
+		}
+
+		// Create secrets collection, values are encrypted by the app and hidden from the API
+		authRule := "@request.auth.id != \"\""
+		secrets := core.NewBaseCollection("secrets")
+		secrets.ListRule = &authRule
+		secrets.ViewRule = &authRule
+		secrets.CreateRule = &authRule
+		secrets.UpdateRule = &authRule
+		secrets.DeleteRule = &authRule
+		secrets.Fields.Add(&core.TextField{
+			Name:        "name",
+			Required:    true,
+			Presentable: true,
+			Pattern:     `^[a-zA-Z0-9_.-]+$`,
+		})
+		secrets.Fields.Add(&core.TextField{
+			Name:     "value",
+			Required: true,
+			Hidden:   true,
+		})
+		secrets.Fields.Add(&core.AutodateField{
+			Name:     "created",
+			OnCreate: true,
+		})
+		secrets.Fields.Add(&core.AutodateField{
+			Name:     "updated",
+			OnCreate: true,
+			OnUpdate: true,
+		})
+		secrets.AddIndex("idx_secrets_name", true, "name", "")
+		return app.Save(secrets)
+	}, func(app core.App) error {
+		// Revert: drop secrets collection and env fields
+		secrets, err := app.FindCollectionByNameOrId("secrets")
+		if err != nil {
+			return err
+		}
+		if err := app.Delete(secrets); err != nil {
+			return err
+		}
+
+		for _, name := range []string{"tasks", "projects", "nodes"} {
+			collection, err := app.FindCollectionByNameOrId(name)
+			if err != nil {
+				return err
+			}
+			collection.Fields.RemoveByName("env")
+			if err := app.Save(collection); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,44 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("tasks")
+		if err != nil {
+			return err
+		}
+
+		// Add working directory, shell (e.g. "bash -euo pipefail") and sudo user of the command
+		collection.Fields.Add(&core.TextField{
+			Name:     "workdir",
+			Required: false,
+		})
+		collection.Fields.Add(&core.TextField{
+			Name:     "shell",
+			Required: false,
+		})
+		collection.Fields.Add(&core.TextField{
+			Name:     "sudo_user",
+			Pattern:  `^[a-z_][a-z0-9_-]*\$?$`,
+			Required: false,
+		})
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		// Revert: remove command option fields
+		collection, err := app.FindCollectionByNameOrId("tasks")
+		if err != nil {
+			return err
+		}
+
+		collection.Fields.RemoveByName("workdir")
+		collection.Fields.RemoveByName("shell")
+		collection.Fields.RemoveByName("sudo_user")
+
+		return app.Save(collection)
+	})
+}
@@ -0,0 +1,81 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		tasks, err := app.FindCollectionByNameOrId("tasks")
+		if err != nil {
+			return err
+		}
+
+		// Add concurrency policy of overlapping triggers, empty value means "skip"
+		tasks.Fields.Add(&core.SelectField{
+			Name:      "concurrency_policy",
+			Values:    []string{"skip", "queue", "replace", "allow"},
+			MaxSelect: 1,
+			Required:  false,
+		})
+		tasks.Fields.Add(&core.NumberField{
+			Name:     "max_parallel",
+			Min:      func() *float64 { v := 0.0; return &v }(),
+			OnlyInt:  true,
+			Required: false,
+		})
+		if err := app.Save(tasks); err != nil {
+			return err
+		}
+
+		// Triggers which were not executed are recorded as skipped runs with the reason
+		runs, err := app.FindCollectionByNameOrId("runs")
+		if err != nil {
+			return err
+		}
+		addSelectValue(runs, "status", "skipped")
+		runs.Fields.Add(&core.TextField{
+			Name:     "reason",
+			Required: false,
+		})
+		if err := app.Save(runs); err != nil {
+			return err
+		}
+
+		subscriptions, err := app.FindCollectionByNameOrId("subscriptions")
+		if err != nil {
+			return err
+		}
+		addSelectValue(subscriptions, "events", "skipped")
+		return app.Save(subscriptions)
+	}, func(app core.App) error {
+		// Revert: remove concurrency fields and "skipped" status
+		tasks, err := app.FindCollectionByNameOrId("tasks")
+		if err != nil {
+			return err
+		}
+		tasks.Fields.RemoveByName("concurrency_policy")
+		tasks.Fields.RemoveByName("max_parallel")
+		if err := app.Save(tasks); err != nil {
+			return err
+		}
+
+		runs, err := app.FindCollectionByNameOrId("runs")
+		if err != nil {
+			return err
+		}
+		removeSelectValue(runs, "status", "skipped")
+		runs.Fields.RemoveByName("reason")
+		if err := app.Save(runs); err != nil {
+			return err
+		}
+
+		subscriptions, err := app.FindCollectionByNameOrId("subscriptions")
+		if err != nil {
+			return err
+		}
+		removeSelectValue(subscriptions, "events", "skipped")
+		return app.Save(subscriptions)
+	})
+}
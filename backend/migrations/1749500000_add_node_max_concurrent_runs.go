@@ -0,0 +1,51 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		nodes, err := app.FindCollectionByNameOrId("nodes")
+		if err != nil {
+			return err
+		}
+
+		// Limit of runs executed on the node at once, 0 means no limit
+		nodes.Fields.Add(&core.NumberField{
+			Name:     "max_concurrent_runs",
+			Min:      func() *float64 { v := 0.0; return &v }(),
+			OnlyInt:  true,
+			Required: false,
+		})
+		if err := app.Save(nodes); err != nil {
+			return err
+		}
+
+		// Runs above the limit wait in the node queue with "queued" status
+		runs, err := app.FindCollectionByNameOrId("runs")
+		if err != nil {
+			return err
+		}
+		addSelectValue(runs, "status", "queued")
+		return app.Save(runs)
+	}, func(app core.App) error {
+		// Revert: remove max_concurrent_runs field and "queued" status
+		nodes, err := app.FindCollectionByNameOrId("nodes")
+		if err != nil {
+			return err
+		}
+		nodes.Fields.RemoveByName("max_concurrent_runs")
+		if err := app.Save(nodes); err != nil {
+			return err
+		}
+
+		runs, err := app.FindCollectionByNameOrId("runs")
+		if err != nil {
+			return err
+		}
+		removeSelectValue(runs, "status", "queued")
+		return app.Save(runs)
+	})
+}
@@ -0,0 +1,108 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		nodes, err := app.FindCollectionByNameOrId("nodes")
+		if err != nil {
+			return err
+		}
+
+		// Labels group nodes, e.g. ["web", "eu"]
+		nodes.Fields.Add(&core.JSONField{
+			Name:     "labels",
+			Required: false,
+		})
+		if err := app.Save(nodes); err != nil {
+			return err
+		}
+
+		tasks, err := app.FindCollectionByNameOrId("tasks")
+		if err != nil {
+			return err
+		}
+
+		// A task runs either on its node or on the nodes matching its node selector
+		if node, ok := tasks.Fields.GetByName("node").(*core.RelationField); ok {
+			node.Required = false
+		}
+		tasks.Fields.Add(&core.TextField{
+			Name:     "node_selector",
+			Required: false,
+		})
+		tasks.Fields.Add(&core.SelectField{
+			Name:      "node_mode",
+			Values:    []string{"all", "any-one", "n-of"},
+			MaxSelect: 1,
+			Required:  false,
+		})
+		tasks.Fields.Add(&core.NumberField{
+			Name:     "node_count",
+			Min:      func() *float64 { v := 0.0; return &v }(),
+			OnlyInt:  true,
+			Required: false,
+		})
+		if err := app.Save(tasks); err != nil {
+			return err
+		}
+
+		runs, err := app.FindCollectionByNameOrId("runs")
+		if err != nil {
+			return err
+		}
+
+		// Child runs of a fan-out execution are linked to its parent run,
+		// every run records the node it was executed on
+		runs.Fields.Add(&core.RelationField{
+			Name:          "execution",
+			CollectionId:  runs.Id,
+			CascadeDelete: false,
+			MaxSelect:     1,
+			Required:      false,
+		})
+		runs.Fields.Add(&core.RelationField{
+			Name:          "node",
+			CollectionId:  nodes.Id,
+			CascadeDelete: false,
+			MaxSelect:     1,
+			Required:      false,
+		})
+		return app.Save(runs)
+	}, func(app core.App) error {
+		// Revert: remove labels and fan-out fields
+		nodes, err := app.FindCollectionByNameOrId("nodes")
+		if err != nil {
+			return err
+		}
+		nodes.Fields.RemoveByName("labels")
+		if err := app.Save(nodes); err != nil {
+			return err
+		}
+
+		tasks, err := app.FindCollectionByNameOrId("tasks")
+		if err != nil {
+			return err
+		}
+		if node, ok := tasks.Fields.GetByName("node").(*core.RelationField); ok {
+			node.Required = true
+		}
+		tasks.Fields.RemoveByName("node_selector")
+		tasks.Fields.RemoveByName("node_mode")
+		tasks.Fields.RemoveByName("node_count")
+		if err := app.Save(tasks); err != nil {
+			return err
+		}
+
+		runs, err := app.FindCollectionByNameOrId("runs")
+		if err != nil {
+			return err
+		}
+		runs.Fields.RemoveByName("execution")
+		runs.Fields.RemoveByName("node")
+		return app.Save(runs)
+	})
+}
@@ -0,0 +1,37 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		nodes, err := app.FindCollectionByNameOrId("nodes")
+		if err != nil {
+			return err
+		}
+		tasks, err := app.FindCollectionByNameOrId("tasks")
+		if err != nil {
+			return err
+		}
+
+		// Ordered list of nodes which run the task when its node is offline
+		tasks.Fields.Add(&core.RelationField{
+			Name:          "fallback_nodes",
+			CollectionId:  nodes.Id,
+			CascadeDelete: false,
+			MaxSelect:     99,
+			Required:      false,
+		})
+		return app.Save(tasks)
+	}, func(app core.App) error {
+		// Revert: remove fallback_nodes field
+		tasks, err := app.FindCollectionByNameOrId("tasks")
+		if err != nil {
+			return err
+		}
+		tasks.Fields.RemoveByName("fallback_nodes")
+		return app.Save(tasks)
+	})
+}
@@ -0,0 +1,68 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		tasks, err := app.FindCollectionByNameOrId("tasks")
+		if err != nil {
+			return err
+		}
+
+		// Whether runs missed while scriptflow was down are executed on startup, empty value means "none"
+		tasks.Fields.Add(&core.SelectField{
+			Name:      "catchup",
+			Values:    []string{"none", "latest", "all"},
+			MaxSelect: 1,
+			Required:  false,
+		})
+		if err := app.Save(tasks); err != nil {
+			return err
+		}
+
+		runs, err := app.FindCollectionByNameOrId("runs")
+		if err != nil {
+			return err
+		}
+		addSelectValue(runs, "status", "missed")
+		if err := app.Save(runs); err != nil {
+			return err
+		}
+
+		subscriptions, err := app.FindCollectionByNameOrId("subscriptions")
+		if err != nil {
+			return err
+		}
+		addSelectValue(subscriptions, "events", "missed")
+		return app.Save(subscriptions)
+	}, func(app core.App) error {
+		// Revert: remove catchup field and "missed" status
+		tasks, err := app.FindCollectionByNameOrId("tasks")
+		if err != nil {
+			return err
+		}
+		tasks.Fields.RemoveByName("catchup")
+		if err := app.Save(tasks); err != nil {
+			return err
+		}
+
+		runs, err := app.FindCollectionByNameOrId("runs")
+		if err != nil {
+			return err
+		}
+		removeSelectValue(runs, "status", "missed")
+		if err := app.Save(runs); err != nil {
+			return err
+		}
+
+		subscriptions, err := app.FindCollectionByNameOrId("subscriptions")
+		if err != nil {
+			return err
+		}
+		removeSelectValue(subscriptions, "events", "missed")
+		return app.Save(subscriptions)
+	})
+}
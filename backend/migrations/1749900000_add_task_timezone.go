@@ -0,0 +1,30 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		tasks, err := app.FindCollectionByNameOrId("tasks")
+		if err != nil {
+			return err
+		}
+
+		// IANA time zone of the cron schedule (e.g. "Europe/Berlin"), empty value means the project default
+		tasks.Fields.Add(&core.TextField{
+			Name:     "timezone",
+			Required: false,
+		})
+		return app.Save(tasks)
+	}, func(app core.App) error {
+		// Revert: remove timezone field
+		tasks, err := app.FindCollectionByNameOrId("tasks")
+		if err != nil {
+			return err
+		}
+		tasks.Fields.RemoveByName("timezone")
+		return app.Save(tasks)
+	})
+}
@@ -0,0 +1,92 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		// Create calendars collection, a calendar is a set of date ranges and weekly recurring windows
+		authRule := "@request.auth.id != \"\""
+		calendars := core.NewBaseCollection("calendars")
+		calendars.ListRule = &authRule
+		calendars.ViewRule = &authRule
+		calendars.CreateRule = &authRule
+		calendars.UpdateRule = &authRule
+		calendars.DeleteRule = &authRule
+		calendars.Fields.Add(&core.TextField{
+			Name:        "name",
+			Required:    true,
+			Presentable: true,
+		})
+		// IANA time zone of the windows and of ranges without offset, empty value means server local zone
+		calendars.Fields.Add(&core.TextField{
+			Name:     "timezone",
+			Required: false,
+		})
+		// [{"start": "2025-12-20", "end": "2026-01-05"}]
+		calendars.Fields.Add(&core.JSONField{
+			Name:     "ranges",
+			Required: false,
+		})
+		// [{"days": ["sun"], "start": "02:00", "end": "04:00"}]
+		calendars.Fields.Add(&core.JSONField{
+			Name:     "windows",
+			Required: false,
+		})
+		calendars.Fields.Add(&core.AutodateField{
+			Name:     "created",
+			OnCreate: true,
+		})
+		calendars.Fields.Add(&core.AutodateField{
+			Name:     "updated",
+			OnCreate: true,
+			OnUpdate: true,
+		})
+		calendars.AddIndex("idx_calendars_name", true, "name", "")
+		if err := app.Save(calendars); err != nil {
+			return err
+		}
+
+		// Projects and tasks don't run inside windows of exclude calendars and run only inside windows of include calendars
+		for _, name := range []string{"projects", "tasks"} {
+			collection, err := app.FindCollectionByNameOrId(name)
+			if err != nil {
+				return err
+			}
+			for _, fieldName := range []string{"exclude_calendars", "include_calendars"} {
+				collection.Fields.Add(&core.RelationField{
+					Name:          fieldName,
+					CollectionId:  calendars.Id,
+					CascadeDelete: false,
+					MaxSelect:     99,
+					Required:      false,
+				})
+			}
+			if err := app.Save(collection); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, func(app core.App) error {
+		// Revert: remove calendar fields and drop calendars collection
+		for _, name := range []string{"projects", "tasks"} {
+			collection, err := app.FindCollectionByNameOrId(name)
+			if err != nil {
+				return err
+			}
+			collection.Fields.RemoveByName("exclude_calendars")
+			collection.Fields.RemoveByName("include_calendars")
+			if err := app.Save(collection); err != nil {
+				return err
+			}
+		}
+
+		calendars, err := app.FindCollectionByNameOrId("calendars")
+		if err != nil {
+			return err
+		}
+		return app.Delete(calendars)
+	})
+}
@@ -0,0 +1,52 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		tasks, err := app.FindCollectionByNameOrId("tasks")
+		if err != nil {
+			return err
+		}
+
+		// Create scheduled_runs collection, pending one-time runs of tasks which are removed once they fire
+		authRule := "@request.auth.id != \"\""
+		scheduledRuns := core.NewBaseCollection("scheduled_runs")
+		scheduledRuns.ListRule = &authRule
+		scheduledRuns.ViewRule = &authRule
+		scheduledRuns.CreateRule = &authRule
+		scheduledRuns.DeleteRule = &authRule
+		scheduledRuns.Fields.Add(&core.RelationField{
+			Name:          "task",
+			CollectionId:  tasks.Id,
+			CascadeDelete: true,
+			MaxSelect:     1,
+			Required:      true,
+		})
+		scheduledRuns.Fields.Add(&core.DateField{
+			Name:     "at",
+			Required: true,
+		})
+		scheduledRuns.Fields.Add(&core.AutodateField{
+			Name:     "created",
+			OnCreate: true,
+		})
+		scheduledRuns.Fields.Add(&core.AutodateField{
+			Name:     "updated",
+			OnCreate: true,
+			OnUpdate: true,
+		})
+		scheduledRuns.AddIndex("idx_scheduled_runs_task", false, "task", "")
+		return app.Save(scheduledRuns)
+	}, func(app core.App) error {
+		// Revert: drop scheduled_runs collection
+		scheduledRuns, err := app.FindCollectionByNameOrId("scheduled_runs")
+		if err != nil {
+			return err
+		}
+		return app.Delete(scheduledRuns)
+	})
+}
@@ -0,0 +1,140 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		projects, err := app.FindCollectionByNameOrId("projects")
+		if err != nil {
+			return err
+		}
+		pipelines, err := app.FindCollectionByNameOrId("pipelines")
+		if err != nil {
+			return err
+		}
+
+		// Pipeline runs its tasks as steps, in order or as a DAG described by steps
+		authRule := "@request.auth.id != \"\""
+		pipelines.ListRule = &authRule
+		pipelines.ViewRule = &authRule
+		pipelines.CreateRule = &authRule
+		pipelines.UpdateRule = &authRule
+		pipelines.DeleteRule = &authRule
+		if tasks, ok := pipelines.Fields.GetByName("field").(*core.RelationField); ok {
+			tasks.Name = "tasks"
+			tasks.Required = true
+		}
+		pipelines.Fields.Add(&core.TextField{
+			Name:        "name",
+			Required:    true,
+			Presentable: true,
+		})
+		pipelines.Fields.Add(&core.RelationField{
+			Name:          "project",
+			CollectionId:  projects.Id,
+			CascadeDelete: false,
+			MaxSelect:     1,
+			Required:      true,
+		})
+		pipelines.Fields.Add(&core.BoolField{
+			Name: "active",
+		})
+		// empty schedule means the pipeline is run manually only
+		pipelines.Fields.Add(&core.TextField{
+			Name:     "schedule",
+			Required: false,
+		})
+		pipelines.Fields.Add(&core.TextField{
+			Name:     "timezone",
+			Required: false,
+		})
+		// [{"task": "<id>", "needs": ["<id>"], "when": "on_success"}], empty value means the tasks run in order
+		pipelines.Fields.Add(&core.JSONField{
+			Name:     "steps",
+			Required: false,
+		})
+		if err := app.Save(pipelines); err != nil {
+			return err
+		}
+
+		// Pipeline run has no task, its step runs are linked to it as runs of an execution
+		runs, err := app.FindCollectionByNameOrId("runs")
+		if err != nil {
+			return err
+		}
+		if task, ok := runs.Fields.GetByName("task").(*core.RelationField); ok {
+			task.Required = false
+		}
+		// step runs of tasks are linked to the pipeline too and stay with their tasks,
+		// pipeline runs are deleted along with the pipeline by the app
+		runs.Fields.Add(&core.RelationField{
+			Name:          "pipeline",
+			CollectionId:  pipelines.Id,
+			CascadeDelete: false,
+			MaxSelect:     1,
+			Required:      false,
+		})
+		runs.AddIndex("idx_runs_pipeline_created", false, "pipeline, created", "")
+		if err := app.Save(runs); err != nil {
+			return err
+		}
+
+		// Subscription covers either a task or a pipeline
+		subscriptions, err := app.FindCollectionByNameOrId("subscriptions")
+		if err != nil {
+			return err
+		}
+		if task, ok := subscriptions.Fields.GetByName("task").(*core.RelationField); ok {
+			task.Required = false
+		}
+		subscriptions.Fields.Add(&core.RelationField{
+			Name:          "pipeline",
+			CollectionId:  pipelines.Id,
+			CascadeDelete: true,
+			MaxSelect:     1,
+			Required:      false,
+		})
+		return app.Save(subscriptions)
+	}, func(app core.App) error {
+		// Revert: remove pipeline fields
+		subscriptions, err := app.FindCollectionByNameOrId("subscriptions")
+		if err != nil {
+			return err
+		}
+		subscriptions.Fields.RemoveByName("pipeline")
+		if err := app.Save(subscriptions); err != nil {
+			return err
+		}
+
+		runs, err := app.FindCollectionByNameOrId("runs")
+		if err != nil {
+			return err
+		}
+		runs.RemoveIndex("idx_runs_pipeline_created")
+		runs.Fields.RemoveByName("pipeline")
+		if err := app.Save(runs); err != nil {
+			return err
+		}
+
+		pipelines, err := app.FindCollectionByNameOrId("pipelines")
+		if err != nil {
+			return err
+		}
+		pipelines.ListRule = nil
+		pipelines.ViewRule = nil
+		pipelines.CreateRule = nil
+		pipelines.UpdateRule = nil
+		pipelines.DeleteRule = nil
+		if tasks, ok := pipelines.Fields.GetByName("tasks").(*core.RelationField); ok {
+			tasks.Name = "field"
+			tasks.Required = false
+		}
+		for _, name := range []string{"name", "project", "active", "schedule", "timezone", "steps"} {
+			pipelines.Fields.RemoveByName(name)
+		}
+		return app.Save(pipelines)
+	})
+}
@@ -0,0 +1,70 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		tasks, err := app.FindCollectionByNameOrId("tasks")
+		if err != nil {
+			return err
+		}
+
+		// Token of the inbound webhook of the task, empty value disables the webhook
+		tasks.Fields.Add(&core.TextField{
+			Name:     "webhook_token",
+			Required: false,
+			Hidden:   true,
+		})
+		// Webhook requests must be signed with HMAC-SHA256 of the token instead of passing it in the URL
+		tasks.Fields.Add(&core.BoolField{
+			Name: "webhook_signed",
+		})
+		// {"<ENV_NAME>": "<payload field path>"}, fields of the JSON payload passed to the run as env variables
+		tasks.Fields.Add(&core.JSONField{
+			Name:     "webhook_env",
+			Required: false,
+		})
+		if err := app.Save(tasks); err != nil {
+			return err
+		}
+
+		runs, err := app.FindCollectionByNameOrId("runs")
+		if err != nil {
+			return err
+		}
+		// What started the run, e.g. "webhook", empty value means the schedule
+		runs.Fields.Add(&core.TextField{
+			Name:     "trigger",
+			Required: false,
+		})
+		// Env variables passed by the trigger, they override env of the task
+		runs.Fields.Add(&core.JSONField{
+			Name:     "env",
+			Required: false,
+		})
+		return app.Save(runs)
+	}, func(app core.App) error {
+		// Revert: remove webhook fields
+		runs, err := app.FindCollectionByNameOrId("runs")
+		if err != nil {
+			return err
+		}
+		runs.Fields.RemoveByName("trigger")
+		runs.Fields.RemoveByName("env")
+		if err := app.Save(runs); err != nil {
+			return err
+		}
+
+		tasks, err := app.FindCollectionByNameOrId("tasks")
+		if err != nil {
+			return err
+		}
+		tasks.Fields.RemoveByName("webhook_token")
+		tasks.Fields.RemoveByName("webhook_signed")
+		tasks.Fields.RemoveByName("webhook_env")
+		return app.Save(tasks)
+	})
+}
@@ -0,0 +1,49 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		tasks, err := app.FindCollectionByNameOrId("tasks")
+		if err != nil {
+			return err
+		}
+
+		// [{"name": "<ENV_NAME>", "type": "string", "default": "", "allowed": [], "required": false}],
+		// parameters accepted by manual runs of the task
+		tasks.Fields.Add(&core.JSONField{
+			Name:     "params",
+			Required: false,
+		})
+		if err := app.Save(tasks); err != nil {
+			return err
+		}
+
+		runs, err := app.FindCollectionByNameOrId("runs")
+		if err != nil {
+			return err
+		}
+		// Resolved parameter values the run was started with
+		runs.Fields.Add(&core.JSONField{
+			Name:     "params",
+			Required: false,
+		})
+		return app.Save(runs)
+	}, func(app core.App) error {
+		// Revert: remove params fields
+		for _, name := range []string{"runs", "tasks"} {
+			collection, err := app.FindCollectionByNameOrId(name)
+			if err != nil {
+				return err
+			}
+			collection.Fields.RemoveByName("params")
+			if err := app.Save(collection); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,72 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		tasks, err := app.FindCollectionByNameOrId("tasks")
+		if err != nil {
+			return err
+		}
+
+		// The task runs when a run of any of these tasks finishes with one of on_run_statuses
+		tasks.Fields.Add(&core.RelationField{
+			Name:          "on_run_of",
+			CollectionId:  tasks.Id,
+			CascadeDelete: false,
+			MaxSelect:     99,
+			Required:      false,
+		})
+		// Statuses of upstream runs which trigger the task, empty value means "completed"
+		tasks.Fields.Add(&core.SelectField{
+			Name:      "on_run_statuses",
+			Values:    []string{"completed", "error", "internal_error", "timeout", "killed", "interrupted", "skipped", "missed"},
+			MaxSelect: 8,
+			Required:  false,
+		})
+		// Absolute path on the task node, the task runs when the file appears or changes
+		tasks.Fields.Add(&core.TextField{
+			Name:     "on_remote_file",
+			Required: false,
+		})
+		if err := app.Save(tasks); err != nil {
+			return err
+		}
+
+		runs, err := app.FindCollectionByNameOrId("runs")
+		if err != nil {
+			return err
+		}
+		// Upstream run which triggered the run by the on_run_of trigger
+		runs.Fields.Add(&core.RelationField{
+			Name:          "source_run",
+			CollectionId:  runs.Id,
+			CascadeDelete: false,
+			MaxSelect:     1,
+			Required:      false,
+		})
+		return app.Save(runs)
+	}, func(app core.App) error {
+		// Revert: remove trigger fields
+		runs, err := app.FindCollectionByNameOrId("runs")
+		if err != nil {
+			return err
+		}
+		runs.Fields.RemoveByName("source_run")
+		if err := app.Save(runs); err != nil {
+			return err
+		}
+
+		tasks, err := app.FindCollectionByNameOrId("tasks")
+		if err != nil {
+			return err
+		}
+		tasks.Fields.RemoveByName("on_run_of")
+		tasks.Fields.RemoveByName("on_run_statuses")
+		tasks.Fields.RemoveByName("on_remote_file")
+		return app.Save(tasks)
+	})
+}
@@ -0,0 +1,68 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+func init() {
+	m.Register(func(app core.App) error {
+		tasks, err := app.FindCollectionByNameOrId("tasks")
+		if err != nil {
+			return err
+		}
+
+		// Max size of the output of a run written to its log, 0 means the project default
+		tasks.Fields.Add(&core.NumberField{
+			Name:     "max_output_bytes",
+			Min:      func() *float64 { v := 0.0; return &v }(),
+			OnlyInt:  true,
+			Required: false,
+		})
+		// Max number of output lines of a run written to its log, 0 means the project default
+		tasks.Fields.Add(&core.NumberField{
+			Name:     "max_output_lines",
+			Min:      func() *float64 { v := 0.0; return &v }(),
+			OnlyInt:  true,
+			Required: false,
+		})
+		// Kill the run when its output exceeds a limit, instead of only dropping the rest of the output
+		tasks.Fields.Add(&core.BoolField{
+			Name:     "output_limit_kill",
+			Required: false,
+		})
+		if err := app.Save(tasks); err != nil {
+			return err
+		}
+
+		runs, err := app.FindCollectionByNameOrId("runs")
+		if err != nil {
+			return err
+		}
+		// The output of the run exceeded a limit and the rest of it is not in the log
+		runs.Fields.Add(&core.BoolField{
+			Name:     "truncated",
+			Required: false,
+		})
+		return app.Save(runs)
+	}, func(app core.App) error {
+		// Revert: remove output limit fields
+		runs, err := app.FindCollectionByNameOrId("runs")
+		if err != nil {
+			return err
+		}
+		runs.Fields.RemoveByName("truncated")
+		if err := app.Save(runs); err != nil {
+			return err
+		}
+
+		tasks, err := app.FindCollectionByNameOrId("tasks")
+		if err != nil {
+			return err
+		}
+		tasks.Fields.RemoveByName("max_output_bytes")
+		tasks.Fields.RemoveByName("max_output_lines")
+		tasks.Fields.RemoveByName("output_limit_kill")
+		return app.Save(tasks)
+	})
+}
@@ -0,0 +1,32 @@
+package migrations
+
+import (
+	"slices"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// addSelectValue appends value to the select field of the collection if it is not there yet.
+// For multi-select fields maxSelect is raised so that every value can be selected.
+func addSelectValue(collection *core.Collection, fieldName string, value string) {
+	selectField, ok := collection.Fields.GetByName(fieldName).(*core.SelectField)
+	if !ok || slices.Contains(selectField.Values, value) {
+		return
+	}
+	selectField.Values = append(selectField.Values, value)
+	if selectField.MaxSelect > 1 {
+		selectField.MaxSelect = len(selectField.Values)
+	}
+}
+
+// removeSelectValue removes value from the select field of the collection
+func removeSelectValue(collection *core.Collection, fieldName string, value string) {
+	selectField, ok := collection.Fields.GetByName(fieldName).(*core.SelectField)
+	if !ok {
+		return
+	}
+	selectField.Values = slices.DeleteFunc(selectField.Values, func(v string) bool { return v == value })
+	if selectField.MaxSelect > len(selectField.Values) {
+		selectField.MaxSelect = len(selectField.Values)
+	}
+}
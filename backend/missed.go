@@ -0,0 +1,150 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// validateCatchupPolicy checks catch-up policy of the task
+func validateCatchupPolicy(policy string) error {
+	if policy != "" && !slices.Contains(CatchupPolicies, policy) {
+		return fmt.Errorf("invalid catchup policy: %q", policy)
+	}
+	return nil
+}
+
+// recordMissedRuns records fire times of active cron tasks which passed since their last run,
+// while scriptflow was not running, as missed runs. The tasks are run according to their catch-up policy.
+func (sf *ScriptFlow) recordMissedRuns() {
+	tasks, err := sf.app.FindAllRecords(CollectionTasks, dbx.HashExp{"active": true})
+	if err != nil {
+		sf.app.Logger().Error("failed to query tasks collection", slog.Any("error", err))
+		return
+	}
+
+	now := time.Now()
+	heartbeat := sf.lastHeartbeat()
+	for _, task := range tasks {
+		sf.recordTaskMissedRuns(task, heartbeat, now)
+	}
+}
+
+// missedRunsSince returns the time after which fire times of the task were missed. Fire times before its last
+// run, its last change, e.g. activation or a new schedule, or the last heartbeat of scriptflow weren't missed.
+// The heartbeat is zero if it is unknown.
+func missedRunsSince(lastRun time.Time, updated time.Time, heartbeat time.Time) time.Time {
+	since := lastRun
+	for _, t := range []time.Time{updated, heartbeat} {
+		if t.After(since) {
+			since = t
+		}
+	}
+	return since
+}
+
+// recordTaskMissedRuns records missed runs of the task up to now and starts catch-up runs
+func (sf *ScriptFlow) recordTaskMissedRuns(task *core.Record, heartbeat time.Time, now time.Time) {
+	schedule := task.GetString("schedule")
+	if at, isAt, err := parseAtSchedule(schedule); isAt {
+		if err == nil {
+			sf.recordOneTimeTaskMissedRun(task, at, now)
+		}
+		return
+	}
+	// @every and @reboot tasks have no fixed fire times to miss
+	if strings.HasPrefix(schedule, "@every ") || schedule == RebootSchedule {
+		return
+	}
+	crontab, _, err := scheduleCrontab(schedule, task.Id, sf.taskTimezone(task))
+	if err != nil {
+		// invalid schedule is reported when the task is scheduled
+		return
+	}
+
+	lastRuns, err := sf.app.FindRecordsByFilter(CollectionRuns, "task={:task}", "-created", 1, 0, dbx.Params{"task": task.Id})
+	if err != nil {
+		sf.app.Logger().Error("failed to find last run", taskAttrs(task), slog.Any("error", err))
+		return
+	}
+	// without a run there is nothing to compare with
+	if len(lastRuns) == 0 {
+		return
+	}
+	since := missedRunsSince(lastRuns[0].GetDateTime("created").Time(), task.GetDateTime("updated").Time(), heartbeat).In(time.Local)
+
+	missed, total, err := cronFireTimes(crontab, since, now, MissedRunsMaxCount)
+	if err != nil || total == 0 {
+		return
+	}
+	if total >= cronFireTimesMaxCount {
+		sf.app.Logger().Warn("too many missed runs, only the first are counted", taskAttrs(task), slog.Int("counted", total), slog.Int("recorded", len(missed)))
+	} else if total > len(missed) {
+		sf.app.Logger().Warn("too many missed runs, only the latest are recorded", taskAttrs(task), slog.Int("missed", total), slog.Int("recorded", len(missed)))
+	}
+
+	policy := task.GetString("catchup")
+	sf.app.Logger().Info("task missed runs", taskAttrs(task), slog.Int("missed", total), slog.String("catchup", policy))
+	for _, fireTime := range missed {
+		sf.recordMissedRun(task, fireTime)
+	}
+
+	switch policy {
+	case CatchupLatest:
+		go sf.runTask(task.Id)
+	case CatchupAll:
+		// one after another, so that they don't skip each other
+		go func() {
+			for range missed {
+				if sf.ctx.Err() != nil {
+					return
+				}
+				sf.runTask(task.Id)
+			}
+		}()
+	}
+}
+
+func (sf *ScriptFlow) heartbeatFilePath() string {
+	return filepath.Join(sf.app.DataDir(), HeartbeatFileName)
+}
+
+// lastHeartbeat returns the last time scriptflow was known to be running, zero if it is unknown
+func (sf *ScriptFlow) lastHeartbeat() time.Time {
+	data, err := os.ReadFile(sf.heartbeatFilePath())
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			sf.app.Logger().Error("failed to read heartbeat file", slog.Any("error", err))
+		}
+		return time.Time{}
+	}
+	heartbeat, err := time.Parse(time.RFC3339, strings.TrimSpace(string(data)))
+	if err != nil {
+		sf.app.Logger().Warn("invalid heartbeat file", slog.Any("error", err))
+		return time.Time{}
+	}
+	return heartbeat
+}
+
+// JobHeartbeat records the time scriptflow is running, so that on the next start only fire times after
+// it are counted as missed. The file is replaced at once, so it is never read half written.
+func (sf *ScriptFlow) JobHeartbeat() {
+	filePath := sf.heartbeatFilePath()
+	tmpPath := filePath + ".tmp"
+	if err := os.WriteFile(tmpPath, []byte(time.Now().UTC().Format(time.RFC3339)), 0o644); err != nil {
+		sf.app.Logger().Error("failed to write heartbeat file", slog.Any("error", err))
+		return
+	}
+	if err := os.Rename(tmpPath, filePath); err != nil {
+		sf.app.Logger().Error("failed to write heartbeat file", slog.Any("error", err))
+	}
+}
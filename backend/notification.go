@@ -17,10 +17,24 @@ import (
 
 // on run create/update checks notification configs and creates notification row if needed
 func (sf *ScriptFlow) ProcessRunNotification(run *core.Record) {
+	// react only to the final attempt of the run, a retry that later succeeds must not alert
+	if run.GetBool("retrying") {
+		return
+	}
+	// runs of a fan-out execution are reported by the execution itself
+	if isExecutionChild(run) {
+		return
+	}
+	// "started" is already notified by the first attempt
+	if run.GetString("status") == RunStatusStarted && run.GetInt("attempt") > 1 {
+		return
+	}
+
 	runItem := &RunItem{
-		Id:     run.GetString("id"),
-		Task:   run.GetString("task"),
-		Status: run.GetString("status"),
+		Id:       run.GetString("id"),
+		Task:     run.GetString("task"),
+		Pipeline: run.GetString("pipeline"),
+		Status:   run.GetString("status"),
 	}
 	subscriptions, err := retrieveSubscriptionsForRun(sf.app.DB(), runItem)
 	if err != nil {
@@ -80,24 +94,32 @@ func (sf *ScriptFlow) createNotification(subscription *SubscriptionItem, run *co
 	}
 }
 
-// Select {threshold} most recent runs newer than {subscription.notified}
+// Select {threshold} most recent final runs newer than {subscription.notified}
 // return count of runs with status in {subscription.events}
 func retrieveConsecutiveRunsCount(db dbx.Builder, subscription SubscriptionItem) (int, error) {
 	// SELECT id FROM runs
-	// WHERE task='{taskId}' AND created > '{notified}'
+	// WHERE task='{taskId}' AND retrying=FALSE AND (execution='' OR pipeline!='') AND created > '{notified}'
 	// ORDER BY `created` DESC
 	// LIMIT {threshold}
+	// runs of a pipeline subscription are the pipeline runs, which have no task
+	runs := dbx.And(
+		dbx.HashExp{"task": subscription.Task, "retrying": false},
+		dbx.Or(dbx.HashExp{"execution": ""}, dbx.Not(dbx.HashExp{"pipeline": ""})),
+	)
+	if subscription.Pipeline != "" {
+		runs = dbx.HashExp{"pipeline": subscription.Pipeline, "task": "", "retrying": false}
+	}
 	query := db.Select("status").
 		From(CollectionRuns).
 		Where(dbx.And(
-			dbx.HashExp{"task": subscription.Task},
+			runs,
 			dbx.NewExp("created > {:created}", dbx.Params{"created": subscription.Notified}),
 		)).
 		OrderBy("created DESC").
 		Limit(int64(subscription.Threshold))
 
-	runs := []RunItem{}
-	err := query.All(&runs)
+	items := []RunItem{}
+	err := query.All(&items)
 	if err != nil {
 		return 0, err
 	}
@@ -109,7 +131,7 @@ func retrieveConsecutiveRunsCount(db dbx.Builder, subscription SubscriptionItem)
 	}
 
 	cnt := 0
-	for _, run := range runs {
+	for _, run := range items {
 		if _, exists := eventSet[run.Status]; exists {
 			cnt++
 		}
@@ -124,13 +146,15 @@ func retrieveSubscriptionsForRun(db dbx.Builder, run *RunItem) ([]SubscriptionIt
 	// FROM subscriptions
 	// JOIN json_each(subscriptions.events) AS je ON je.value = 'error'
 	// WHERE task = '{task}';
+	// pipeline runs have no task, they are matched by the pipeline instead
+	where := dbx.HashExp{"active": true, "task": run.Task}
+	if run.Task == "" {
+		where = dbx.HashExp{"active": true, "pipeline": run.Pipeline}
+	}
 	query := db.Select("subscriptions.*").
 		From(CollectionSubscriptions).
 		Join("JOIN", "json_each(subscriptions.events) AS je", dbx.HashExp{"je.value": run.Status}).
-		Where(dbx.HashExp{
-			"active": true,
-			"task":   run.Task,
-		})
+		Where(where)
 
 	// Execute the query and fetch the results
 	var subscriptions []SubscriptionItem
@@ -204,6 +228,9 @@ func (sf *ScriptFlow) sendEmailNotification(subject string, message string, chan
 }
 
 func (sf *ScriptFlow) buildMessageContext(nc NotificationContext) MessageContext {
+	if nc.Pipeline != nil {
+		return sf.buildPipelineMessageContext(nc)
+	}
 	taskUrl := fmt.Sprintf(
 		"%s/#/project/%s/task/%s/history",
 		sf.app.Settings().Meta.AppURL,
@@ -227,20 +254,53 @@ func (sf *ScriptFlow) buildMessageContext(nc NotificationContext) MessageContext
 			nc.Run.GetString("status"),
 		),
 		Item: MessageItem{
-			Command:  nc.Run.GetString("command"),
+			Command:  maskSecrets(nc.Run.GetString("command"), sf.taskSecrets(nc.Task)),
 			Host:     nc.Run.GetString("host"),
 			Status:   nc.Run.GetString("status"),
-			Error:    nc.Run.GetString("connection_error"),
+			Error:    runError(nc.Run),
 			ExitCode: fmt.Sprintf("%d", nc.Run.GetInt("exit_code")),
 			Created:  nc.Run.GetDateTime("created").String(),
 			Updated:  nc.Run.GetDateTime("updated").String(),
 		},
+		Kind:     "Task",
 		TaskUrl:  taskUrl,
 		TaskName: nc.Task.GetString("name"),
 		RunUrl:   runUrl,
 	}
 }
 
+// buildPipelineMessageContext returns message context of a pipeline run, the run has no command and its
+// steps are listed in the project
+func (sf *ScriptFlow) buildPipelineMessageContext(nc NotificationContext) MessageContext {
+	projectUrl := fmt.Sprintf(
+		"%s/#/project/%s",
+		sf.app.Settings().Meta.AppURL,
+		nc.Project.GetString("id"),
+	)
+
+	return MessageContext{
+		Header: sf.app.Settings().Meta.AppName,
+		Subject: fmt.Sprintf(
+			"[%s] <%s> %s",
+			sf.app.Settings().Meta.AppName,
+			nc.Subscription.GetString("name"),
+			nc.Run.GetString("status"),
+		),
+		Item: MessageItem{
+			Host:     nc.Run.GetString("host"),
+			Status:   nc.Run.GetString("status"),
+			Error:    runError(nc.Run),
+			ExitCode: fmt.Sprintf("%d", nc.Run.GetInt("exit_code")),
+			Created:  nc.Run.GetDateTime("created").String(),
+			Updated:  nc.Run.GetDateTime("updated").String(),
+		},
+		Kind:     "Pipeline",
+		TaskUrl:  projectUrl,
+		TaskName: nc.Pipeline.GetString("name"),
+		RunUrl:   projectUrl,
+	}
+}
+
 //go:embed templates/*
 var embeddedTemplates embed.FS
 
@@ -271,3 +331,11 @@ func (sf *ScriptFlow) notificationSlackMessage(mc MessageContext) (string, error
 	}
 	return tpl.String(), nil
 }
+
+// runError returns error message of the run, or the reason why it was not executed
+func runError(run *core.Record) string {
+	if connectionError := run.GetString("connection_error"); connectionError != "" {
+		return connectionError
+	}
+	return run.GetString("reason")
+}
@@ -18,8 +18,9 @@ func TestRetrieveConsecutiveRunsCount(t *testing.T) {
 	CreateRunsCollection()
 
 	type runStatusAndDate struct {
-		status  string
-		created types.DateTime
+		status   string
+		created  types.DateTime
+		retrying bool
 	}
 	tests := []struct {
 		name          string
@@ -124,6 +125,16 @@ func TestRetrieveConsecutiveRunsCount(t *testing.T) {
 			},
 			expectedCount: 1,
 		},
+		{
+			name: "Retrying attempts are not counted",
+			runs: []runStatusAndDate{
+				{status: "error", created: types.NowDateTime().Add(-1 * time.Hour)},
+				{status: "error", created: types.NowDateTime().Add(-2 * time.Hour), retrying: true},
+				{status: "error", created: types.NowDateTime().Add(-3 * time.Hour), retrying: true},
+				{status: "error", created: types.NowDateTime().Add(-4 * time.Hour)},
+			},
+			expectedCount: 2,
+		},
 	}
 
 	for _, tt := range tests {
@@ -137,9 +148,10 @@ func TestRetrieveConsecutiveRunsCount(t *testing.T) {
 			// Insert runs
 			for _, run := range tt.runs {
 				testApp.DB().Insert(CollectionRuns, dbx.Params{
-					"task":    subscription.Task,
-					"status":  run.status,
-					"created": run.created,
+					"task":     subscription.Task,
+					"status":   run.status,
+					"created":  run.created,
+					"retrying": run.retrying,
 				}).Execute()
 			}
 
@@ -152,6 +164,44 @@ func TestRetrieveConsecutiveRunsCount(t *testing.T) {
 	}
 }
 
+func TestRetrieveConsecutiveRunsCountOfPipeline(t *testing.T) {
+	testApp, _ := tests.NewTestApp()
+	defer testApp.Cleanup()
+
+	pipelineId := core.GenerateDefaultRandomId()
+	pipelineRunId := core.GenerateDefaultRandomId()
+	taskId := core.GenerateDefaultRandomId()
+	runs := []dbx.Params{
+		// pipeline runs
+		{"id": pipelineRunId, "pipeline": pipelineId, "status": "error", "created": types.NowDateTime().Add(-1 * time.Hour)},
+		{"pipeline": pipelineId, "status": "error", "created": types.NowDateTime().Add(-2 * time.Hour)},
+		// step run of the pipeline run and child run of a fan-out execution of the task
+		{"task": taskId, "pipeline": pipelineId, "execution": pipelineRunId, "status": "error", "created": types.NowDateTime().Add(-1 * time.Hour)},
+		{"task": taskId, "execution": core.GenerateDefaultRandomId(), "status": "error", "created": types.NowDateTime().Add(-1 * time.Hour)},
+	}
+	for _, run := range runs {
+		_, err := testApp.DB().Insert(CollectionRuns, run).Execute()
+		assert.NoError(t, err)
+	}
+
+	subscription := SubscriptionItem{
+		Threshold: 3,
+		Events:    types.JSONArray[string]{"error"},
+		Notified:  types.NowDateTime().AddDate(0, 0, -1),
+	}
+	pipelineSubscription := subscription
+	pipelineSubscription.Pipeline = pipelineId
+	count, err := retrieveConsecutiveRunsCount(testApp.DB(), pipelineSubscription)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count, "only pipeline runs are counted")
+
+	taskSubscription := subscription
+	taskSubscription.Task = taskId
+	count, err = retrieveConsecutiveRunsCount(testApp.DB(), taskSubscription)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count, "step runs are runs of their task")
+}
+
 func TestRetrieveSubscriptionsForRun(t *testing.T) {
 	testApp, _ := tests.NewTestApp()
 	defer testApp.Cleanup()
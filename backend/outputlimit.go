@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// OutputLimit caps the output of a run written to its log, zero values mean no limit
+type OutputLimit struct {
+	MaxBytes int64
+	MaxLines int
+	Kill     bool // kill the run when the limit is exceeded
+}
+
+// taskOutputLimit returns the output limit of the task, limits not set on the task are taken from the project.
+// The project is nil if it wasn't found.
+func taskOutputLimit(task *core.Record, project *core.Record) OutputLimit {
+	limit := OutputLimit{
+		MaxBytes: int64(task.GetInt("max_output_bytes")),
+		MaxLines: task.GetInt("max_output_lines"),
+		Kill:     task.GetBool("output_limit_kill"),
+	}
+	if project == nil {
+		return limit
+	}
+	if limit.MaxBytes <= 0 {
+		maxBytes, _ := GetCollectionConfigAttr(project, "maxOutputBytes", int64(0))
+		limit.MaxBytes, _ = maxBytes.(int64)
+	}
+	if limit.MaxLines <= 0 {
+		maxLines, _ := GetCollectionConfigAttr(project, "maxOutputLines", 0)
+		limit.MaxLines, _ = maxLines.(int)
+	}
+	return limit
+}
+
+// outputLimiter counts the output of a run, output callbacks of stdout and stderr may be called concurrently
+type outputLimiter struct {
+	limit     OutputLimit
+	kill      func() // called when the limit is exceeded, if the limit kills the run
+	mutex     sync.Mutex
+	bytes     int64
+	lines     int
+	truncated bool
+}
+
+func newOutputLimiter(limit OutputLimit, kill func()) *outputLimiter {
+	return &outputLimiter{limit: limit, kill: kill}
+}
+
+// allow counts the log line and reports whether it can be written. exceeded is true only for the first line
+// over the limit, the following lines are dropped silently.
+func (l *outputLimiter) allow(line string) (allowed bool, exceeded bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.truncated {
+		return false, false
+	}
+	size := int64(len(line))
+	if (l.limit.MaxLines > 0 && l.lines+1 > l.limit.MaxLines) || (l.limit.MaxBytes > 0 && l.bytes+size > l.limit.MaxBytes) {
+		l.truncated = true
+		if l.limit.Kill && l.kill != nil {
+			l.kill()
+		}
+		return false, true
+	}
+	l.lines++
+	l.bytes += size
+	return true, false
+}
+
+// Truncated reports whether the output exceeded the limit
+func (l *outputLimiter) Truncated() bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.truncated
+}
+
+// marker returns the text of the log line recorded in place of the dropped output
+func (l *outputLimiter) marker() string {
+	if l.limit.Kill {
+		return fmt.Sprintf("output truncated: %s exceeded, run killed", l.limit)
+	}
+	return fmt.Sprintf("output truncated: %s exceeded", l.limit)
+}
+
+// String describes the limits, e.g. "limit of 1048576 bytes / 1000 lines"
+func (limit OutputLimit) String() string {
+	switch {
+	case limit.MaxBytes > 0 && limit.MaxLines > 0:
+		return fmt.Sprintf("limit of %d bytes / %d lines", limit.MaxBytes, limit.MaxLines)
+	case limit.MaxBytes > 0:
+		return fmt.Sprintf("limit of %d bytes", limit.MaxBytes)
+	case limit.MaxLines > 0:
+		return fmt.Sprintf("limit of %d lines", limit.MaxLines)
+	}
+	return "no limit"
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaskOutputLimit(t *testing.T) {
+	tasks := core.NewBaseCollection(CollectionTasks)
+	tasks.Fields.Add(&core.NumberField{Name: "max_output_bytes"})
+	tasks.Fields.Add(&core.NumberField{Name: "max_output_lines"})
+	tasks.Fields.Add(&core.BoolField{Name: "output_limit_kill"})
+	task := core.NewRecord(tasks)
+	task.Set("max_output_bytes", 1024)
+	task.Set("output_limit_kill", true)
+
+	assert.Equal(t, OutputLimit{MaxBytes: 1024, Kill: true}, taskOutputLimit(task, nil))
+
+	projects := core.NewBaseCollection(CollectionProjects)
+	projects.Fields.Add(&core.JSONField{Name: "config"})
+	project := core.NewRecord(projects)
+	project.Set("config", `{"maxOutputBytes": 4096, "maxOutputLines": 100}`)
+	assert.Equal(t, OutputLimit{MaxBytes: 1024, MaxLines: 100, Kill: true}, taskOutputLimit(task, project),
+		"limits of the task take precedence over the project")
+}
+
+func TestOutputLimiter(t *testing.T) {
+	limiter := newOutputLimiter(OutputLimit{MaxLines: 2}, nil)
+	for _, expected := range [][2]bool{{true, false}, {true, false}, {false, true}, {false, false}} {
+		allowed, exceeded := limiter.allow("line\n")
+		assert.Equal(t, expected, [2]bool{allowed, exceeded})
+	}
+	assert.True(t, limiter.Truncated())
+	assert.Equal(t, "output truncated: limit of 2 lines exceeded", limiter.marker())
+
+	limiter = newOutputLimiter(OutputLimit{MaxBytes: 10}, nil)
+	allowed, _ := limiter.allow("12345\n")
+	assert.True(t, allowed)
+	allowed, exceeded := limiter.allow("12345\n")
+	assert.False(t, allowed, "a line crossing the limit is dropped whole")
+	assert.True(t, exceeded)
+
+	killed := 0
+	limiter = newOutputLimiter(OutputLimit{MaxBytes: 10, MaxLines: 5, Kill: true}, func() { killed++ })
+	limiter.allow("0123456789\n")
+	limiter.allow("0123456789\n")
+	assert.Equal(t, 1, killed)
+	assert.Equal(t, "output truncated: limit of 10 bytes / 5 lines exceeded, run killed", limiter.marker())
+
+	limiter = newOutputLimiter(OutputLimit{}, nil)
+	for range 1000 {
+		allowed, _ := limiter.allow("line\n")
+		assert.True(t, allowed)
+	}
+	assert.False(t, limiter.Truncated())
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// types of task parameters
+const (
+	ParamTypeString = "string"
+	ParamTypeInt    = "int"
+	ParamTypeFloat  = "float"
+	ParamTypeBool   = "bool"
+)
+
+var ParamTypes = []string{ParamTypeString, ParamTypeInt, ParamTypeFloat, ParamTypeBool}
+
+// paramPlaceholderPattern matches "{{NAME}}" placeholders of parameters in the task command
+var paramPlaceholderPattern = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+// TaskParam is a typed parameter of manual runs of a task. Its value is exported as env variable of the same
+// name and replaces "{{NAME}}" placeholders in the command. Empty type means string, empty allowed means any value.
+type TaskParam struct {
+	Name        string   `json:"name" yaml:"name"`
+	Type        string   `json:"type,omitempty" yaml:"type"`
+	Default     string   `json:"default,omitempty" yaml:"default"`
+	Allowed     []string `json:"allowed,omitempty" yaml:"allowed"`
+	Required    bool     `json:"required,omitempty" yaml:"required"`
+	Description string   `json:"description,omitempty" yaml:"description"`
+}
+
+// normalize parses the value according to the parameter type and returns it in canonical form
+func (p TaskParam) normalize(value string) (string, error) {
+	switch p.Type {
+	case "", ParamTypeString:
+	case ParamTypeInt:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return "", fmt.Errorf("parameter %s must be an integer: %q", p.Name, value)
+		}
+		value = strconv.FormatInt(n, 10)
+	case ParamTypeFloat:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return "", fmt.Errorf("parameter %s must be a number: %q", p.Name, value)
+		}
+		value = strconv.FormatFloat(f, 'f', -1, 64)
+	case ParamTypeBool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return "", fmt.Errorf("parameter %s must be a boolean: %q", p.Name, value)
+		}
+		value = strconv.FormatBool(b)
+	default:
+		return "", fmt.Errorf("invalid type of parameter %s: %q", p.Name, p.Type)
+	}
+	if len(p.Allowed) > 0 && !slices.Contains(p.Allowed, value) {
+		return "", fmt.Errorf("parameter %s must be one of %v: %q", p.Name, p.Allowed, value)
+	}
+	return value, nil
+}
+
+// validateTaskParams checks names, types, defaults and allowed values of the task parameters
+func validateTaskParams(params []TaskParam) error {
+	seen := map[string]bool{}
+	for _, param := range params {
+		if !envNamePattern.MatchString(param.Name) {
+			return fmt.Errorf("invalid parameter name: %q", param.Name)
+		}
+		if seen[param.Name] {
+			return fmt.Errorf("duplicate parameter: %s", param.Name)
+		}
+		seen[param.Name] = true
+		if param.Type != "" && !slices.Contains(ParamTypes, param.Type) {
+			return fmt.Errorf("invalid type of parameter %s: %q", param.Name, param.Type)
+		}
+		for _, allowed := range param.Allowed {
+			if normalized, err := param.normalize(allowed); err != nil || normalized != allowed {
+				return fmt.Errorf("allowed value %q of parameter %s doesn't match its type", allowed, param.Name)
+			}
+		}
+		if param.Default != "" {
+			if _, err := param.normalize(param.Default); err != nil {
+				return fmt.Errorf("invalid default: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// resolveParams validates values of the parameters and returns them in canonical form, missing values are
+// replaced by defaults. JSON numbers and booleans are accepted as well as strings.
+func resolveParams(params []TaskParam, values map[string]any) (map[string]string, error) {
+	for name := range values {
+		if !slices.ContainsFunc(params, func(p TaskParam) bool { return p.Name == name }) {
+			return nil, fmt.Errorf("unknown parameter: %s", name)
+		}
+	}
+
+	resolved := make(map[string]string, len(params))
+	for _, param := range params {
+		value, ok := values[param.Name]
+		if !ok || value == nil {
+			if param.Required && param.Default == "" {
+				return nil, fmt.Errorf("parameter %s is required", param.Name)
+			}
+			if param.Default == "" {
+				continue
+			}
+			value = param.Default
+		}
+
+		var s string
+		switch v := value.(type) {
+		case string:
+			s = v
+		case float64:
+			s = strconv.FormatFloat(v, 'f', -1, 64)
+		case bool:
+			s = strconv.FormatBool(v)
+		default:
+			return nil, fmt.Errorf("parameter %s must be a string, number or boolean", param.Name)
+		}
+		if s == "" && param.Required {
+			return nil, fmt.Errorf("parameter %s is required", param.Name)
+		}
+		normalized, err := param.normalize(s)
+		if err != nil {
+			return nil, err
+		}
+		resolved[param.Name] = normalized
+	}
+	return resolved, nil
+}
+
+// renderCommandParams replaces "{{NAME}}" placeholders of the parameters in the command with their shell quoted
+// values, placeholders of parameters without a value are replaced with an empty string and other ones are kept
+func renderCommandParams(command string, params []TaskParam, values map[string]string) string {
+	if len(params) == 0 {
+		return command
+	}
+	return paramPlaceholderPattern.ReplaceAllStringFunc(command, func(placeholder string) string {
+		name := paramPlaceholderPattern.FindStringSubmatch(placeholder)[1]
+		if !slices.ContainsFunc(params, func(p TaskParam) bool { return p.Name == name }) {
+			return placeholder
+		}
+		return shellQuote(values[name])
+	})
+}
+
+// taskParams returns parameters of the task
+func taskParams(task *core.Record) ([]TaskParam, error) {
+	var params []TaskParam
+	if err := task.UnmarshalJSONField("params", &params); err != nil {
+		return nil, fmt.Errorf("invalid params: %w", err)
+	}
+	return params, nil
+}
+
+// resolveTaskParams returns values of the task parameters, missing values are replaced by defaults
+func resolveTaskParams(task *core.Record, values map[string]any) (map[string]string, error) {
+	params, err := taskParams(task)
+	if err != nil {
+		return nil, err
+	}
+	return resolveParams(params, values)
+}
+
+// validateTaskRecordParams rejects task record with invalid parameters
+func validateTaskRecordParams(task *core.Record) error {
+	params, err := taskParams(task)
+	if err == nil {
+		err = validateTaskParams(params)
+	}
+	if err != nil {
+		return validation.Errors{"params": validation.NewError("validation_invalid_params", err.Error())}
+	}
+	return nil
+}
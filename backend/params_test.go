@@ -0,0 +1,77 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateTaskParams(t *testing.T) {
+	assert.NoError(t, validateTaskParams(nil))
+	assert.NoError(t, validateTaskParams([]TaskParam{
+		{Name: "TARGET", Allowed: []string{"staging", "production"}, Required: true},
+		{Name: "COUNT", Type: ParamTypeInt, Default: "3"},
+		{Name: "RATIO", Type: ParamTypeFloat, Default: "0.5"},
+		{Name: "DRY_RUN", Type: ParamTypeBool, Default: "true"},
+	}))
+
+	invalid := []struct {
+		name   string
+		params []TaskParam
+	}{
+		{"name", []TaskParam{{Name: "DRY-RUN"}}},
+		{"duplicate", []TaskParam{{Name: "COUNT"}, {Name: "COUNT"}}},
+		{"type", []TaskParam{{Name: "COUNT", Type: "number"}}},
+		{"default type", []TaskParam{{Name: "COUNT", Type: ParamTypeInt, Default: "three"}}},
+		{"default not allowed", []TaskParam{{Name: "TARGET", Default: "dev", Allowed: []string{"staging"}}}},
+		{"allowed type", []TaskParam{{Name: "COUNT", Type: ParamTypeInt, Allowed: []string{"1", "two"}}}},
+	}
+	for _, tc := range invalid {
+		assert.Error(t, validateTaskParams(tc.params), tc.name)
+	}
+}
+
+func TestResolveParams(t *testing.T) {
+	params := []TaskParam{
+		{Name: "TARGET", Allowed: []string{"staging", "production"}, Required: true},
+		{Name: "COUNT", Type: ParamTypeInt, Default: "3"},
+		{Name: "RATIO", Type: ParamTypeFloat},
+		{Name: "DRY_RUN", Type: ParamTypeBool, Default: "true"},
+	}
+
+	values, err := resolveParams(params, map[string]any{"TARGET": "staging"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"TARGET": "staging", "COUNT": "3", "DRY_RUN": "true"}, values)
+
+	values, err = resolveParams(params, map[string]any{"TARGET": "production", "COUNT": float64(10), "RATIO": "0.25", "DRY_RUN": false})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"TARGET": "production", "COUNT": "10", "RATIO": "0.25", "DRY_RUN": "false"}, values)
+
+	invalid := []struct {
+		name   string
+		values map[string]any
+	}{
+		{"required", nil},
+		{"empty required", map[string]any{"TARGET": ""}},
+		{"not allowed", map[string]any{"TARGET": "dev"}},
+		{"unknown", map[string]any{"TARGET": "staging", "OTHER": "x"}},
+		{"int", map[string]any{"TARGET": "staging", "COUNT": 1.5}},
+		{"bool", map[string]any{"TARGET": "staging", "DRY_RUN": "maybe"}},
+		{"object", map[string]any{"TARGET": "staging", "RATIO": map[string]any{}}},
+	}
+	for _, tc := range invalid {
+		_, err := resolveParams(params, tc.values)
+		assert.Error(t, err, tc.name)
+	}
+}
+
+func TestRenderCommandParams(t *testing.T) {
+	params := []TaskParam{{Name: "TARGET"}, {Name: "MESSAGE"}, {Name: "EMPTY"}}
+	values := map[string]string{"TARGET": "staging", "MESSAGE": "it's done"}
+
+	assert.Equal(t,
+		`deploy.sh 'staging' --message 'it'\''s done' --tag '' {{ OTHER }}`,
+		renderCommandParams("deploy.sh {{TARGET}} --message {{ MESSAGE }} --tag {{EMPTY}} {{ OTHER }}", params, values),
+	)
+	assert.Equal(t, "echo {{TARGET}}", renderCommandParams("echo {{TARGET}}", nil, values), "tasks without params are not rendered")
+}
@@ -0,0 +1,417 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync/atomic"
+
+	"github.com/go-co-op/gocron/v2"
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// PipelineStep is a task of a pipeline which runs once all its upstream steps finish and their statuses
+// meet the condition. Needs are ids of the upstream tasks, empty condition means on_success.
+type PipelineStep struct {
+	Task  string   `json:"task" yaml:"task"`
+	Needs []string `json:"needs,omitempty" yaml:"needs"`
+	When  string   `json:"when,omitempty" yaml:"when"`
+}
+
+// pipelineSteps returns steps of the pipeline tasks in their order. Without steps the tasks run one by one,
+// each after the previous one succeeds. Tasks without a step run when the pipeline starts.
+func pipelineSteps(tasks []string, steps []PipelineStep) []PipelineStep {
+	result := make([]PipelineStep, 0, len(tasks))
+	for i, task := range tasks {
+		step := PipelineStep{Task: task}
+		if len(steps) == 0 && i > 0 {
+			step.Needs = []string{tasks[i-1]}
+		}
+		for _, s := range steps {
+			if s.Task == task {
+				step = s
+				break
+			}
+		}
+		if step.When == "" {
+			step.When = StepWhenOnSuccess
+		}
+		result = append(result, step)
+	}
+	return result
+}
+
+// validatePipelineSteps checks that steps refer to the pipeline tasks, have valid conditions and form no cycle
+func validatePipelineSteps(tasks []string, steps []PipelineStep) error {
+	seen := map[string]bool{}
+	for _, step := range steps {
+		if !slices.Contains(tasks, step.Task) {
+			return fmt.Errorf("step task %q is not a task of the pipeline", step.Task)
+		}
+		if seen[step.Task] {
+			return fmt.Errorf("duplicate step of task %q", step.Task)
+		}
+		seen[step.Task] = true
+		if step.When != "" && !slices.Contains(StepConditions, step.When) {
+			return fmt.Errorf("invalid step condition: %q", step.When)
+		}
+		for _, need := range step.Needs {
+			if !slices.Contains(tasks, need) {
+				return fmt.Errorf("step %q needs %q which is not a task of the pipeline", step.Task, need)
+			}
+			if need == step.Task {
+				return fmt.Errorf("step %q needs itself", step.Task)
+			}
+		}
+	}
+
+	// steps are ordered by removing those whose upstream steps are already ordered, the rest form a cycle
+	ordered := map[string]bool{}
+	pending := pipelineSteps(tasks, steps)
+	for len(pending) > 0 {
+		var next []PipelineStep
+		for _, step := range pending {
+			if slices.ContainsFunc(step.Needs, func(need string) bool { return !ordered[need] }) {
+				next = append(next, step)
+			}
+		}
+		if len(next) == len(pending) {
+			return fmt.Errorf("steps form a cycle: step %q", next[0].Task)
+		}
+		for _, step := range pending {
+			if !slices.ContainsFunc(next, func(s PipelineStep) bool { return s.Task == step.Task }) {
+				ordered[step.Task] = true
+			}
+		}
+		pending = next
+	}
+	return nil
+}
+
+// stepConditionMet reports whether a step with the condition runs after its upstream steps finished with
+// the statuses. Skipped upstream steps count neither as success nor as failure: on_success needs at least
+// one completed upstream step and no other status besides skipped, on_failure at least one failed one.
+// Root steps always run.
+func stepConditionMet(when string, upstream []string) bool {
+	if len(upstream) == 0 {
+		return true
+	}
+	switch when {
+	case StepWhenAlways:
+		return true
+	case StepWhenOnFailure:
+		return slices.ContainsFunc(upstream, func(status string) bool {
+			return slices.Contains([]string{RunStatusError, RunStatusInternalError, RunStatusTimeout}, status)
+		})
+	default:
+		succeeded := slices.Contains(upstream, RunStatusCompleted)
+		return succeeded && !slices.ContainsFunc(upstream, func(status string) bool {
+			return status != RunStatusCompleted && status != RunStatusSkipped
+		})
+	}
+}
+
+// pipelineRecordSteps returns validated steps of the pipeline record
+func pipelineRecordSteps(pipeline *core.Record) ([]PipelineStep, error) {
+	var steps []PipelineStep
+	if err := pipeline.UnmarshalJSONField("steps", &steps); err != nil {
+		return nil, fmt.Errorf("invalid steps: %w", err)
+	}
+	tasks := pipeline.GetStringSlice("tasks")
+	if err := validatePipelineSteps(tasks, steps); err != nil {
+		return nil, err
+	}
+	return pipelineSteps(tasks, steps), nil
+}
+
+// validatePipelineRecord rejects pipeline record with invalid time zone, schedule or steps.
+// Empty schedule means the pipeline is run manually only.
+func (sf *ScriptFlow) validatePipelineRecord(pipeline *core.Record) error {
+	timezone := pipeline.GetString("timezone")
+	if !isValidTimezone(timezone) {
+		return validation.Errors{"timezone": validation.NewError("validation_invalid_timezone", "invalid time zone")}
+	}
+	if schedule := pipeline.GetString("schedule"); schedule != "" {
+		if _, isAt, _ := parseAtSchedule(schedule); isAt {
+			return validation.Errors{"schedule": validation.NewError("validation_invalid_schedule", "one-time schedules are not supported by pipelines")}
+		}
+		if schedule == RebootSchedule {
+			return validation.Errors{"schedule": validation.NewError("validation_invalid_schedule", "@reboot schedule is not supported by pipelines")}
+		}
+		if err := validateSchedule(schedule, pipeline.Id, sf.pipelineTimezone(pipeline)); err != nil {
+			return validation.Errors{"schedule": validation.NewError("validation_invalid_schedule", err.Error())}
+		}
+	}
+	if _, err := pipelineRecordSteps(pipeline); err != nil {
+		return validation.Errors{"steps": validation.NewError("validation_invalid_steps", err.Error())}
+	}
+	return nil
+}
+
+// pipelineTimezone returns time zone of the pipeline schedule, the project default is used if the pipeline has none
+func (sf *ScriptFlow) pipelineTimezone(pipeline *core.Record) string {
+	if timezone := pipeline.GetString("timezone"); timezone != "" {
+		return timezone
+	}
+	project, err := sf.app.FindRecordById(CollectionProjects, pipeline.GetString("project"))
+	if err != nil {
+		return ""
+	}
+	timezone, _ := GetCollectionConfigAttr(project, "timezone", "")
+	timezoneStr, _ := timezone.(string)
+	return timezoneStr
+}
+
+// schedulePipelines schedules active pipelines having a schedule
+func (sf *ScriptFlow) schedulePipelines() {
+	pipelines, err := sf.app.FindAllRecords(CollectionPipelines, dbx.HashExp{"active": true})
+	if err != nil {
+		sf.app.Logger().Error("failed to find active pipelines", slog.Any("error", err))
+		return
+	}
+	for _, pipeline := range pipelines {
+		sf.SchedulePipeline(pipeline)
+	}
+}
+
+// SchedulePipeline replaces the job of the pipeline, inactive pipelines and those without a schedule have no job
+func (sf *ScriptFlow) SchedulePipeline(pipeline *core.Record) {
+	sf.pipelinesMutex.Lock()
+	defer sf.pipelinesMutex.Unlock()
+
+	if job, exists := sf.pipelineJobs[pipeline.Id]; exists {
+		if err := sf.scheduler.RemoveJob(job.ID()); err != nil {
+			sf.app.Logger().Error("failed to remove pipeline job", pipelineAttrs(pipeline), slog.Any("error", err))
+		}
+		delete(sf.pipelineJobs, pipeline.Id)
+	}
+	schedule := pipeline.GetString("schedule")
+	if !pipeline.GetBool("active") || schedule == "" {
+		return
+	}
+
+	jobDefinition, err := recurringJobDefinition(schedule, pipeline.Id, sf.pipelineTimezone(pipeline))
+	if err != nil {
+		sf.app.Logger().Error("invalid schedule", pipelineAttrs(pipeline), slog.Any("error", err))
+		return
+	}
+	job, err := sf.scheduler.NewJob(
+		jobDefinition,
+		gocron.NewTask(sf.runPipeline, pipeline.Id),
+		gocron.WithTags(PipelineTask, pipeline.Id),
+		gocron.WithCronImplementation(&extendedCron{}),
+	)
+	if err != nil {
+		sf.app.Logger().Error("failed to schedule pipeline", pipelineAttrs(pipeline), slog.Any("error", err))
+		return
+	}
+	sf.pipelineJobs[pipeline.Id] = job
+	sf.app.Logger().Info("scheduled pipeline", pipelineAttrs(pipeline))
+}
+
+// UnschedulePipeline removes the job of the deleted pipeline
+func (sf *ScriptFlow) UnschedulePipeline(pipelineId string) {
+	sf.pipelinesMutex.Lock()
+	defer sf.pipelinesMutex.Unlock()
+
+	job, exists := sf.pipelineJobs[pipelineId]
+	if !exists {
+		return
+	}
+	delete(sf.pipelineJobs, pipelineId)
+	if err := sf.scheduler.RemoveJob(job.ID()); err != nil {
+		sf.app.Logger().Error("failed to remove pipeline job", slog.String("pipelineId", pipelineId), slog.Any("error", err))
+	}
+}
+
+// newPipelineRunRecord returns unsaved run record of the pipeline, the pipeline run has no task
+func (sf *ScriptFlow) newPipelineRunRecord(pipeline *core.Record) (*core.Record, error) {
+	runCollection, err := sf.app.FindCollectionByNameOrId(CollectionRuns)
+	if err != nil {
+		return nil, fmt.Errorf("unable to find collection '%s': %w", CollectionRuns, err)
+	}
+	run := core.NewRecord(runCollection)
+	run.Set("pipeline", pipeline.Id)
+	run.Set("host", "pipeline: "+pipeline.GetString("name"))
+	run.Set("status", RunStatusStarted)
+	run.Set("attempt", 1)
+	return run, nil
+}
+
+// deletePipelineRuns deletes runs of the pipeline itself, which have no task. Step runs are runs of their tasks,
+// they are kept with the link to the pipeline removed.
+func deletePipelineRuns(app core.App, pipelineId string) error {
+	runs, err := app.FindAllRecords(CollectionRuns, dbx.HashExp{"pipeline": pipelineId, "task": ""})
+	if err != nil {
+		return err
+	}
+	for _, run := range runs {
+		if err := app.Delete(run); err != nil {
+			return fmt.Errorf("failed to delete pipeline run %s: %w", run.Id, err)
+		}
+	}
+	return nil
+}
+
+// pipelineLockKey returns the key of the run slot of the pipeline. Pipelines share the run slots with tasks,
+// and ids generated from the config name of a task and a pipeline may be the same.
+func pipelineLockKey(pipelineId string) string {
+	return "pipeline:" + pipelineId
+}
+
+// runPipeline runs steps of the pipeline as child runs of a pipeline run. A step starts when its upstream
+// steps finish, steps whose condition is not met are recorded as skipped. The pipeline run status is
+// aggregated from the statuses of the steps, killing the pipeline run kills its running steps.
+func (sf *ScriptFlow) runPipeline(pipelineId string) {
+	pipeline, err := sf.app.FindRecordById(CollectionPipelines, pipelineId)
+	if err != nil {
+		sf.app.Logger().Error("failed to find pipeline", slog.String("pipelineId", pipelineId), slog.Any("error", err))
+		return
+	}
+	run, err := sf.newPipelineRunRecord(pipeline)
+	if err != nil {
+		sf.app.Logger().Error("failed to create record", slog.Any("error", err))
+		return
+	}
+
+	// a pipeline run at a time, its steps would compete for the same tasks otherwise
+	if !sf.tryLockTask(pipelineLockKey(pipeline.Id), 1) {
+		sf.app.Logger().Info("skip pipeline trigger", pipelineAttrs(pipeline), slog.String("reason", SkipReasonPipelineRunning))
+		run.Set("status", RunStatusSkipped)
+		run.Set("reason", SkipReasonPipelineRunning)
+		if err := sf.app.Save(run); err != nil {
+			sf.app.Logger().Error("failed to save run record", slog.Any("error", err))
+		}
+		return
+	}
+	defer sf.unlockTask(pipelineLockKey(pipeline.Id))
+
+	steps, err := pipelineRecordSteps(pipeline)
+	if err != nil {
+		sf.app.Logger().Error("invalid pipeline steps", pipelineAttrs(pipeline), slog.Any("error", err))
+		run.Set("status", RunStatusInternalError)
+		run.Set("reason", err.Error())
+		if err := sf.app.Save(run); err != nil {
+			sf.app.Logger().Error("failed to save run record", slog.Any("error", err))
+		}
+		return
+	}
+	if err := sf.app.Save(run); err != nil {
+		sf.app.Logger().Error("failed to save run record", slog.Any("error", err))
+		return
+	}
+
+	// killing the pipeline run kills its running steps, steps which haven't started are skipped
+	ctx, cancel := context.WithCancel(sf.ctx)
+	defer cancel()
+	var killed atomic.Bool
+	sf.registerActiveRun(run.Id, func() {
+		killed.Store(true)
+		cancel()
+		sf.killExecutionRuns(run.Id)
+	})
+	defer sf.unregisterActiveRun(run.Id)
+
+	statuses := sf.runPipelineSteps(ctx, pipeline, run, steps)
+	stepStatuses := make([]string, 0, len(statuses))
+	for _, status := range statuses {
+		stepStatuses = append(stepStatuses, status)
+	}
+	status := aggregateRunStatus(stepStatuses)
+	if killed.Load() && status != RunStatusError {
+		status = RunStatusKilled
+	}
+	run.Set("status", status)
+	if err := sf.app.Save(run); err != nil {
+		sf.app.Logger().Error("failed to save run record", slog.Any("error", err))
+	}
+}
+
+// runPipelineSteps runs the steps in order of their dependencies and returns their statuses per task
+func (sf *ScriptFlow) runPipelineSteps(ctx context.Context, pipeline *core.Record, run *core.Record, steps []PipelineStep) map[string]string {
+	trigger := RunTrigger{Execution: run.Id, Pipeline: pipeline.Id}
+	statuses := make(map[string]string, len(steps))
+	started := make(map[string]bool, len(steps))
+	finished := make(chan string)
+	running := 0
+
+	for {
+		// a skipped step may make its downstream steps ready, so steps are checked until none is started
+		for progress := true; progress; {
+			progress = false
+			for _, step := range steps {
+				if started[step.Task] {
+					continue
+				}
+				upstream := make([]string, 0, len(step.Needs))
+				for _, need := range step.Needs {
+					if status, done := statuses[need]; done {
+						upstream = append(upstream, status)
+					}
+				}
+				if len(upstream) < len(step.Needs) {
+					continue
+				}
+				started[step.Task] = true
+				progress = true
+
+				reason := ""
+				if ctx.Err() != nil {
+					reason = SkipReasonPipelineKilled
+				} else if !stepConditionMet(step.When, upstream) {
+					reason = step.When + " condition is not met"
+				}
+				if reason != "" {
+					statuses[step.Task] = RunStatusSkipped
+					sf.skipPipelineStep(step.Task, trigger, reason)
+					continue
+				}
+
+				running++
+				go func(taskId string) {
+					sf.triggerTask(taskId, trigger)
+					finished <- taskId
+				}(step.Task)
+			}
+		}
+		if running == 0 {
+			break
+		}
+
+		taskId := <-finished
+		running--
+		status, err := sf.pipelineStepStatus(run.Id, taskId)
+		if err != nil {
+			sf.app.Logger().Error("failed to aggregate step status", pipelineAttrs(pipeline), slog.String("taskId", taskId), slog.Any("error", err))
+			status = RunStatusInternalError
+		}
+		statuses[taskId] = status
+	}
+	return statuses
+}
+
+// skipPipelineStep records the step which is not run as a skipped run of the pipeline run
+func (sf *ScriptFlow) skipPipelineStep(taskId string, trigger RunTrigger, reason string) {
+	task, err := sf.app.FindRecordById(CollectionTasks, taskId)
+	if err != nil {
+		sf.app.Logger().Error("failed to find task", slog.String("taskId", taskId), slog.Any("error", err))
+		return
+	}
+	sf.skipTrigger(task, trigger, reason)
+}
+
+// pipelineStepStatus returns status of the step aggregated from final attempts of its runs in the pipeline run
+func (sf *ScriptFlow) pipelineStepStatus(pipelineRunId string, taskId string) (string, error) {
+	runs, err := sf.app.FindAllRecords(CollectionRuns, dbx.HashExp{"execution": pipelineRunId, "task": taskId, "retrying": false})
+	if err != nil {
+		return "", err
+	}
+	statuses := make([]string, len(runs))
+	for i, run := range runs {
+		statuses[i] = run.GetString("status")
+	}
+	return aggregateRunStatus(statuses), nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPipelineStepsOrdered(t *testing.T) {
+	steps := pipelineSteps([]string{"build", "test", "deploy"}, nil)
+	assert.Equal(t, []PipelineStep{
+		{Task: "build", When: StepWhenOnSuccess},
+		{Task: "test", Needs: []string{"build"}, When: StepWhenOnSuccess},
+		{Task: "deploy", Needs: []string{"test"}, When: StepWhenOnSuccess},
+	}, steps)
+}
+
+func TestPipelineStepsDAG(t *testing.T) {
+	steps := pipelineSteps([]string{"backup-db", "backup-files", "upload", "alert"}, []PipelineStep{
+		{Task: "upload", Needs: []string{"backup-db", "backup-files"}},
+		{Task: "alert", Needs: []string{"upload"}, When: StepWhenOnFailure},
+	})
+	assert.Equal(t, []PipelineStep{
+		{Task: "backup-db", When: StepWhenOnSuccess},
+		{Task: "backup-files", When: StepWhenOnSuccess},
+		{Task: "upload", Needs: []string{"backup-db", "backup-files"}, When: StepWhenOnSuccess},
+		{Task: "alert", Needs: []string{"upload"}, When: StepWhenOnFailure},
+	}, steps)
+}
+
+func TestValidatePipelineSteps(t *testing.T) {
+	tasks := []string{"a", "b", "c"}
+	assert.NoError(t, validatePipelineSteps(tasks, nil))
+	assert.NoError(t, validatePipelineSteps(tasks, []PipelineStep{
+		{Task: "b", Needs: []string{"a"}},
+		{Task: "c", Needs: []string{"a", "b"}, When: StepWhenAlways},
+	}))
+
+	invalid := []struct {
+		name  string
+		steps []PipelineStep
+	}{
+		{"unknown task", []PipelineStep{{Task: "x"}}},
+		{"unknown need", []PipelineStep{{Task: "b", Needs: []string{"x"}}}},
+		{"duplicate", []PipelineStep{{Task: "b"}, {Task: "b"}}},
+		{"condition", []PipelineStep{{Task: "b", Needs: []string{"a"}, When: "sometimes"}}},
+		{"self", []PipelineStep{{Task: "b", Needs: []string{"b"}}}},
+		{"cycle", []PipelineStep{{Task: "a", Needs: []string{"c"}}, {Task: "b", Needs: []string{"a"}}, {Task: "c", Needs: []string{"b"}}}},
+	}
+	for _, tc := range invalid {
+		assert.Error(t, validatePipelineSteps(tasks, tc.steps), tc.name)
+	}
+}
+
+func TestStepConditionMet(t *testing.T) {
+	tests := []struct {
+		when     string
+		upstream []string
+		expected bool
+	}{
+		{StepWhenOnSuccess, nil, true},
+		{StepWhenOnFailure, nil, true},
+		{StepWhenOnSuccess, []string{RunStatusCompleted, RunStatusCompleted}, true},
+		{StepWhenOnSuccess, []string{RunStatusCompleted, RunStatusError}, false},
+		{StepWhenOnSuccess, []string{RunStatusSkipped}, false},
+		{StepWhenOnSuccess, []string{RunStatusCompleted, RunStatusSkipped}, true},
+		{StepWhenOnSuccess, []string{RunStatusSkipped, RunStatusKilled}, false},
+		{StepWhenOnFailure, []string{RunStatusSkipped, RunStatusError}, true},
+		{StepWhenOnFailure, []string{RunStatusCompleted, RunStatusTimeout}, true},
+		{StepWhenOnFailure, []string{RunStatusCompleted, RunStatusSkipped}, false},
+		{StepWhenOnFailure, []string{RunStatusKilled}, false},
+		{StepWhenAlways, []string{RunStatusError, RunStatusSkipped}, true},
+	}
+	for _, tc := range tests {
+		assert.Equal(t, tc.expected, stepConditionMet(tc.when, tc.upstream), "%s %v", tc.when, tc.upstream)
+	}
+}
+
+func TestPipelineLockKey(t *testing.T) {
+	sf := newTaskSlotsScriptFlow(context.Background())
+	id := generateIdFromName("Deploy")
+	assert.True(t, sf.tryLockTask(id, 1))
+	assert.True(t, sf.tryLockTask(pipelineLockKey(id), 1), "a task and a pipeline of the same name don't block each other")
+	assert.False(t, sf.tryLockTask(pipelineLockKey(id), 1))
+}
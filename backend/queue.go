@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// nodeQueue holds run slots of a node and runs waiting for a free slot in FIFO order
+type nodeQueue struct {
+	limit   int // max_concurrent_runs of the node, 0 means no limit
+	running int
+	waiting []*queuedRun
+}
+
+// queuedRun is a run waiting in the node queue, ready is closed when the run gets a slot
+type queuedRun struct {
+	runId    string
+	taskId   string
+	queuedAt time.Time
+	ready    chan struct{}
+}
+
+// QueuedRunItem describes a queued run in the node queue API response
+type QueuedRunItem struct {
+	RunId    string    `json:"runId"`
+	TaskId   string    `json:"taskId"`
+	Position int       `json:"position"`
+	QueuedAt time.Time `json:"queuedAt"`
+}
+
+// NodeQueueItem describes the node queue in the API response
+type NodeQueueItem struct {
+	NodeId            string          `json:"nodeId"`
+	MaxConcurrentRuns int             `json:"maxConcurrentRuns"`
+	Running           int             `json:"running"`
+	Queued            []QueuedRunItem `json:"queued"`
+}
+
+// hasFreeSlot reports whether a run can take a slot without waiting
+func (q *nodeQueue) hasFreeSlot() bool {
+	return q.limit == 0 || q.running < q.limit
+}
+
+// dispatch hands free slots over to the waiting runs, first queued first
+func (q *nodeQueue) dispatch() {
+	for len(q.waiting) > 0 && q.hasFreeSlot() {
+		next := q.waiting[0]
+		q.waiting = q.waiting[1:]
+		q.running++
+		close(next.ready)
+	}
+}
+
+// remove removes the run from the waiting list, returns false if it is not waiting anymore
+func (q *nodeQueue) remove(runId string) bool {
+	i := slices.IndexFunc(q.waiting, func(r *queuedRun) bool { return r.runId == runId })
+	if i < 0 {
+		return false
+	}
+	q.waiting = slices.Delete(q.waiting, i, i+1)
+	return true
+}
+
+// nodeQueue returns queue of the node, caller has to hold nodeQueuesMutex
+func (sf *ScriptFlow) nodeQueue(nodeId string) *nodeQueue {
+	queue, ok := sf.nodeQueues[nodeId]
+	if !ok {
+		queue = &nodeQueue{}
+		sf.nodeQueues[nodeId] = queue
+	}
+	return queue
+}
+
+// acquireNodeSlot saves the new run and takes a run slot of its node. When the node already runs
+// max_concurrent_runs, the run is saved as queued and waits at the end of the node queue, then it is
+// marked as started. Returns false if the run was not saved, or was killed or the app was stopped while queued.
+// On success the slot must be released with releaseNodeSlot.
+func (sf *ScriptFlow) acquireNodeSlot(node *core.Record, run *core.Record) bool {
+	sf.nodeQueuesMutex.Lock()
+	queue := sf.nodeQueue(node.Id)
+	queue.limit = node.GetInt("max_concurrent_runs")
+	// the limit may have been raised since the last run
+	queue.dispatch()
+	if queue.hasFreeSlot() && len(queue.waiting) == 0 {
+		queue.running++
+		sf.nodeQueuesMutex.Unlock()
+		if err := sf.app.Save(run); err != nil {
+			sf.app.Logger().Error("failed to save run record", slog.Any("error", err))
+			sf.releaseNodeSlot(node.Id)
+			return false
+		}
+		return true
+	}
+
+	// the run is saved while holding the lock, so that it is put in the queue with its id
+	run.Set("status", RunStatusQueued)
+	if err := sf.app.Save(run); err != nil {
+		sf.nodeQueuesMutex.Unlock()
+		sf.app.Logger().Error("failed to save run record", slog.Any("error", err))
+		return false
+	}
+	waiting := &queuedRun{
+		runId:    run.Id,
+		taskId:   run.GetString("task"),
+		queuedAt: time.Now(),
+		ready:    make(chan struct{}),
+	}
+	queue.waiting = append(queue.waiting, waiting)
+	sf.nodeQueuesMutex.Unlock()
+
+	sf.app.Logger().Info("run queued", nodeAttrs(node), slog.String("runId", run.Id), slog.Int("maxConcurrentRuns", queue.limit))
+
+	// a queued run can be killed the same way as a running one
+	ctx, cancel := context.WithCancel(sf.ctx)
+	defer cancel()
+	sf.registerActiveRun(run.Id, cancel)
+	defer sf.unregisterActiveRun(run.Id)
+
+	select {
+	case <-waiting.ready:
+	case <-ctx.Done():
+		sf.nodeQueuesMutex.Lock()
+		removed := queue.remove(run.Id)
+		sf.nodeQueuesMutex.Unlock()
+		if !removed {
+			// the slot was handed over at the same time
+			sf.releaseNodeSlot(node.Id)
+		}
+		// on shutdown queued runs are marked as interrupted by MarkAllRunningTasksAsInterrupted
+		if sf.ctx.Err() == nil {
+			run.Set("status", RunStatusKilled)
+			if err := sf.app.Save(run); err != nil {
+				sf.app.Logger().Error("failed to save run record", slog.Any("error", err))
+			}
+		}
+		return false
+	}
+
+	run.Set("status", RunStatusStarted)
+	if err := sf.app.Save(run); err != nil {
+		sf.app.Logger().Error("failed to save run record", slog.Any("error", err))
+		sf.releaseNodeSlot(node.Id)
+		return false
+	}
+	return true
+}
+
+// releaseNodeSlot releases a run slot of the node and hands it over to the next queued run
+func (sf *ScriptFlow) releaseNodeSlot(nodeId string) {
+	sf.nodeQueuesMutex.Lock()
+	defer sf.nodeQueuesMutex.Unlock()
+	queue := sf.nodeQueue(nodeId)
+	queue.running--
+	queue.dispatch()
+}
+
+// nodeQueueItem returns current state of the node queue
+func (sf *ScriptFlow) nodeQueueItem(node *core.Record) NodeQueueItem {
+	sf.nodeQueuesMutex.Lock()
+	defer sf.nodeQueuesMutex.Unlock()
+	item := NodeQueueItem{
+		NodeId:            node.Id,
+		MaxConcurrentRuns: node.GetInt("max_concurrent_runs"),
+		Queued:            []QueuedRunItem{},
+	}
+	queue, ok := sf.nodeQueues[node.Id]
+	if !ok {
+		return item
+	}
+	item.Running = queue.running
+	for i, waiting := range queue.waiting {
+		item.Queued = append(item.Queued, QueuedRunItem{
+			RunId:    waiting.runId,
+			TaskId:   waiting.taskId,
+			Position: i + 1,
+			QueuedAt: waiting.queuedAt,
+		})
+	}
+	return item
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newQueuedRun(runId string) *queuedRun {
+	return &queuedRun{runId: runId, ready: make(chan struct{})}
+}
+
+func isReady(r *queuedRun) bool {
+	select {
+	case <-r.ready:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestNodeQueueDispatch(t *testing.T) {
+	a, b, c := newQueuedRun("a"), newQueuedRun("b"), newQueuedRun("c")
+	q := &nodeQueue{limit: 2, running: 2, waiting: []*queuedRun{a, b, c}}
+
+	q.dispatch()
+	assert.False(t, isReady(a), "no free slot")
+
+	q.running--
+	q.dispatch()
+	assert.True(t, isReady(a), "first queued run gets the slot")
+	assert.False(t, isReady(b))
+	assert.Equal(t, 2, q.running)
+	assert.Equal(t, []*queuedRun{b, c}, q.waiting)
+
+	// raising the limit releases as many runs as there are new slots
+	q.limit = 3
+	q.dispatch()
+	assert.True(t, isReady(b))
+	assert.False(t, isReady(c))
+
+	// no limit releases everything
+	q.limit = 0
+	q.dispatch()
+	assert.True(t, isReady(c))
+	assert.Empty(t, q.waiting)
+	assert.Equal(t, 4, q.running)
+}
+
+func TestNodeQueueRemove(t *testing.T) {
+	a, b, c := newQueuedRun("a"), newQueuedRun("b"), newQueuedRun("c")
+	q := &nodeQueue{limit: 1, running: 1, waiting: []*queuedRun{a, b, c}}
+
+	assert.True(t, q.remove("b"))
+	assert.Equal(t, []*queuedRun{a, c}, q.waiting)
+	assert.False(t, q.remove("b"), "already removed")
+
+	q.running--
+	q.dispatch()
+	assert.True(t, isReady(a))
+	assert.False(t, q.remove("a"), "dispatched run is not waiting")
+}
@@ -36,11 +36,13 @@ func (sf *ScriptFlow) reconcileActiveJobs() {
 	}
 }
 
-// getActiveTasks retrieves all active tasks from database
+// getActiveTasks retrieves all active tasks which have a schedule from database,
+// tasks without schedule run only by their triggers and @reboot tasks only on startup, they have no job
 func (sf *ScriptFlow) getActiveTasks() ([]*core.Record, error) {
 	return sf.app.FindAllRecords(
 		CollectionTasks,
 		dbx.HashExp{"active": true},
+		dbx.NotIn("schedule", "", RebootSchedule),
 	)
 }
 
@@ -123,13 +125,14 @@ func (sf *ScriptFlow) reconcileScheduler() {
 	}
 }
 
-// getUserJobs returns all non-system jobs from scheduler
+// getUserJobs returns all task jobs from scheduler, without system jobs and jobs of scheduled runs and pipelines
 func (sf *ScriptFlow) getUserJobs() []gocron.Job {
 	allJobs := sf.scheduler.Jobs()
 	userJobs := make([]gocron.Job, 0, len(allJobs))
 
 	for _, job := range allJobs {
-		if !slices.Contains(job.Tags(), SystemTask) {
+		tags := job.Tags()
+		if !slices.Contains(tags, SystemTask) && !slices.Contains(tags, ScheduledRunTask) && !slices.Contains(tags, PipelineTask) {
 			userJobs = append(userJobs, job)
 		}
 	}
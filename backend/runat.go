@@ -0,0 +1,221 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/go-co-op/gocron/v2"
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// ScheduledRunItem is a pending one-time run of a task
+type ScheduledRunItem struct {
+	Id     string    `json:"id"`
+	TaskId string    `json:"taskId"`
+	At     time.Time `json:"at"`
+}
+
+// parseAtSchedule returns fire time of "@at <RFC3339>" one-time schedule, isAt is false for other schedules
+func parseAtSchedule(schedule string) (at time.Time, isAt bool, err error) {
+	value, isAt := strings.CutPrefix(schedule, "@at ")
+	if !isAt {
+		return time.Time{}, false, nil
+	}
+	at, err = time.Parse(time.RFC3339, strings.TrimSpace(value))
+	if err != nil {
+		return time.Time{}, true, fmt.Errorf("invalid @at time, RFC3339 expected: %w", err)
+	}
+	return at, true, nil
+}
+
+// runOneTimeTask runs the task with "@at" schedule and deactivates it, so that it doesn't stay active with
+// a schedule which never fires again
+func (sf *ScriptFlow) runOneTimeTask(taskId string) {
+	sf.runTask(taskId)
+	sf.deactivateTask(taskId)
+}
+
+// deactivateTask sets the task inactive, its job is removed by the task update hook
+func (sf *ScriptFlow) deactivateTask(taskId string) {
+	task, err := sf.app.FindRecordById(CollectionTasks, taskId)
+	if err != nil {
+		sf.app.Logger().Error("failed to find task", slog.String("taskId", taskId), slog.Any("error", err))
+		return
+	}
+	task.Set("active", false)
+	if err := sf.app.Save(task); err != nil {
+		sf.app.Logger().Error("failed to deactivate task", taskAttrs(task), slog.Any("error", err))
+		return
+	}
+	sf.app.Logger().Info("deactivated one-time task", taskAttrs(task))
+}
+
+// recordOneTimeTaskMissedRun records the run of the "@at" task as missed if the time passed while scriptflow
+// was not running, the task is run according to its catch-up policy and deactivated
+func (sf *ScriptFlow) recordOneTimeTaskMissedRun(task *core.Record, at time.Time, now time.Time) {
+	if at.After(now) {
+		return
+	}
+	// the task fired, but scriptflow stopped before it was deactivated
+	runs, err := sf.app.FindRecordsByFilter(CollectionRuns, "task={:task} && created>={:at}", "", 1, 0,
+		dbx.Params{"task": task.Id, "at": at.UTC().Format(time.DateTime)})
+	if err != nil {
+		sf.app.Logger().Error("failed to find runs", taskAttrs(task), slog.Any("error", err))
+		return
+	}
+	if len(runs) > 0 {
+		sf.deactivateTask(task.Id)
+		return
+	}
+
+	sf.recordMissedRun(task, at)
+	switch task.GetString("catchup") {
+	case CatchupLatest, CatchupAll:
+		go sf.runOneTimeTask(task.Id)
+	default:
+		sf.deactivateTask(task.Id)
+	}
+}
+
+// recordMissedRun saves a missed run of the task for the fire time
+func (sf *ScriptFlow) recordMissedRun(task *core.Record, fireTime time.Time) {
+	reason := "scriptflow was not running at " + fireTime.Format(time.RFC3339)
+	if _, err := sf.recordUnexecutedRun(task, RunTrigger{}, RunStatusMissed, reason); err != nil {
+		sf.app.Logger().Error("failed to record missed run", taskAttrs(task), slog.Any("error", err))
+	}
+}
+
+// createScheduledRun stores pending one-time run of the task, it is scheduled by the record create hook
+func (sf *ScriptFlow) createScheduledRun(task *core.Record, at time.Time) (*core.Record, error) {
+	collection, err := sf.app.FindCollectionByNameOrId(CollectionScheduledRuns)
+	if err != nil {
+		return nil, err
+	}
+	record := core.NewRecord(collection)
+	record.Set("task", task.Id)
+	record.Set("at", at)
+	if err := sf.app.Save(record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// validateScheduledRunRecord rejects new scheduled run which is not in the future
+func validateScheduledRunRecord(record *core.Record) error {
+	if record.IsNew() && !record.GetDateTime("at").Time().After(time.Now()) {
+		return validation.Errors{"at": validation.NewError("validation_at_in_the_past", "time must be in the future")}
+	}
+	return nil
+}
+
+// schedulePendingRuns schedules stored one-time runs. Runs whose time passed while scriptflow was not running
+// are recorded as missed and run according to the catch-up policy of the task.
+func (sf *ScriptFlow) schedulePendingRuns() {
+	records, err := sf.app.FindAllRecords(CollectionScheduledRuns)
+	if err != nil {
+		sf.app.Logger().Error("failed to find scheduled runs", slog.Any("error", err))
+		return
+	}
+
+	now := time.Now()
+	for _, record := range records {
+		at := record.GetDateTime("at").Time()
+		if at.After(now) {
+			sf.scheduleScheduledRun(record)
+			continue
+		}
+
+		task, err := sf.app.FindRecordById(CollectionTasks, record.GetString("task"))
+		if err != nil {
+			sf.app.Logger().Error("failed to find task", slog.String("taskId", record.GetString("task")), slog.Any("error", err))
+			continue
+		}
+		sf.deleteScheduledRun(record)
+		sf.recordMissedRun(task, at)
+		switch task.GetString("catchup") {
+		case CatchupLatest, CatchupAll:
+			go sf.runTask(task.Id)
+		}
+	}
+}
+
+// scheduleScheduledRun creates one-time job of the scheduled run
+func (sf *ScriptFlow) scheduleScheduledRun(record *core.Record) {
+	at := record.GetDateTime("at").Time()
+	job, err := sf.scheduler.NewJob(
+		gocron.OneTimeJob(gocron.OneTimeJobStartDateTime(at)),
+		gocron.NewTask(sf.runScheduledRun, record.Id),
+		gocron.WithTags(ScheduledRunTask, record.Id, record.GetString("task")),
+		gocron.WithLimitedRuns(1),
+	)
+	if err != nil {
+		sf.app.Logger().Error("failed to schedule one-time run",
+			slog.String("scheduledRunId", record.Id),
+			slog.String("taskId", record.GetString("task")),
+			slog.Any("error", err))
+		return
+	}
+
+	sf.scheduledMutex.Lock()
+	defer sf.scheduledMutex.Unlock()
+	sf.scheduledRuns[record.Id] = job
+	sf.app.Logger().Info("scheduled one-time run",
+		slog.String("scheduledRunId", record.Id),
+		slog.String("taskId", record.GetString("task")),
+		slog.Time("at", at))
+}
+
+// unscheduleScheduledRun removes job of the deleted scheduled run if it hasn't fired yet
+func (sf *ScriptFlow) unscheduleScheduledRun(scheduledRunId string) {
+	sf.scheduledMutex.Lock()
+	job, exists := sf.scheduledRuns[scheduledRunId]
+	delete(sf.scheduledRuns, scheduledRunId)
+	sf.scheduledMutex.Unlock()
+
+	if !exists {
+		return
+	}
+	if err := sf.scheduler.RemoveJob(job.ID()); err != nil {
+		sf.app.Logger().Error("failed to remove one-time run job",
+			slog.String("scheduledRunId", scheduledRunId),
+			slog.Any("error", err))
+	}
+}
+
+// runScheduledRun removes the pending entry of the scheduled run and runs its task
+func (sf *ScriptFlow) runScheduledRun(scheduledRunId string) {
+	// the job is removed by the scheduler after its only run
+	sf.scheduledMutex.Lock()
+	delete(sf.scheduledRuns, scheduledRunId)
+	sf.scheduledMutex.Unlock()
+
+	record, err := sf.app.FindRecordById(CollectionScheduledRuns, scheduledRunId)
+	if err != nil {
+		// deleted in the meantime
+		return
+	}
+	sf.deleteScheduledRun(record)
+	sf.runTask(record.GetString("task"))
+}
+
+// deleteScheduledRun removes the pending entry of the scheduled run
+func (sf *ScriptFlow) deleteScheduledRun(record *core.Record) {
+	if err := sf.app.Delete(record); err != nil {
+		sf.app.Logger().Error("failed to delete scheduled run",
+			slog.String("scheduledRunId", record.Id),
+			slog.Any("error", err))
+	}
+}
+
+// scheduledRunItem returns response item of the scheduled run
+func scheduledRunItem(record *core.Record) ScheduledRunItem {
+	return ScheduledRunItem{
+		Id:     record.Id,
+		TaskId: record.GetString("task"),
+		At:     record.GetDateTime("at").Time(),
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"strconv"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// RunResult is the outcome of a run returned by the run API in wait mode
+type RunResult struct {
+	RunId     string      `json:"runId"`
+	TaskId    string      `json:"taskId"`
+	Status    string      `json:"status"`
+	ExitCode  int         `json:"exitCode"`
+	Reason    string      `json:"reason,omitempty"`
+	Host      string      `json:"host,omitempty"`
+	Attempt   int         `json:"attempt"`
+	LastRunId string      `json:"lastRunId,omitempty"` // run of the last attempt if the run was retried
+	Output    []string    `json:"output"`              // last lines of the output of the last attempt
+	Runs      []RunResult `json:"runs,omitempty"`      // child runs of a fan-out execution
+}
+
+// parseWaitTimeout parses the timeout of the run API wait mode, a duration or a number of seconds
+func parseWaitTimeout(v string) (time.Duration, error) {
+	if v == "" {
+		return RunWaitDefaultTimeout, nil
+	}
+	timeout, err := time.ParseDuration(v)
+	if err != nil {
+		seconds, atoiErr := strconv.Atoi(v)
+		if atoiErr != nil {
+			return 0, fmt.Errorf("invalid timeout, duration or number of seconds expected: %q", v)
+		}
+		timeout = time.Duration(seconds) * time.Second
+	}
+	if timeout <= 0 || timeout > RunWaitMaxTimeout {
+		return 0, fmt.Errorf("timeout must be positive and at most %s", RunWaitMaxTimeout)
+	}
+	return timeout, nil
+}
+
+// lastAttempt returns the last attempt of the run, which is the run itself if it wasn't retried
+func (sf *ScriptFlow) lastAttempt(run *core.Record) (*core.Record, error) {
+	attempts, err := sf.app.FindRecordsByFilter(CollectionRuns, "parent_run={:runId}", "-attempt", 1, 0, dbx.Params{"runId": run.Id})
+	if err != nil {
+		return nil, err
+	}
+	if len(attempts) == 0 {
+		return run, nil
+	}
+	return attempts[0], nil
+}
+
+// runResult returns the outcome of the run with its last output lines
+func (sf *ScriptFlow) runResult(runId string, lines int) (*RunResult, error) {
+	run, err := sf.app.FindRecordById(CollectionRuns, runId)
+	if err != nil {
+		return nil, err
+	}
+	result, err := sf.attemptResult(run, lines)
+	if err != nil {
+		return nil, err
+	}
+
+	// a fan-out execution has no output of its own, its child runs are reported instead
+	children, err := sf.app.FindAllRecords(CollectionRuns, dbx.HashExp{"execution": run.Id, "attempt": 1})
+	if err != nil {
+		return nil, err
+	}
+	for _, child := range children {
+		childResult, err := sf.attemptResult(child, lines)
+		if err != nil {
+			return nil, err
+		}
+		result.Runs = append(result.Runs, *childResult)
+	}
+	return result, nil
+}
+
+// attemptResult returns the outcome of the last attempt of the run
+func (sf *ScriptFlow) attemptResult(run *core.Record, lines int) (*RunResult, error) {
+	last, err := sf.lastAttempt(run)
+	if err != nil {
+		return nil, err
+	}
+	output, err := sf.runOutputTail(last, lines)
+	if err != nil {
+		return nil, err
+	}
+	result := &RunResult{
+		RunId:    run.Id,
+		TaskId:   last.GetString("task"),
+		Status:   last.GetString("status"),
+		ExitCode: last.GetInt("exit_code"),
+		Reason:   last.GetString("reason"),
+		Host:     last.GetString("host"),
+		Attempt:  last.GetInt("attempt"),
+		Output:   output,
+	}
+	if last.Id != run.Id {
+		result.LastRunId = last.Id
+	}
+	return result, nil
+}
+
+// runOutputTail returns up to the given number of the last output lines of the run,
+// runs which weren't executed have no output
+func (sf *ScriptFlow) runOutputTail(run *core.Record, lines int) ([]string, error) {
+	logs, err := sf.readRunLog(run)
+	if errors.Is(err, fs.ErrNotExist) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	// the first line is the run mark
+	if len(logs) > 0 && logDelimiterRegex.MatchString(logs[0]) {
+		logs = logs[1:]
+	}
+	if len(logs) > lines {
+		logs = logs[len(logs)-lines:]
+	}
+	return logs, nil
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseWaitTimeout(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected time.Duration
+	}{
+		{"", RunWaitDefaultTimeout},
+		{"90s", 90 * time.Second},
+		{"10m", 10 * time.Minute},
+		{"30", 30 * time.Second},
+		{"1h", time.Hour},
+	}
+	for _, tc := range tests {
+		timeout, err := parseWaitTimeout(tc.value)
+		assert.NoError(t, err, tc.value)
+		assert.Equal(t, tc.expected, timeout, tc.value)
+	}
+
+	for _, value := range []string{"soon", "0", "-5s", "2h"} {
+		_, err := parseWaitTimeout(value)
+		assert.Error(t, err, value)
+	}
+}
@@ -4,9 +4,14 @@ import (
 	"fmt"
 	"hash/fnv"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/go-co-op/gocron/v2"
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pocketbase/pocketbase/core"
 )
 
 // durationMinMax returns -10% and +10% of given duration
@@ -15,6 +20,218 @@ func durationMinMax(duration time.Duration) (time.Duration, time.Duration) {
 	return duration - spread, duration + spread
 }
 
+// RebootSchedule runs the task once when scriptflow starts
+const RebootSchedule = "@reboot"
+
+// recurringJobDefinition returns job definition of "@every" or cron schedule. Runs of "@every" schedules are
+// spread by 10% of the duration to avoid running them simultaneously. Cron schedules are evaluated in the
+// time zone, Jenkins-style H notation is resolved with the seed. The job needs the extendedCron implementation
+// option for the L, W and # modifiers.
+func recurringJobDefinition(schedule string, seed string, timezone string) (gocron.JobDefinition, error) {
+	if interval, ok := strings.CutPrefix(schedule, "@every "); ok {
+		duration, err := time.ParseDuration(interval)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse duration: %w", err)
+		}
+		min, max := durationMinMax(duration)
+		return gocron.DurationRandomJob(min, max), nil
+	}
+	crontab, _, err := scheduleCrontab(schedule, seed, timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cron schedule: %w", err)
+	}
+	return gocron.CronJob(crontab, true), nil
+}
+
+// SchedulePreview describes next fire times of a schedule
+type SchedulePreview struct {
+	Schedule string      `json:"schedule"`
+	Resolved string      `json:"resolved"` // crontab with resolved H notation and time zone
+	Timezone string      `json:"timezone"`
+	Next     []time.Time `json:"next"`
+	Jitter   string      `json:"jitter,omitempty"` // spread applied to @every schedules
+}
+
+// previewSchedule validates the schedule and returns its next count fire times after now, in the schedule
+// time zone. H notation is resolved with the seed the same way as for the task with that id.
+// Fire times of @every schedules are shown without the jitter applied when the task is scheduled.
+// @reboot schedules have no fire times.
+func previewSchedule(schedule string, seed string, timezone string, now time.Time, count int) (*SchedulePreview, error) {
+	if schedule == RebootSchedule {
+		location, err := scheduleLocation(timezone)
+		if err != nil {
+			return nil, err
+		}
+		return &SchedulePreview{Schedule: schedule, Resolved: schedule, Timezone: location.String(), Next: []time.Time{}}, nil
+	}
+	if at, isAt, err := parseAtSchedule(schedule); isAt {
+		if err != nil {
+			return nil, err
+		}
+		location, err := scheduleLocation(timezone)
+		if err != nil {
+			return nil, err
+		}
+		preview := &SchedulePreview{Schedule: schedule, Resolved: schedule, Timezone: location.String(), Next: []time.Time{}}
+		if at.After(now) {
+			preview.Next = append(preview.Next, at.In(location))
+		}
+		return preview, nil
+	}
+	if interval, ok := strings.CutPrefix(schedule, "@every "); ok {
+		duration, err := time.ParseDuration(interval)
+		if err != nil {
+			return nil, fmt.Errorf("invalid duration: %w", err)
+		}
+		if duration <= 0 {
+			return nil, fmt.Errorf("duration must be positive: %s", interval)
+		}
+		location, err := scheduleLocation(timezone)
+		if err != nil {
+			return nil, err
+		}
+		preview := &SchedulePreview{Schedule: schedule, Resolved: schedule, Timezone: location.String(), Jitter: "±10%"}
+		for i := 1; i <= count; i++ {
+			preview.Next = append(preview.Next, now.Add(time.Duration(i)*duration).In(location))
+		}
+		return preview, nil
+	}
+
+	crontab, location, err := scheduleCrontab(schedule, seed, timezone)
+	if err != nil {
+		return nil, err
+	}
+	cron := &extendedCron{}
+	if err := cron.IsValid(crontab, location, now); err != nil {
+		return nil, err
+	}
+	preview := &SchedulePreview{Schedule: schedule, Resolved: crontab, Timezone: location.String()}
+	next := now
+	for range count {
+		next = cron.Next(next)
+		if next.IsZero() {
+			break
+		}
+		preview.Next = append(preview.Next, next.In(location))
+	}
+	return preview, nil
+}
+
+// scheduleLocation returns location of the time zone, empty time zone means server local zone
+func scheduleLocation(timezone string) (*time.Location, error) {
+	if timezone == "" {
+		return time.Local, nil
+	}
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time zone %q: %w", timezone, err)
+	}
+	return location, nil
+}
+
+// validateSchedule checks that the schedule can be scheduled for the task with the seed id and fires in the future,
+// or runs on startup
+func validateSchedule(schedule string, seed string, timezone string) error {
+	if schedule == RebootSchedule {
+		_, err := scheduleLocation(timezone)
+		return err
+	}
+	preview, err := previewSchedule(schedule, seed, timezone, time.Now(), 1)
+	if err != nil {
+		return err
+	}
+	if len(preview.Next) == 0 {
+		return fmt.Errorf("schedule never fires after now: %s", schedule)
+	}
+	return nil
+}
+
+// validateTaskRecordSchedule rejects task record with invalid schedule or time zone. They are checked only when
+// changed or when the task is activated, so that a task stored before the validation existed, or a fired one-time
+// task, can still be updated by the scheduler.
+func (sf *ScriptFlow) validateTaskRecordSchedule(task *core.Record) error {
+	schedule := task.GetString("schedule")
+	timezone := task.GetString("timezone")
+	original := task.Original()
+	activated := task.GetBool("active") && !original.GetBool("active")
+	if !task.IsNew() && !activated && schedule == original.GetString("schedule") && timezone == original.GetString("timezone") {
+		return nil
+	}
+	if !isValidTimezone(timezone) {
+		return validation.Errors{"timezone": validation.NewError("validation_invalid_timezone", "invalid time zone")}
+	}
+	// tasks without schedule run only by their triggers or manually
+	if schedule == "" {
+		return nil
+	}
+	if err := validateSchedule(schedule, task.Id, sf.taskTimezone(task)); err != nil {
+		return validation.Errors{"schedule": validation.NewError("validation_invalid_schedule", err.Error())}
+	}
+	return nil
+}
+
+// splitCronTimezone splits "CRON_TZ=<zone> <schedule>" or "TZ=<zone> <schedule>" into the zone and the schedule,
+// zone is empty if the schedule has no such prefix
+func splitCronTimezone(schedule string) (string, string) {
+	for _, prefix := range []string{"CRON_TZ=", "TZ="} {
+		if rest, ok := strings.CutPrefix(schedule, prefix); ok {
+			zone, cron, _ := strings.Cut(rest, " ")
+			return zone, strings.TrimSpace(cron)
+		}
+	}
+	return "", schedule
+}
+
+// scheduleCrontab returns crontab of the cron schedule to evaluate in its time zone. Macros like @daily are
+// expanded and H notation is resolved with the seed. The zone of CRON_TZ= prefix has priority over timezone, server local zone is used if both are empty.
+func scheduleCrontab(schedule string, seed string, timezone string) (string, *time.Location, error) {
+	zone, cron := splitCronTimezone(schedule)
+	if zone == "" {
+		zone = timezone
+	}
+	location := time.Local
+	if zone != "" {
+		var err error
+		location, err = time.LoadLocation(zone)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid time zone %q: %w", zone, err)
+		}
+	}
+	if crontab, ok := cronMacros[cron]; ok {
+		cron = crontab
+	}
+	resolved, err := resolveHashedSchedule(cron, seed)
+	if err != nil {
+		return "", nil, err
+	}
+	return "CRON_TZ=" + location.String() + " " + resolved, location, nil
+}
+
+// cronFireTimesMaxCount bounds the number of fire times cronFireTimes goes through, e.g. a schedule with seconds
+// fires millions of times during a long downtime
+const cronFireTimesMaxCount = 10000
+
+// cronFireTimes returns fire times of the cron schedule after from and up to to, in the location of from.
+// The schedule is evaluated in the zone of CRON_TZ= prefix of the crontab, if any.
+// At most limit latest fire times are returned, along with the total number of fire times in the interval.
+// Fire times are counted up to cronFireTimesMaxCount, the ones after it are left out.
+func cronFireTimes(crontab string, from, to time.Time, limit int) ([]time.Time, int, error) {
+	cron := &extendedCron{}
+	if err := cron.IsValid(crontab, from.Location(), from); err != nil {
+		return nil, 0, err
+	}
+	var times []time.Time
+	total := 0
+	for next := cron.Next(from); !next.IsZero() && !next.After(to) && total < cronFireTimesMaxCount; next = cron.Next(next) {
+		total++
+		times = append(times, next)
+		if len(times) > limit {
+			times = times[1:]
+		}
+	}
+	return times, total, nil
+}
+
 // cronFieldRanges defines the valid ranges for each cron field position
 var cronFieldRanges = []struct {
 	min, max int
@@ -32,63 +249,82 @@ var hashPattern = regexp.MustCompile(`^H(?:\((\d+)-(\d+)\))?$`)
 // resolveHashedSchedule replaces Jenkins-style H notation with deterministic values
 // H -> hash to full field range (e.g., 0-59 for minutes)
 // H(10-30) -> hash to specified range
+// 6-field crons have seconds first, their other fields resolve to the same values as in the 5-field cron.
 // Returns original schedule and error message if validation fails
 func resolveHashedSchedule(schedule string, seed string) (string, error) {
 	fields := strings.Fields(schedule)
-	if len(fields) != 5 {
-		return schedule, nil // not a standard 5-field cron, return as-is
+	if len(fields) != 5 && len(fields) != 6 {
+		return schedule, nil // not a 5 or 6-field cron, e.g. a macro, return as-is
 	}
+	// the optional seconds field comes first
+	offset := len(fields) - 5
 
 	h := fnv.New32a()
 	h.Write([]byte(seed))
 	hashValue := h.Sum32()
 
-	resolved := make([]string, 5)
+	resolved := slices.Clone(fields)
 	fieldNames := []string{"minute", "hour", "day of month", "month", "day of week"}
 
-	for i, field := range fields {
-		matches := hashPattern.FindStringSubmatch(field)
-		if matches == nil {
-			resolved[i] = field
-			continue
-		}
-
-		// Determine range
-		minVal, maxVal := cronFieldRanges[i].min, cronFieldRanges[i].max
-		if matches[1] != "" && matches[2] != "" {
-			// H(min-max) syntax
-			parsedMin, err := strconv.Atoi(matches[1])
-			if err != nil {
-				return schedule, fmt.Errorf("invalid min value in %s field: %s", fieldNames[i], matches[1])
-			}
-			parsedMax, err := strconv.Atoi(matches[2])
-			if err != nil {
-				return schedule, fmt.Errorf("invalid max value in %s field: %s", fieldNames[i], matches[2])
-			}
-
-			// Validate: min must be <= max (no wraparound)
-			if parsedMin > parsedMax {
-				return schedule, fmt.Errorf("invalid range in %s field: min (%d) > max (%d)", fieldNames[i], parsedMin, parsedMax)
-			}
-
-			// Validate: values must be within field bounds
-			if parsedMin < cronFieldRanges[i].min || parsedMax > cronFieldRanges[i].max {
-				return schedule, fmt.Errorf("range out of bounds in %s field: H(%d-%d), valid range is %d-%d",
-					fieldNames[i], parsedMin, parsedMax, cronFieldRanges[i].min, cronFieldRanges[i].max)
-			}
-
-			minVal = parsedMin
-			maxVal = parsedMax
-		}
-
-		// Calculate deterministic value within range
-		rangeSize := maxVal - minVal + 1
-		value := minVal + int(hashValue%uint32(rangeSize))
-		resolved[i] = strconv.Itoa(value)
+	for i, field := range fields[offset:] {
+		value, err := resolveHashedField(field, fieldNames[i], cronFieldRanges[i].min, cronFieldRanges[i].max, hashValue)
+		if err != nil {
+			return schedule, err
+		}
+		resolved[offset+i] = value
 
 		// Use different hash bits for each field
-		hashValue = hashValue*31 + uint32(i)
+		if value != field {
+			hashValue = hashValue*31 + uint32(i)
+		}
+	}
+	if offset == 1 {
+		value, err := resolveHashedField(fields[0], "second", 0, 59, hashValue*31+uint32(len(fieldNames)))
+		if err != nil {
+			return schedule, err
+		}
+		resolved[0] = value
 	}
 
 	return strings.Join(resolved, " "), nil
 }
+
+// resolveHashedField returns the value of H or H(min-max) field within the field bounds, other fields are returned as-is
+func resolveHashedField(field string, name string, lower, upper int, hashValue uint32) (string, error) {
+	matches := hashPattern.FindStringSubmatch(field)
+	if matches == nil {
+		return field, nil
+	}
+
+	// Determine range
+	minVal, maxVal := lower, upper
+	if matches[1] != "" && matches[2] != "" {
+		// H(min-max) syntax
+		parsedMin, err := strconv.Atoi(matches[1])
+		if err != nil {
+			return "", fmt.Errorf("invalid min value in %s field: %s", name, matches[1])
+		}
+		parsedMax, err := strconv.Atoi(matches[2])
+		if err != nil {
+			return "", fmt.Errorf("invalid max value in %s field: %s", name, matches[2])
+		}
+
+		// Validate: min must be <= max (no wraparound)
+		if parsedMin > parsedMax {
+			return "", fmt.Errorf("invalid range in %s field: min (%d) > max (%d)", name, parsedMin, parsedMax)
+		}
+
+		// Validate: values must be within field bounds
+		if parsedMin < lower || parsedMax > upper {
+			return "", fmt.Errorf("range out of bounds in %s field: H(%d-%d), valid range is %d-%d",
+				name, parsedMin, parsedMax, lower, upper)
+		}
+
+		minVal = parsedMin
+		maxVal = parsedMax
+	}
+
+	// Calculate deterministic value within range
+	rangeSize := maxVal - minVal + 1
+	return strconv.Itoa(minVal + int(hashValue%uint32(rangeSize))), nil
+}
@@ -5,9 +5,12 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"math"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-co-op/gocron/v2"
@@ -27,7 +30,7 @@ func NewScriptFlow(app *pocketbase.PocketBase, config *Config, configFilePath st
 	runCfg := &sshrun.RunConfig{
 		DefaultPrivateKey: filepath.Join(homeDir, ".ssh", "id_rsa"),
 	}
-	sshPool := sshrun.NewPool(runCfg)
+	sshPool := newSSHPool(runCfg)
 
 	scheduler, err := gocron.NewScheduler()
 	if err != nil {
@@ -37,7 +40,7 @@ func NewScriptFlow(app *pocketbase.PocketBase, config *Config, configFilePath st
 
 	ctx, cancel := context.WithCancel(context.Background())
 
-	return &ScriptFlow{
+	sf := &ScriptFlow{
 		app:            app,
 		config:         config,
 		configFilePath: configFilePath,
@@ -48,9 +51,17 @@ func NewScriptFlow(app *pocketbase.PocketBase, config *Config, configFilePath st
 		ctx:            ctx,
 		cancelFunc:     cancel,
 		activeJobs:     make(map[string]gocron.Job),
+		scheduledRuns:  make(map[string]gocron.Job),
+		pipelineJobs:   make(map[string]gocron.Job),
 		activeRuns:     make(map[string]context.CancelFunc),
-		runningTaskIds: make(map[string]struct{}),
-	}, nil
+		runningTasks:   make(map[string]int),
+		taskWaiters:    make(map[string][]chan struct{}),
+		nodeQueues:     make(map[string]*nodeQueue),
+		roundRobin:     make(map[string]int),
+		remoteFiles:    make(map[string]remoteFileState),
+		runTriggers:    make(map[string]bool),
+	}
+	return sf, nil
 }
 
 func (sf *ScriptFlow) Start() error {
@@ -68,7 +79,8 @@ func (sf *ScriptFlow) MarkAllRunningTasksAsInterrupted(errorMsg string) {
 			"status":           RunStatusInterrupted,
 			"connection_error": errorMsg,
 		},
-		dbx.HashExp{"status": RunStatusStarted},
+		// queued runs wait in memory, so they are dropped as well
+		dbx.HashExp{"status": []any{RunStatusStarted, RunStatusQueued}},
 	).Execute()
 
 	if err != nil {
@@ -116,6 +128,17 @@ func (sf *ScriptFlow) scheduleSystemTasks() {
 		sf.app.Logger().Error("failed to schedule JobRemoveOutdatedLogs", slog.Any("error", err))
 	}
 
+	// schedule JobCompressLogs task, it compresses synchronously so that passes don't overlap
+	_, err = sf.scheduler.NewJob(
+		gocron.CronJob("19 * * * *", false),
+		gocron.NewTask(sf.JobCompressLogs),
+		gocron.WithTags(SystemTask, JobCompressLogs),
+		gocron.WithSingletonMode(gocron.LimitModeReschedule),
+	)
+	if err != nil {
+		sf.app.Logger().Error("failed to schedule JobCompressLogs", slog.Any("error", err))
+	}
+
 	// schedule JobRemoveOutdatedRecords task
 	_, err = sf.scheduler.NewJob(
 		gocron.CronJob("39 1 * * *", false),
@@ -141,6 +164,28 @@ func (sf *ScriptFlow) scheduleSystemTasks() {
 	if err != nil {
 		sf.app.Logger().Error("failed to schedule JobReconcileJobs", slog.Any("error", err))
 	}
+
+	// schedule JobPollRemoteFiles task, it polls synchronously so that slow nodes don't pile up polls
+	_, err = sf.scheduler.NewJob(
+		gocron.DurationJob(RemoteFilePollInterval),
+		gocron.NewTask(sf.JobPollRemoteFiles),
+		gocron.WithTags(SystemTask, JobPollRemoteFiles),
+		gocron.WithSingletonMode(gocron.LimitModeReschedule),
+	)
+	if err != nil {
+		sf.app.Logger().Error("failed to schedule JobPollRemoteFiles", slog.Any("error", err))
+	}
+
+	// schedule JobHeartbeat task, the last heartbeat bounds the runs missed while scriptflow was down
+	_, err = sf.scheduler.NewJob(
+		gocron.DurationJob(HeartbeatInterval),
+		gocron.NewTask(sf.JobHeartbeat),
+		gocron.WithTags(SystemTask, JobHeartbeat),
+		gocron.WithSingletonMode(gocron.LimitModeReschedule),
+	)
+	if err != nil {
+		sf.app.Logger().Error("failed to schedule JobHeartbeat", slog.Any("error", err))
+	}
 }
 
 func (sf *ScriptFlow) scheduleActiveTasks() {
@@ -161,6 +206,23 @@ func (sf *ScriptFlow) scheduleActiveTasks() {
 	}
 }
 
+// runRebootTasks runs active tasks with @reboot schedule once, when scriptflow starts
+func (sf *ScriptFlow) runRebootTasks() {
+	tasks, err := sf.app.FindAllRecords(
+		CollectionTasks,
+		dbx.HashExp{"active": true, "schedule": RebootSchedule},
+	)
+	if err != nil {
+		sf.app.Logger().Error("failed to find @reboot tasks", slog.Any("error", err))
+		return
+	}
+
+	for _, task := range tasks {
+		sf.app.Logger().Info("run @reboot task", taskAttrs(task))
+		go sf.runTask(task.Id)
+	}
+}
+
 func (sf *ScriptFlow) ScheduleTask(task *core.Record) {
 	// Acquire lock to ensure scheduler access is thread-safe
 	sf.locks.scheduleTask.Lock()
@@ -171,14 +233,14 @@ func (sf *ScriptFlow) ScheduleTask(task *core.Record) {
 	// Check if job already exists
 	existingJob, jobExists := sf.getActiveJob(taskId)
 
-	// If task is not active, remove the job if it exists
-	if !task.GetBool("active") {
+	// If task is not active or has no schedule, remove the job if it exists. @reboot tasks run on startup only.
+	if !task.GetBool("active") || task.GetString("schedule") == "" || task.GetString("schedule") == RebootSchedule {
 		if jobExists {
 			if err := sf.scheduler.RemoveJob(existingJob.ID()); err != nil {
-				sf.app.Logger().Error("failed to remove inactive task", taskAttrs(task), slog.Any("error", err))
+				sf.app.Logger().Error("failed to remove job of inactive or unscheduled task", taskAttrs(task), slog.Any("error", err))
 			} else {
 				sf.removeActiveJob(taskId)
-				sf.app.Logger().Info("removed inactive task", taskAttrs(task))
+				sf.app.Logger().Info("removed job of inactive or unscheduled task", taskAttrs(task))
 			}
 		}
 		return
@@ -189,37 +251,42 @@ func (sf *ScriptFlow) ScheduleTask(task *core.Record) {
 	schedule := task.GetString("schedule")
 
 	var jobDefinition gocron.JobDefinition
+	runFunc := sf.runTask
 
 	// Parse schedule to create appropriate job definition
-	if strings.HasPrefix(schedule, "@every ") {
-		duration, parseErr := time.ParseDuration(schedule[7:])
-		if parseErr != nil {
-			sf.app.Logger().Error("failed to parse duration", taskAttrs(task), slog.Any("error", parseErr))
+	if at, isAt, err := parseAtSchedule(schedule); isAt {
+		if err != nil {
+			sf.app.Logger().Error("invalid one-time schedule", taskAttrs(task), slog.Any("error", err))
 			return
 		}
-		// spread tasks by 10% of duration to avoid running them simultaneously
-		min, max := durationMinMax(duration)
-		jobDefinition = gocron.DurationRandomJob(min, max)
+		// the task has fired and is being deactivated, or the time passed while scriptflow was not running
+		// and the missed run is recorded on startup
+		if !at.After(time.Now()) {
+			if jobExists {
+				if err := sf.scheduler.RemoveJob(existingJob.ID()); err != nil {
+					sf.app.Logger().Error("failed to remove fired one-time task", taskAttrs(task), slog.Any("error", err))
+				} else {
+					sf.removeActiveJob(taskId)
+				}
+			}
+			return
+		}
+		jobDefinition = gocron.OneTimeJob(gocron.OneTimeJobStartDateTime(at))
+		runFunc = sf.runOneTimeTask
 	} else {
-		// Resolve Jenkins-style H notation if present
-		resolvedSchedule, err := resolveHashedSchedule(schedule, taskId)
+		var err error
+		jobDefinition, err = recurringJobDefinition(schedule, taskId, sf.taskTimezone(task))
 		if err != nil {
-			sf.app.Logger().Error("invalid hashed schedule", taskAttrs(task), slog.Any("error", err))
+			sf.app.Logger().Error("invalid schedule", taskAttrs(task), slog.Any("error", err))
 			return
 		}
-		if resolvedSchedule != schedule {
-			sf.app.Logger().Debug("resolved hashed schedule",
-				slog.String("taskId", taskId),
-				slog.String("original", schedule),
-				slog.String("resolved", resolvedSchedule))
-		}
-		jobDefinition = gocron.CronJob(resolvedSchedule, false)
 	}
 
-	taskFunc := gocron.NewTask(sf.runTask, taskId)
+	taskFunc := gocron.NewTask(runFunc, taskId)
+	// overlapping triggers are handled by the task concurrency policy in runTask
 	jobOptions := []gocron.JobOption{
 		gocron.WithTags(taskId),
-		gocron.WithSingletonMode(gocron.LimitModeReschedule),
+		gocron.WithCronImplementation(&extendedCron{}),
 	}
 
 	if jobExists {
@@ -254,50 +321,201 @@ func (sf *ScriptFlow) ScheduleTask(task *core.Record) {
 
 // run scheduled task
 func (sf *ScriptFlow) runTask(taskId string) {
-	if !sf.tryLockTask(taskId) {
-		sf.app.Logger().Info("task already running, skipping", slog.String("taskId", taskId))
+	sf.triggerTask(taskId, RunTrigger{})
+}
+
+// triggerTask runs the task, runs and skipped runs of the trigger are recorded with its attributes
+func (sf *ScriptFlow) triggerTask(taskId string, trigger RunTrigger) {
+	task, err := sf.app.FindRecordById(CollectionTasks, taskId)
+	if err != nil {
+		sf.app.Logger().Error("failed to find task", slog.String("taskId", taskId), slog.Any("error", err))
+		return
+	}
+	// triggers inside windows of exclude calendars or outside of include calendars are not executed
+	reason, err := sf.calendarSkipReason(task, time.Now())
+	if err != nil {
+		sf.app.Logger().Error("failed to check task calendars", taskAttrs(task), slog.Any("error", err))
+		reason = SkipReasonInternalError
+	}
+	if reason != "" {
+		sf.skipTrigger(task, trigger, reason)
+		return
+	}
+	// triggers without parameter values, e.g. the schedule, run with defaults
+	if trigger.Params == nil {
+		params, err := resolveTaskParams(task, nil)
+		if err != nil {
+			sf.skipTrigger(task, trigger, err.Error())
+			return
+		}
+		trigger.Params = params
+	}
+	if !sf.acquireTaskSlot(task, trigger) {
 		return
 	}
 	defer sf.unlockTask(taskId)
 
-	node, task, err := sf.findNodeAndTaskToRun(taskId)
+	// tasks targeting a node selector fan out to the selected nodes
+	if task.GetString("node_selector") != "" {
+		sf.runExecution(task, trigger)
+		return
+	}
+
+	var failoverReason string
+	node, taskToRun, err := sf.findNodeAndTaskToRun(taskId)
+	if err != nil {
+		node, taskToRun, failoverReason, err = sf.findFallbackNodeAndTaskToRun(taskId, err)
+	}
 	if err != nil {
 		sf.app.Logger().Error("failed to find project, node or task", slog.Any("error", err))
+		sf.skipTrigger(task, trigger, err.Error())
 		return
 	}
+	task = taskToRun
 
-	// Create new run record
-	run, err := sf.createRunRecord(node, task)
+	// Create new run record, it is saved once it gets a run slot of the node or is queued
+	run, err := sf.newRunRecord(node, task, trigger)
 	if err != nil {
 		sf.app.Logger().Error("failed to create record", slog.Any("error", err))
 		return
 	}
+	if failoverReason != "" {
+		sf.app.Logger().Info("run task on fallback node", taskAttrs(task), nodeAttrs(node), slog.String("reason", failoverReason))
+		run.Set("reason", failoverReason)
+	}
+	sf.runWithRetries(node, task, run)
+}
 
+// runWithRetries executes the unsaved run on the node and re-runs it according to the task retry policy
+func (sf *ScriptFlow) runWithRetries(node *core.Record, task *core.Record, run *core.Record) {
+	retries := task.GetInt("retries")
+	for attempt := 1; ; attempt++ {
+		if !sf.acquireNodeSlot(node, run) {
+			return
+		}
+		sf.executeRun(node, task, run)
+		sf.releaseNodeSlot(node.Id)
+
+		// Mark the run as retrying in the same save as its final status,
+		// so notifications and failure count ignore intermediate attempts
+		willRetry := attempt <= retries && isRetryableStatus(run.GetString("status"))
+		run.Set("retrying", willRetry)
+		// the run waiting for the retry stays active, so that killing it, e.g. by the replace policy, cancels the retry
+		retryCtx, cancelRetry := context.WithCancel(sf.ctx)
+		if willRetry {
+			sf.registerActiveRun(run.Id, cancelRetry)
+		}
+		if err := sf.app.Save(run); err != nil {
+			sf.app.Logger().Error("failed to save run record", slog.Any("error", err))
+		}
+		if !willRetry {
+			cancelRetry()
+			return
+		}
+
+		delay := retryDelay(sf.taskDuration(task, "retry_delay"), task.GetFloat("retry_backoff"), attempt)
+		sf.app.Logger().Info("retry task", taskAttrs(task), slog.Int("attempt", attempt+1), slog.Duration("delay", delay))
+		retry := sf.waitRetryDelay(retryCtx, run, delay)
+		sf.unregisterActiveRun(run.Id)
+		cancelRetry()
+		if !retry {
+			return
+		}
+
+		parentRunId := run.GetString("parent_run")
+		if parentRunId == "" {
+			parentRunId = run.Id
+		}
+		previousRun := run
+		var err error
+		node, task, run, err = sf.newRetryRunRecord(previousRun, parentRunId, attempt+1)
+		if err != nil {
+			// retry is not possible, so the previous attempt becomes the final one
+			sf.app.Logger().Error("failed to retry task", slog.String("taskId", previousRun.GetString("task")), slog.Any("error", err))
+			previousRun.Set("retrying", false)
+			if err := sf.app.Save(previousRun); err != nil {
+				sf.app.Logger().Error("failed to save run record", slog.Any("error", err))
+			}
+			return
+		}
+	}
+}
+
+// executeRun executes the task command on the node and sets the resulting status of the run.
+// The run record is not saved, it is up to the caller.
+func (sf *ScriptFlow) executeRun(node *core.Record, task *core.Record, run *core.Record) {
 	// Create cancellable context for this run
 	runCtx, runCancel := context.WithCancel(sf.ctx)
+	defer runCancel()
 	sf.registerActiveRun(run.Id, runCancel)
 	defer sf.unregisterActiveRun(run.Id)
 
-	// Create and open log file
-	logFile, err := sf.createLogFile(task.Id)
+	// Terminate the run when it exceeds the task timeout, if any
+	var timedOut atomic.Bool
+	timeout := sf.taskDuration(task, "timeout")
+	if timeout > 0 {
+		grace := sf.taskDuration(task, "timeout_grace")
+		if grace <= 0 {
+			grace = TimeoutGracePeriod
+		}
+		go sf.enforceRunTimeout(runCtx, runCancel, nodeSSHConfig(node), run.Id, timeout, grace, &timedOut)
+	}
+
+	// Resolve environment variables and secrets of the run
+	env, err := sf.resolveRunEnv(node, task, run)
+	if err != nil {
+		sf.app.Logger().Error("failed to resolve task env", nodeAttrs(node), taskAttrs(task), slog.Any("error", err))
+		run.Set("status", RunStatusInternalError)
+		run.Set("connection_error", err.Error())
+		return
+	}
+
+	// Create and open log file of the run
+	logFile, err := sf.createRunLogFile(run)
 	if err != nil {
 		sf.app.Logger().Error("Log file error", slog.Any("error", err))
+		run.Set("status", RunStatusInternalError)
+		run.Set("connection_error", err.Error())
 		return
 	}
 	defer logFile.Close()
 
-	// Execute command and process output
+	// Drop the output over the limit of the task or its project, the limit may kill the run
+	project, _ := sf.app.FindRecordById(CollectionProjects, task.GetString("project"))
+	limiter := newOutputLimiter(taskOutputLimit(task, project), runCancel)
+
+	// Execute command and process output, the output is flushed to the log file on an interval
 	sf.app.Logger().Info("execute task", taskAttrs(task), nodeAttrs(node))
-	exitCode, err := sf.executeCommand(runCtx, nodeSSHConfig(node), task, run, logFile)
-	if err != nil {
-		// Check if context was cancelled (killed) first
+	sink := newLogSink(logFile, logFlushInterval)
+	exitCode, err := sf.executeCommand(runCtx, nodeSSHConfig(node), task, run, env, sink, limiter)
+	// the whole output is in the log file before the run finishes
+	if closeErr := sink.Close(); closeErr != nil {
+		sf.app.Logger().Error("failed to flush log file", slog.Any("error", closeErr))
+	}
+	if limiter.Truncated() {
+		sf.app.Logger().Warn("task output truncated", nodeAttrs(node), taskAttrs(task), slog.String("limit", limiter.limit.String()))
+		run.Set("truncated", true)
+	}
+	// Check if the run was terminated by timeout first, a script handling the SIGTERM
+	// may still exit with 0 within the grace period
+	if timedOut.Load() {
+		sf.app.Logger().Info("task timed out", nodeAttrs(node), taskAttrs(task), slog.Duration("timeout", timeout))
+		run.Set("status", RunStatusTimeout)
+		run.Set("connection_error", fmt.Sprintf("timed out after %s", timeout))
+		run.Set("exit_code", exitCode)
+	} else if err != nil {
+		// Check if the run was cancelled (killed) first
 		if errors.Is(err, context.Canceled) || runCtx.Err() == context.Canceled {
 			sf.app.Logger().Info("task killed", nodeAttrs(node), taskAttrs(task))
 			run.Set("status", RunStatusKilled)
+			if limiter.Truncated() && limiter.limit.Kill {
+				run.Set("connection_error", limiter.marker())
+			}
 		} else {
 			switch e := err.(type) {
 			case *ScriptFlowError:
 				sf.app.Logger().Error("ScriptFlow error", nodeAttrs(node), taskAttrs(task), slog.Any("error", err))
+				run.Set("connection_error", e.Error())
 				run.Set("status", RunStatusInternalError)
 			case *sshrun.SSHError:
 				sf.app.Logger().Error("SSH error", nodeAttrs(node), taskAttrs(task), slog.Any("error", err))
@@ -312,19 +530,162 @@ func (sf *ScriptFlow) runTask(taskId string) {
 				run.Set("status", RunStatusError)
 			}
 		}
-		if err := sf.app.Save(run); err != nil {
-			sf.app.Logger().Error("failed to save run record", slog.Any("error", err))
-		}
 	} else {
 		// Update run record with completion status
 		run.Set("exit_code", exitCode)
 		run.Set("status", RunStatusCompleted)
-		if err := sf.app.Save(run); err != nil {
-			sf.app.Logger().Error("failed to save run record(completed)", slog.Any("error", err))
-		}
 	}
 }
 
+// waitRetryDelay waits before the next attempt of the run, which holds the run slot of the task meanwhile.
+// Returns false if the retry was cancelled by the context, the run is the final attempt then.
+func (sf *ScriptFlow) waitRetryDelay(ctx context.Context, run *core.Record, delay time.Duration) bool {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+	}
+	if sf.ctx.Err() != nil {
+		return false
+	}
+	sf.app.Logger().Info("retry cancelled", slog.String("runId", run.Id))
+	run.Set("retrying", false)
+	if err := sf.app.Save(run); err != nil {
+		sf.app.Logger().Error("failed to save run record", slog.Any("error", err))
+	}
+	return false
+}
+
+// isRetryableStatus reports whether a run finished with the status can be retried
+func isRetryableStatus(status string) bool {
+	return status == RunStatusError || status == RunStatusInterrupted
+}
+
+// retryDelay returns delay before the next attempt: baseDelay * backoff^(attempt-1).
+// Backoff less than 1 is treated as 1 (constant delay).
+func retryDelay(baseDelay time.Duration, backoff float64, attempt int) time.Duration {
+	if backoff < 1 {
+		backoff = 1
+	}
+	return time.Duration(float64(baseDelay) * math.Pow(backoff, float64(attempt-1)))
+}
+
+// newRetryRunRecord re-checks node and task of the previous run and returns unsaved run record for the next attempt
+func (sf *ScriptFlow) newRetryRunRecord(previousRun *core.Record, parentRunId string, attempt int) (*core.Record, *core.Record, *core.Record, error) {
+	task, err := sf.app.FindRecordById(CollectionTasks, previousRun.GetString("task"))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	node, err := sf.findNodeToRun(previousRun.GetString("node"), task)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	trigger := RunTrigger{
+		Type:      previousRun.GetString("trigger"),
+		Execution: previousRun.GetString("execution"),
+		Pipeline:  previousRun.GetString("pipeline"),
+		SourceRun: previousRun.GetString("source_run"),
+	}
+	if err := previousRun.UnmarshalJSONField("env", &trigger.Env); err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid env of run %s: %w", previousRun.Id, err)
+	}
+	if err := previousRun.UnmarshalJSONField("params", &trigger.Params); err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid params of run %s: %w", previousRun.Id, err)
+	}
+	run, err := sf.newRunRecord(node, task, trigger)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	run.Set("attempt", attempt)
+	run.Set("parent_run", parentRunId)
+	return node, task, run, nil
+}
+
+// enforceRunTimeout waits for the run timeout, then sends SIGTERM to the remote process group,
+// waits for the grace period and sends SIGKILL. Returns as soon as the run context is done.
+func (sf *ScriptFlow) enforceRunTimeout(ctx context.Context, cancel context.CancelFunc, sshCfg *sshrun.SSHConfig, runId string, timeout, grace time.Duration, timedOut *atomic.Bool) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return
+	case <-timer.C:
+	}
+
+	timedOut.Store(true)
+	sf.app.Logger().Info("run timed out, terminating", slog.String("runId", runId), slog.Duration("timeout", timeout))
+	sf.signalRemoteRun(sshCfg, runId, "TERM")
+
+	timer.Reset(grace)
+	select {
+	case <-ctx.Done():
+		return
+	case <-timer.C:
+	}
+
+	sf.app.Logger().Info("run did not stop after SIGTERM, killing", slog.String("runId", runId), slog.Duration("grace", grace))
+	sf.signalRemoteRun(sshCfg, runId, "KILL")
+	cancel()
+}
+
+// signalRemoteRun sends signal to the process group of the run on the remote node.
+// The process group id is taken from the pid file written by wrapCommandWithPidFile.
+func (sf *ScriptFlow) signalRemoteRun(sshCfg *sshrun.SSHConfig, runId string, signal string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	pidFile := fmt.Sprintf(RemotePidFile, runId)
+	cmd := fmt.Sprintf(`pid=$(cat "%[1]s" 2>/dev/null) && kill -%[2]s -- -"$pid"`, pidFile, signal)
+	_, err := sf.sshPool.RunContext(ctx, sshCfg, cmd, func(stdout string) {}, func(stderr string) {})
+	if err != nil {
+		sf.app.Logger().Error("failed to signal remote run",
+			slog.String("runId", runId),
+			slog.String("signal", signal),
+			slog.Any("error", err))
+	}
+}
+
+// wrapCommandWithPidFile makes the remote shell store its pid before running the command.
+// sshd starts the shell as a session leader, so the pid is also the process group id
+// of everything the command spawns. The pid file sits in a directory private to the SSH user,
+// so that nobody else on the node can plant a pid for signalRemoteRun to kill.
+func wrapCommandWithPidFile(command string, runId string) string {
+	pidFile := fmt.Sprintf(RemotePidFile, runId)
+	return fmt.Sprintf(`mkdir -p -m 700 "%[1]s"; echo $$ > "%[2]s"; trap 'rm -f "%[2]s"' EXIT; %[3]s`, RemotePidDir, pidFile, command)
+}
+
+// taskDuration returns the duration stored in the task field as a Go duration string.
+// Returns zero if the field is empty or invalid.
+func (sf *ScriptFlow) taskDuration(task *core.Record, field string) time.Duration {
+	value := task.GetString(field)
+	if value == "" {
+		return 0
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		sf.app.Logger().Error("failed to parse task duration", taskAttrs(task), slog.String("field", field), slog.Any("error", err))
+		return 0
+	}
+	return duration
+}
+
+// taskTimezone returns time zone of the task schedule, the project default is used if the task has none.
+// Empty string means server local zone.
+func (sf *ScriptFlow) taskTimezone(task *core.Record) string {
+	if timezone := task.GetString("timezone"); timezone != "" {
+		return timezone
+	}
+	project, err := sf.app.FindRecordById(CollectionProjects, task.GetString("project"))
+	if err != nil {
+		return ""
+	}
+	timezone, _ := GetCollectionConfigAttr(project, "timezone", "")
+	timezoneStr, _ := timezone.(string)
+	return timezoneStr
+}
+
 // return corresponding project, node and task to run
 // check that node is online and task is active
 func (sf *ScriptFlow) findNodeAndTaskToRun(taskId string) (*core.Record, *core.Record, error) {
@@ -334,43 +695,135 @@ func (sf *ScriptFlow) findNodeAndTaskToRun(taskId string) (*core.Record, *core.R
 		return nil, nil, err
 	}
 
-	// Fetch node record
-	node, err := sf.app.FindRecordById(CollectionNodes, task.GetString("node"))
+	node, err := sf.findNodeToRun(task.GetString("node"), task)
 	if err != nil {
 		return nil, nil, err
 	}
+	return node, task, nil
+}
+
+// findFallbackNodeAndTaskToRun returns the first fallback node of the task which is online, when the primary
+// node can't run the task because of primaryErr, and the reason why the primary node was skipped.
+// Returns primaryErr if the task is not active or none of its fallback nodes is online.
+func (sf *ScriptFlow) findFallbackNodeAndTaskToRun(taskId string, primaryErr error) (*core.Record, *core.Record, string, error) {
+	task, err := sf.app.FindRecordById(CollectionTasks, taskId)
+	if err != nil || !task.GetBool("active") {
+		return nil, nil, "", primaryErr
+	}
+
+	primaryName := task.GetString("node")
+	if primary, err := sf.app.FindRecordById(CollectionNodes, primaryName); err == nil {
+		primaryName = primary.GetString("username") + "@" + primary.GetString("host")
+	}
+
+	for _, nodeId := range task.GetStringSlice("fallback_nodes") {
+		node, err := sf.findNodeToRun(nodeId, task)
+		if err != nil {
+			continue
+		}
+		return node, task, fmt.Sprintf("primary node %s skipped: %s", primaryName, primaryErr), nil
+	}
+	return nil, nil, "", primaryErr
+}
+
+// return the node to run the task on
+// check that node is online and task is active
+func (sf *ScriptFlow) findNodeToRun(nodeId string, task *core.Record) (*core.Record, error) {
+	// Fetch node record
+	node, err := sf.app.FindRecordById(CollectionNodes, nodeId)
+	if err != nil {
+		return nil, NewNodeNotFoundError()
+	}
 
 	// Skip task if the node is offline
 	if node.GetString("status") != NodeStatusOnline {
-		return nil, nil, NewNodeStatusNotOnlineError()
+		return nil, NewNodeStatusNotOnlineError()
 	}
 
 	// Skip task if it is not active
 	if !task.GetBool("active") {
-		return nil, nil, NewTaskNotActiveError()
+		return nil, NewTaskNotActiveError()
 	}
 
-	return node, task, nil
+	return node, nil
 }
 
-func (sf *ScriptFlow) createRunRecord(node *core.Record, task *core.Record) (*core.Record, error) {
+// skipTrigger records the trigger of the task which is not executed as a skipped run with the reason,
+// so that it shows up in the history and can be notified about
+func (sf *ScriptFlow) skipTrigger(task *core.Record, trigger RunTrigger, reason string) {
+	sf.app.Logger().Info("skip task trigger", taskAttrs(task), slog.String("reason", reason))
+	if _, err := sf.recordUnexecutedRun(task, trigger, RunStatusSkipped, reason); err != nil {
+		sf.app.Logger().Error("failed to record skipped run", taskAttrs(task), slog.Any("error", err))
+	}
+}
+
+// recordUnexecutedRun saves a run of the task which was not executed (yet) with the status and the reason why
+func (sf *ScriptFlow) recordUnexecutedRun(task *core.Record, trigger RunTrigger, status string, reason string) (*core.Record, error) {
+	// the node is only used for the host of the run, so a missing node is not an error
+	node, _ := sf.app.FindRecordById(CollectionNodes, task.GetString("node"))
+	run, err := sf.newRunRecord(node, task, trigger)
+	if err != nil {
+		return nil, err
+	}
+	run.Set("status", status)
+	run.Set("reason", reason)
+	if err := sf.app.Save(run); err != nil {
+		return nil, fmt.Errorf("failed to save run record: %w", err)
+	}
+	return run, nil
+}
+
+// newRunRecord returns unsaved run record of the task started on the node, node may be nil.
+// The queued record of the trigger run id is reused, if there is one.
+func (sf *ScriptFlow) newRunRecord(node *core.Record, task *core.Record, trigger RunTrigger) (*core.Record, error) {
 	runCollection, err := sf.app.FindCollectionByNameOrId(CollectionRuns)
 	if err != nil {
 		return nil, fmt.Errorf("unable to find collection '%s': %w", CollectionRuns, err)
 	}
 
 	run := core.NewRecord(runCollection)
+	if trigger.RunId != "" {
+		// the run of the trigger may have been recorded as queued while it was waiting for a slot
+		if queued, err := sf.app.FindRecordById(CollectionRuns, trigger.RunId); err == nil {
+			run = queued
+		} else {
+			run.Id = trigger.RunId
+		}
+	}
+	params, err := taskParams(task)
+	if err != nil {
+		return nil, err
+	}
 	run.Set("task", task.Id)
-	run.Set("command", task.GetString("command"))
-	run.Set("host", node.GetString("host"))
-	run.Set("status", RunStatusStarted)
-	if err := sf.app.Save(run); err != nil {
-		return nil, fmt.Errorf("failed to save run record: %w", err)
+	run.Set("command", renderCommandParams(task.GetString("command"), params, trigger.Params))
+	if node != nil {
+		run.Set("node", node.Id)
+		run.Set("host", node.GetString("host"))
+	}
+	if trigger.Execution != "" {
+		run.Set("execution", trigger.Execution)
+	}
+	if trigger.Pipeline != "" {
+		run.Set("pipeline", trigger.Pipeline)
+	}
+	if trigger.Type != "" {
+		run.Set("trigger", trigger.Type)
+	}
+	if trigger.SourceRun != "" {
+		run.Set("source_run", trigger.SourceRun)
 	}
+	if len(trigger.Env) > 0 {
+		run.Set("env", trigger.Env)
+	}
+	if len(trigger.Params) > 0 {
+		run.Set("params", trigger.Params)
+	}
+	run.Set("status", RunStatusStarted)
+	run.Set("attempt", 1)
 	return run, nil
 }
 
-func (sf *ScriptFlow) executeCommand(ctx context.Context, sshCfg *sshrun.SSHConfig, task *core.Record, run *core.Record, logFile *os.File) (int, error) {
+func (sf *ScriptFlow) executeCommand(ctx context.Context, sshCfg *sshrun.SSHConfig, task *core.Record, run *core.Record, env *TaskEnv, logFile *logSink, limiter *outputLimiter) (int, error) {
 	// add run mark to the log file
 	runMark := fmt.Sprintf(
 		LogSeparator,
@@ -381,18 +834,33 @@ func (sf *ScriptFlow) executeCommand(ctx context.Context, sshCfg *sshrun.SSHConf
 		return 0, &ScriptFlowError{"failed to write to log file"}
 	}
 	writeLine := func(stream, out string) {
-		line := formatLogLine(time.Now(), stream, out)
+		line := formatLogLine(time.Now(), stream, maskSecrets(out, env.Secrets))
+		allowed, exceeded := limiter.allow(line)
+		if exceeded {
+			// the marker replaces the rest of the output
+			line = formatLogLine(time.Now(), "scriptflow", limiter.marker())
+		} else if !allowed {
+			return
+		}
 		if _, err := logFile.WriteString(line); err != nil {
 			sf.app.Logger().Error("failed to write to log file", slog.Any("error", err))
 		}
-		if err := logFile.Sync(); err != nil {
-			sf.app.Logger().Error("failed to sync log file", slog.Any("error", err))
-		}
 	}
-	return sf.sshPool.RunContext(
+	// make sure the working directory, the interpreter and the sudo user are usable
+	opts := taskCommandOptions(task)
+	if err := sf.checkCommandOptions(ctx, sshCfg, opts); err != nil {
+		return 0, err
+	}
+	// the command of the run has placeholders of the task parameters replaced with their values
+	command, input := wrapCommand(run.GetString("command"), env.Vars, opts)
+	if task.GetString("timeout") != "" {
+		command = wrapCommandWithPidFile(command, run.Id)
+	}
+	return sf.sshPool.RunInputContext(
 		ctx,
 		sshCfg,
-		task.GetString("command"),
+		command,
+		input,
 		func(out string) { writeLine("stdout", out) },
 		func(out string) { writeLine("stderr", out) },
 	)
@@ -435,7 +903,7 @@ func (sf *ScriptFlow) taskLogRootDir(taskId string) string {
 	)
 }
 
-// {taskLogRootDir}/{taskLogFileName}.log
+// {taskLogRootDir}/{taskLogFileName}.log, daily log file of the task used by earlier versions
 func (sf *ScriptFlow) taskLogFilePathDate(taskId string, dateTime time.Time) string {
 	fileName := TaskLogFileName(dateTime.UTC())
 	return filepath.Join(
@@ -444,24 +912,6 @@ func (sf *ScriptFlow) taskLogFilePathDate(taskId string, dateTime time.Time) str
 	)
 }
 
-// Helper function to get today's log file path
-func (sf *ScriptFlow) taskTodayLogFilePath(taskId string) string {
-	return sf.taskLogFilePathDate(taskId, time.Now())
-}
-
-func (sf *ScriptFlow) createLogFile(taskId string) (*os.File, error) {
-	filePath := sf.taskTodayLogFilePath(taskId)
-	logDir := filepath.Dir(filePath)
-	if err := os.MkdirAll(logDir, os.ModePerm); err != nil {
-		return nil, NewFailedCreateLogFileDirectoryError()
-	}
-
-	if _, err := os.Stat(filePath); err == nil {
-		return os.OpenFile(filePath, os.O_APPEND|os.O_WRONLY, os.ModeAppend)
-	}
-	return os.Create(filePath)
-}
-
 func (sf *ScriptFlow) Reload() error {
 	// Serialize reload operations - only one reload at a time
 	sf.reloadMutex.Lock()
@@ -541,27 +991,88 @@ func (sf *ScriptFlow) unregisterActiveRun(runId string) {
 	delete(sf.activeRuns, runId)
 }
 
-func (sf *ScriptFlow) tryLockTask(taskId string) bool {
+func (sf *ScriptFlow) isActiveRun(runId string) bool {
+	sf.runsMutex.Lock()
+	defer sf.runsMutex.Unlock()
+	_, exists := sf.activeRuns[runId]
+	return exists
+}
+
+// tryLockTask takes one of limit run slots of the task, limit 0 means no limit
+func (sf *ScriptFlow) tryLockTask(taskId string, limit int) bool {
 	sf.taskRunMutex.Lock()
 	defer sf.taskRunMutex.Unlock()
-	if _, running := sf.runningTaskIds[taskId]; running {
+	if limit > 0 && sf.runningTasks[taskId] >= limit {
 		return false
 	}
-	sf.runningTaskIds[taskId] = struct{}{}
+	sf.runningTasks[taskId]++
 	return true
 }
 
+// waitLockTask waits until one of limit run slots of the task is free and takes it. Waiting triggers get
+// the slots in the order they arrived. Returns false if there are already maxWaiting triggers waiting or
+// the app is shutting down.
+func (sf *ScriptFlow) waitLockTask(taskId string, limit int, maxWaiting int) bool {
+	sf.taskRunMutex.Lock()
+	if sf.runningTasks[taskId] < limit && len(sf.taskWaiters[taskId]) == 0 {
+		sf.runningTasks[taskId]++
+		sf.taskRunMutex.Unlock()
+		return true
+	}
+	if len(sf.taskWaiters[taskId]) >= maxWaiting {
+		sf.taskRunMutex.Unlock()
+		return false
+	}
+	// the slot is handed over by unlockTask when the channel is closed
+	slot := make(chan struct{})
+	sf.taskWaiters[taskId] = append(sf.taskWaiters[taskId], slot)
+	sf.taskRunMutex.Unlock()
+
+	select {
+	case <-slot:
+		return true
+	case <-sf.ctx.Done():
+	}
+
+	sf.taskRunMutex.Lock()
+	waiters := sf.taskWaiters[taskId]
+	if i := slices.Index(waiters, slot); i >= 0 {
+		sf.taskWaiters[taskId] = slices.Delete(waiters, i, i+1)
+		if len(sf.taskWaiters[taskId]) == 0 {
+			delete(sf.taskWaiters, taskId)
+		}
+		sf.taskRunMutex.Unlock()
+		return false
+	}
+	// the slot was handed over along with the shutdown, pass it on
+	sf.taskRunMutex.Unlock()
+	sf.unlockTask(taskId)
+	return false
+}
+
+// unlockTask releases the run slot of the task, or hands it over to the first waiting trigger
 func (sf *ScriptFlow) unlockTask(taskId string) {
 	sf.taskRunMutex.Lock()
 	defer sf.taskRunMutex.Unlock()
-	delete(sf.runningTaskIds, taskId)
+	if waiters := sf.taskWaiters[taskId]; len(waiters) > 0 {
+		close(waiters[0])
+		sf.taskWaiters[taskId] = waiters[1:]
+		if len(sf.taskWaiters[taskId]) == 0 {
+			delete(sf.taskWaiters, taskId)
+		}
+		return
+	}
+	sf.runningTasks[taskId]--
+	if sf.runningTasks[taskId] <= 0 {
+		delete(sf.runningTasks, taskId)
+	}
 }
 
-func (sf *ScriptFlow) isTaskRunning(taskId string) bool {
+// runningTaskCount returns number of runs of the task currently holding a run slot
+func (sf *ScriptFlow) runningTaskCount(taskId string) int {
 	sf.taskRunMutex.Lock()
 	defer sf.taskRunMutex.Unlock()
-	_, running := sf.runningTaskIds[taskId]
-	return running
+	return sf.runningTasks[taskId]
 }
 
 // KillRun cancels a running task by its run ID
@@ -584,8 +1095,9 @@ func (sf *ScriptFlow) KillRun(runId string) error {
 func (sf *ScriptFlow) UpdateTaskFailureCount(run *core.Record) {
 	status := run.GetString("status")
 
-	// Only process terminal statuses (not "started")
-	if status == RunStatusStarted {
+	// Only process terminal statuses (not "started") of the final attempt,
+	// runs of a fan-out execution are counted by the execution itself
+	if status == RunStatusStarted || run.GetBool("retrying") || isExecutionChild(run) {
 		return
 	}
 
@@ -609,7 +1121,7 @@ func (sf *ScriptFlow) UpdateTaskFailureCount(run *core.Record) {
 	case RunStatusCompleted:
 		// Success - reset counter
 		newCount = 0
-	case RunStatusError, RunStatusInternalError:
+	case RunStatusError, RunStatusInternalError, RunStatusTimeout:
 		// Failure - increment counter
 		newCount = currentCount + 1
 	default:
@@ -612,3 +612,231 @@ func TestFormatLogLine(t *testing.T) {
 		})
 	}
 }
+
+func TestWrapCommandWithPidFile(t *testing.T) {
+	got := wrapCommandWithPidFile("sleep 10; echo done", "abc123")
+	assert.Equal(t, `mkdir -p -m 700 "${XDG_RUNTIME_DIR:-$HOME}/.scriptflow"; `+
+		`echo $$ > "${XDG_RUNTIME_DIR:-$HOME}/.scriptflow/abc123.pid"; `+
+		`trap 'rm -f "${XDG_RUNTIME_DIR:-$HOME}/.scriptflow/abc123.pid"' EXIT; sleep 10; echo done`, got)
+}
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		name      string
+		baseDelay time.Duration
+		backoff   float64
+		attempt   int
+		expected  time.Duration
+	}{
+		{"first attempt, no backoff", 10 * time.Second, 0, 1, 10 * time.Second},
+		{"third attempt, no backoff", 10 * time.Second, 1, 3, 10 * time.Second},
+		{"first attempt, backoff 2", 10 * time.Second, 2, 1, 10 * time.Second},
+		{"second attempt, backoff 2", 10 * time.Second, 2, 2, 20 * time.Second},
+		{"fourth attempt, backoff 2", 10 * time.Second, 2, 4, 80 * time.Second},
+		{"second attempt, backoff 1.5", time.Minute, 1.5, 2, 90 * time.Second},
+		{"backoff below 1 is constant", time.Minute, 0.5, 3, time.Minute},
+		{"zero delay", 0, 2, 3, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, retryDelay(tt.baseDelay, tt.backoff, tt.attempt))
+		})
+	}
+}
+
+func TestIsRetryableStatus(t *testing.T) {
+	assert.True(t, isRetryableStatus(RunStatusError))
+	assert.True(t, isRetryableStatus(RunStatusInterrupted))
+	assert.False(t, isRetryableStatus(RunStatusCompleted))
+	assert.False(t, isRetryableStatus(RunStatusKilled))
+	assert.False(t, isRetryableStatus(RunStatusTimeout))
+	assert.False(t, isRetryableStatus(RunStatusInternalError))
+}
+
+func TestCronFireTimes(t *testing.T) {
+	from := time.Date(2025, 6, 10, 1, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 6, 10, 5, 0, 0, 0, time.UTC)
+
+	times, total, err := cronFireTimes("20 4 * * *", from, to, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, total)
+	assert.Equal(t, []time.Time{time.Date(2025, 6, 10, 4, 20, 0, 0, time.UTC)}, times)
+
+	times, total, err = cronFireTimes("0 * * * *", from, to, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, total, "from is exclusive, to is inclusive")
+	assert.Equal(t, time.Date(2025, 6, 10, 2, 0, 0, 0, time.UTC), times[0])
+	assert.Equal(t, to, times[3])
+
+	times, total, err = cronFireTimes("*/5 * * * *", from, to, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, 48, total)
+	assert.Equal(t, []time.Time{
+		time.Date(2025, 6, 10, 4, 50, 0, 0, time.UTC),
+		time.Date(2025, 6, 10, 4, 55, 0, 0, time.UTC),
+		time.Date(2025, 6, 10, 5, 0, 0, 0, time.UTC),
+	}, times, "latest fire times are kept")
+
+	times, total, err = cronFireTimes("0 3 * * *", to, to.Add(time.Hour), 10)
+	assert.NoError(t, err)
+	assert.Zero(t, total)
+	assert.Empty(t, times)
+
+	_, _, err = cronFireTimes("61 * * * *", from, to, 10)
+	assert.Error(t, err)
+
+	// a schedule with seconds over a year of downtime stops at the cap
+	times, total, err = cronFireTimes("* * * * * *", from, from.AddDate(1, 0, 0), 10)
+	assert.NoError(t, err)
+	assert.Equal(t, cronFireTimesMaxCount, total)
+	assert.Len(t, times, 10)
+	assert.Equal(t, from.Add(cronFireTimesMaxCount*time.Second), times[9])
+}
+
+func TestMissedRunsSince(t *testing.T) {
+	lastRun := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	updated := time.Date(2025, 6, 5, 0, 0, 0, 0, time.UTC)
+	heartbeat := time.Date(2025, 6, 8, 0, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, heartbeat, missedRunsSince(lastRun, updated, heartbeat), "scriptflow was running until the heartbeat")
+	assert.Equal(t, updated, missedRunsSince(lastRun, updated, time.Time{}), "the task was inactive until it was updated")
+	assert.Equal(t, updated, missedRunsSince(lastRun, updated, lastRun.Add(time.Hour)))
+	assert.Equal(t, lastRun, missedRunsSince(lastRun, lastRun.Add(-time.Hour), time.Time{}))
+}
+
+func TestSplitCronTimezone(t *testing.T) {
+	tests := []struct {
+		schedule     string
+		wantZone     string
+		wantSchedule string
+	}{
+		{"0 9 * * *", "", "0 9 * * *"},
+		{"CRON_TZ=Europe/Berlin 0 9 * * *", "Europe/Berlin", "0 9 * * *"},
+		{"TZ=UTC  H 9 * * 1", "UTC", "H 9 * * 1"},
+		{"@every 5m", "", "@every 5m"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.schedule, func(t *testing.T) {
+			zone, schedule := splitCronTimezone(tt.schedule)
+			assert.Equal(t, tt.wantZone, zone)
+			assert.Equal(t, tt.wantSchedule, schedule)
+		})
+	}
+}
+
+func TestScheduleCrontab(t *testing.T) {
+	crontab, location, err := scheduleCrontab("0 9 * * *", "task", "Europe/Berlin")
+	assert.NoError(t, err)
+	assert.Equal(t, "CRON_TZ=Europe/Berlin 0 9 * * *", crontab)
+	assert.Equal(t, "Europe/Berlin", location.String())
+
+	crontab, _, err = scheduleCrontab("CRON_TZ=America/New_York 0 9 * * *", "task", "Europe/Berlin")
+	assert.NoError(t, err)
+	assert.Equal(t, "CRON_TZ=America/New_York 0 9 * * *", crontab, "prefix has priority over the task time zone")
+
+	crontab, location, err = scheduleCrontab("0 9 * * *", "task", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "CRON_TZ="+time.Local.String()+" 0 9 * * *", crontab)
+	assert.Equal(t, time.Local, location)
+
+	resolved, _ := resolveHashedSchedule("H H * * *", "task")
+	crontab, _, err = scheduleCrontab("CRON_TZ=UTC H H * * *", "task", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "CRON_TZ=UTC "+resolved, crontab, "H is resolved after the prefix")
+
+	_, _, err = scheduleCrontab("0 9 * * *", "task", "Mars/Olympus")
+	assert.Error(t, err)
+	_, _, err = scheduleCrontab("CRON_TZ=Mars/Olympus 0 9 * * *", "task", "")
+	assert.Error(t, err)
+	_, _, err = scheduleCrontab("H(30-10) * * * *", "task", "UTC")
+	assert.Error(t, err)
+}
+
+func TestScheduleCrontabKeepsLocalTimeAcrossDST(t *testing.T) {
+	crontab, berlin, err := scheduleCrontab("0 9 * * *", "task", "Europe/Berlin")
+	assert.NoError(t, err)
+
+	// Berlin switches to summer time on 2025-03-30
+	from := time.Date(2025, 3, 28, 12, 0, 0, 0, time.UTC)
+	times, _, err := cronFireTimes(crontab, from, from.Add(72*time.Hour), 10)
+	assert.NoError(t, err)
+	utcHours := make([]int, len(times))
+	for i, fireTime := range times {
+		assert.Equal(t, 9, fireTime.In(berlin).Hour(), "fires at 09:00 Berlin time")
+		utcHours[i] = fireTime.UTC().Hour()
+	}
+	assert.Equal(t, []int{8, 7, 7}, utcHours)
+}
+
+func TestPreviewSchedule(t *testing.T) {
+	now := time.Date(2025, 1, 1, 10, 30, 0, 0, time.UTC)
+
+	preview, err := previewSchedule("0 9 * * *", "task", "Europe/Berlin", now, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, "CRON_TZ=Europe/Berlin 0 9 * * *", preview.Resolved)
+	assert.Equal(t, "Europe/Berlin", preview.Timezone)
+	if assert.Len(t, preview.Next, 3) {
+		berlin, _ := time.LoadLocation("Europe/Berlin")
+		assert.Equal(t, time.Date(2025, 1, 2, 9, 0, 0, 0, berlin), preview.Next[0])
+		assert.Equal(t, time.Date(2025, 1, 4, 9, 0, 0, 0, berlin), preview.Next[2])
+		assert.Equal(t, "Europe/Berlin", preview.Next[0].Location().String())
+	}
+
+	resolved, _ := resolveHashedSchedule("H H * * *", "task")
+	preview, err = previewSchedule("H H * * *", "task", "UTC", now, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "CRON_TZ=UTC "+resolved, preview.Resolved, "H is resolved with the task id")
+
+	preview, err = previewSchedule("@every 1h", "task", "UTC", now, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, "±10%", preview.Jitter)
+	assert.Equal(t, []time.Time{now.Add(time.Hour), now.Add(2 * time.Hour)}, preview.Next)
+
+	for _, schedule := range []string{"@every 0s", "@every nope", "61 * * * *", "* * *", "H(30-10) * * * *"} {
+		_, err = previewSchedule(schedule, "task", "UTC", now, 1)
+		assert.Error(t, err, schedule)
+	}
+	_, err = previewSchedule("0 9 * * *", "task", "Mars/Olympus", now, 1)
+	assert.Error(t, err)
+}
+
+func TestValidateSchedule(t *testing.T) {
+	assert.NoError(t, validateSchedule("*/5 * * * *", "task", ""))
+	assert.NoError(t, validateSchedule("@every 30s", "task", ""))
+	assert.NoError(t, validateSchedule("CRON_TZ=Asia/Tokyo H 3 * * *", "task", ""))
+	assert.Error(t, validateSchedule("", "task", ""))
+	assert.Error(t, validateSchedule("every day", "task", ""))
+}
+
+func TestParseAtSchedule(t *testing.T) {
+	at, isAt, err := parseAtSchedule("@at 2026-11-01T03:00:00Z")
+	assert.True(t, isAt)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 11, 1, 3, 0, 0, 0, time.UTC), at.UTC())
+
+	_, isAt, err = parseAtSchedule("@at tomorrow")
+	assert.True(t, isAt)
+	assert.Error(t, err)
+
+	_, isAt, err = parseAtSchedule("0 3 * * *")
+	assert.False(t, isAt)
+	assert.NoError(t, err)
+}
+
+func TestPreviewAtSchedule(t *testing.T) {
+	now := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	preview, err := previewSchedule("@at 2026-11-01T03:00:00Z", "task", "Europe/Berlin", now, 5)
+	assert.NoError(t, err)
+	assert.Equal(t, "Europe/Berlin", preview.Timezone)
+	if assert.Len(t, preview.Next, 1) {
+		assert.Equal(t, 4, preview.Next[0].Hour(), "fire time is shown in the schedule time zone")
+	}
+
+	preview, err = previewSchedule("@at 2026-09-01T03:00:00Z", "task", "UTC", now, 5)
+	assert.NoError(t, err)
+	assert.Empty(t, preview.Next, "fired one-time schedule has no next fire time")
+
+	assert.Error(t, validateSchedule("@at 2020-01-01T00:00:00Z", "task", ""), "one-time schedule in the past")
+	assert.NoError(t, validateSchedule("@at "+time.Now().Add(time.Hour).Format(time.RFC3339), "task", ""))
+}
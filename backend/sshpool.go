@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/odemakov/sshrun"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// sshPool keeps a connection per node and runs commands over it, the same way as sshrun.Pool does, with input
+// written to stdin of the commands, which sessions of sshrun.Pool don't have. Task commands read their env from
+// the input, so that values of secrets don't show up in the process list of the node. Configs and errors are
+// of the sshrun types.
+type sshPool struct {
+	config  *sshrun.RunConfig
+	mutex   sync.Mutex
+	clients map[string]*ssh.Client
+}
+
+func newSSHPool(config *sshrun.RunConfig) *sshPool {
+	return &sshPool{
+		config:  config,
+		clients: make(map[string]*ssh.Client),
+	}
+}
+
+// RunContext runs the command without input and passes output lines to the callbacks.
+// The command is interrupted when the context is cancelled.
+func (p *sshPool) RunContext(ctx context.Context, sshCfg *sshrun.SSHConfig, cmd string, stdoutCallback func(string), stderrCallback func(string)) (int, error) {
+	return p.RunInputContext(ctx, sshCfg, cmd, "", stdoutCallback, stderrCallback)
+}
+
+// RunInputContext runs the command with the input on its stdin and passes output lines to the callbacks.
+// The command is interrupted when the context is cancelled.
+func (p *sshPool) RunInputContext(ctx context.Context, sshCfg *sshrun.SSHConfig, cmd string, input string, stdoutCallback func(string), stderrCallback func(string)) (int, error) {
+	p.prepareSSHConfig(sshCfg)
+	client, err := p.getClient(sshCfg)
+	if err != nil {
+		return 0, &sshrun.SSHError{Msg: err.Error()}
+	}
+	session, err := client.NewSession()
+	if err != nil {
+		// the connection is broken, the next run reconnects
+		p.drop(sshCfg, client)
+		return 0, &sshrun.SSHError{Msg: err.Error()}
+	}
+	defer session.Close()
+
+	// stdin is closed once the input is written, the command sees the end of it
+	session.Stdin = strings.NewReader(input)
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		return 0, &sshrun.SSHError{Msg: err.Error()}
+	}
+	stderr, err := session.StderrPipe()
+	if err != nil {
+		return 0, &sshrun.SSHError{Msg: err.Error()}
+	}
+	if err := session.Start(cmd); err != nil {
+		return 0, &sshrun.SSHError{Msg: err.Error()}
+	}
+
+	stop := context.AfterFunc(ctx, func() {
+		_ = session.Signal(ssh.SIGINT)
+		_ = session.Close()
+	})
+	defer stop()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go readSessionLines(stdout, stdoutCallback, &wg)
+	go readSessionLines(stderr, stderrCallback, &wg)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	if err := session.Wait(); err != nil {
+		if exitErr, ok := err.(*ssh.ExitError); ok {
+			return exitErr.ExitStatus(), &sshrun.CommandError{Msg: err.Error()}
+		}
+		return 0, &sshrun.SSHError{Msg: err.Error()}
+	}
+	return 0, nil
+}
+
+// readSessionLines passes lines of the output to the callback, if any, with the line break like sshrun does
+func readSessionLines(reader io.Reader, callback func(string), wg *sync.WaitGroup) {
+	defer wg.Done()
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		if callback != nil {
+			callback(scanner.Text() + "\n")
+		}
+	}
+	// drain the rest, so that the session doesn't block on a line over the scanner limit
+	_, _ = io.Copy(io.Discard, reader)
+}
+
+// Put closes the connection to the node, the next run reconnects with the current config of the node
+func (p *sshPool) Put(sshCfg *sshrun.SSHConfig) {
+	p.prepareSSHConfig(sshCfg)
+	key := sshConnKey(sshCfg)
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if client, ok := p.clients[key]; ok {
+		_ = client.Close()
+		delete(p.clients, key)
+	}
+}
+
+// drop closes the connection if it is still the one kept for the node
+func (p *sshPool) drop(sshCfg *sshrun.SSHConfig, client *ssh.Client) {
+	key := sshConnKey(sshCfg)
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.clients[key] == client {
+		delete(p.clients, key)
+	}
+	_ = client.Close()
+}
+
+// prepareSSHConfig fills in the defaults the same way sshrun.Pool does
+func (p *sshPool) prepareSSHConfig(sshCfg *sshrun.SSHConfig) {
+	if sshCfg.Port == 0 {
+		sshCfg.Port = sshrun.DefaultPort
+	}
+	if sshCfg.Timeout == 0 {
+		sshCfg.Timeout = sshrun.DefaultTimeout
+	}
+	if sshCfg.PrivateKey == "" {
+		sshCfg.PrivateKey = p.config.DefaultPrivateKey
+	}
+	if sshCfg.Password == "" {
+		sshCfg.Password = p.config.DefaultPassword
+	}
+}
+
+func sshConnKey(sshCfg *sshrun.SSHConfig) string {
+	return sshCfg.User + "@" + sshCfg.Host + ":" + strconv.Itoa(sshCfg.Port)
+}
+
+// getClient returns the connection to the node, connecting without holding the lock
+func (p *sshPool) getClient(sshCfg *sshrun.SSHConfig) (*ssh.Client, error) {
+	key := sshConnKey(sshCfg)
+	p.mutex.Lock()
+	client, ok := p.clients[key]
+	p.mutex.Unlock()
+	if ok {
+		return client, nil
+	}
+
+	client, err := p.dial(sshCfg)
+	if err != nil {
+		return nil, err
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if existing, ok := p.clients[key]; ok {
+		_ = client.Close()
+		return existing, nil
+	}
+	p.clients[key] = client
+	return client, nil
+}
+
+// dial connects to the node with the key or the password and the known hosts of the sshrun config
+func (p *sshPool) dial(sshCfg *sshrun.SSHConfig) (*ssh.Client, error) {
+	var auth ssh.AuthMethod
+	if sshCfg.PrivateKey != "" {
+		keyData, err := os.ReadFile(sshCfg.PrivateKey)
+		if err != nil {
+			return nil, err
+		}
+		signer, err := ssh.ParsePrivateKey(keyData)
+		if err != nil {
+			return nil, err
+		}
+		auth = ssh.PublicKeys(signer)
+	} else {
+		auth = ssh.Password(sshCfg.Password)
+	}
+
+	hostKeyCallback := p.config.HostKeyCallback
+	if hostKeyCallback == nil {
+		var err error
+		hostKeyCallback, err = knownhosts.New(os.ExpandEnv("$HOME/.ssh/known_hosts"))
+		if err != nil {
+			return nil, fmt.Errorf("host key verification: %w", err)
+		}
+	}
+
+	addr := net.JoinHostPort(sshCfg.Host, strconv.Itoa(sshCfg.Port))
+	conn, err := net.DialTimeout("tcp", addr, sshCfg.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	// the dial timeout doesn't cover the handshake
+	if err := conn.SetDeadline(time.Now().Add(sshCfg.Timeout)); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	clientConn, chans, reqs, err := ssh.NewClientConn(conn, addr, &ssh.ClientConfig{
+		User:            sshCfg.User,
+		Auth:            []ssh.AuthMethod{auth},
+		HostKeyCallback: hostKeyCallback,
+	})
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		_ = clientConn.Close()
+		return nil, err
+	}
+	return ssh.NewClient(clientConn, chans, reqs), nil
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/pem"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/odemakov/sshrun"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+// startTestSSHServer starts SSH server running exec requests with the local sh and returns
+// the config to connect to it
+func startTestSSHServer(t *testing.T) *sshrun.SSHConfig {
+	t.Helper()
+	_, hostKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	hostSigner, err := ssh.NewSignerFromKey(hostKey)
+	require.NoError(t, err)
+	_, clientKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	block, err := ssh.MarshalPrivateKey(clientKey, "")
+	require.NoError(t, err)
+	keyPath := filepath.Join(t.TempDir(), "id_ed25519")
+	require.NoError(t, os.WriteFile(keyPath, pem.EncodeToMemory(block), 0o600))
+
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(ssh.ConnMetadata, ssh.PublicKey) (*ssh.Permissions, error) { return nil, nil },
+	}
+	config.AddHostKey(hostSigner)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveTestSSHConn(conn, config)
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	portNumber, _ := strconv.Atoi(port)
+	return &sshrun.SSHConfig{User: "test", Host: host, Port: portNumber, PrivateKey: keyPath}
+}
+
+func serveTestSSHConn(conn net.Conn, config *ssh.ServerConfig) {
+	_, channels, requests, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(requests)
+	for newChannel := range channels {
+		channel, channelRequests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			defer channel.Close()
+			for request := range channelRequests {
+				if request.Type != "exec" {
+					_ = request.Reply(false, nil)
+					continue
+				}
+				_ = request.Reply(true, nil)
+				length := binary.BigEndian.Uint32(request.Payload)
+				cmd := exec.Command("sh", "-c", string(request.Payload[4:4+length]))
+				cmd.Stdin, cmd.Stdout, cmd.Stderr = channel, channel, channel.Stderr()
+				exitCode := 0
+				if err := cmd.Run(); err != nil {
+					exitCode = 255
+					if exitErr, ok := err.(*exec.ExitError); ok {
+						exitCode = exitErr.ExitCode()
+					}
+				}
+				_, _ = channel.SendRequest("exit-status", false, binary.BigEndian.AppendUint32(nil, uint32(exitCode)))
+				return
+			}
+		}()
+	}
+}
+
+func TestSSHPoolPassesEnvOnStdin(t *testing.T) {
+	sshCfg := startTestSSHServer(t)
+	pool := newSSHPool(&sshrun.RunConfig{HostKeyCallback: ssh.InsecureIgnoreHostKey()})
+	workdir := t.TempDir()
+	env := map[string]string{"A": "it's", "B": "two\nlines"}
+
+	for _, opts := range []CommandOptions{{}, {Workdir: workdir}, {Shell: "sh -eu"}} {
+		command, input := wrapCommand(`echo "$A $B"; read -r line || echo "stdin is at the end"`, env, opts)
+		var mutex sync.Mutex
+		var output []string
+		exitCode, err := pool.RunInputContext(context.Background(), sshCfg, wrapCommandWithPidFile(command, "run"), input,
+			func(out string) {
+				mutex.Lock()
+				defer mutex.Unlock()
+				output = append(output, out)
+			},
+			func(out string) { t.Errorf("unexpected stderr: %s", out) },
+		)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, exitCode)
+		assert.Equal(t, "it's two\nlines\nstdin is at the end\n", strings.Join(output, ""), "options %+v", opts)
+	}
+}
+
+func TestSSHPoolErrors(t *testing.T) {
+	sshCfg := startTestSSHServer(t)
+	pool := newSSHPool(&sshrun.RunConfig{HostKeyCallback: ssh.InsecureIgnoreHostKey()})
+	noOutput := func(string) {}
+
+	exitCode, err := pool.RunContext(context.Background(), sshCfg, "exit 3", noOutput, noOutput)
+	assert.IsType(t, &sshrun.CommandError{}, err)
+	assert.Equal(t, 3, exitCode)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = pool.RunContext(ctx, sshCfg, "sleep 5", noOutput, noOutput)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	pool.Put(sshCfg)
+	_, err = pool.RunContext(context.Background(), &sshrun.SSHConfig{User: "test", Host: "127.0.0.1", Port: 1, PrivateKey: sshCfg.PrivateKey}, "true", noOutput, noOutput)
+	assert.IsType(t, &sshrun.SSHError{}, err)
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// RunTriggerStatuses are statuses of upstream runs which can trigger on_run_of tasks
+var RunTriggerStatuses = []string{
+	RunStatusCompleted, RunStatusError, RunStatusInternalError, RunStatusTimeout,
+	RunStatusKilled, RunStatusInterrupted, RunStatusSkipped, RunStatusMissed,
+}
+
+// remoteFileState is the state of the on_remote_file file seen by the last poll, empty stat means the file is missing
+type remoteFileState struct {
+	Path string
+	Stat string
+}
+
+// runTriggerStatuses returns statuses of upstream runs which trigger the task, "completed" by default
+func runTriggerStatuses(task *core.Record) []string {
+	statuses := task.GetStringSlice("on_run_statuses")
+	if len(statuses) == 0 {
+		return []string{RunStatusCompleted}
+	}
+	return statuses
+}
+
+// isRunTriggerSource reports whether the run is final and can trigger on_run_of tasks. Intermediate attempts
+// and child runs of fan-out executions are left out, their final attempt or execution triggers instead.
+func isRunTriggerSource(run *core.Record) bool {
+	return run.GetString("task") != "" &&
+		!run.GetBool("retrying") &&
+		!isExecutionChild(run) &&
+		slices.Contains(RunTriggerStatuses, run.GetString("status"))
+}
+
+// validateTaskTriggers checks the on_run_of and on_remote_file triggers of the task
+func validateTaskTriggers(taskId string, node string, onRunOf []string, statuses []string, remoteFile string) error {
+	if slices.Contains(onRunOf, taskId) {
+		return fmt.Errorf("task can't be triggered by its own runs")
+	}
+	for _, status := range statuses {
+		if !slices.Contains(RunTriggerStatuses, status) {
+			return fmt.Errorf("invalid on_run_statuses value: %q", status)
+		}
+	}
+	if remoteFile == "" {
+		return nil
+	}
+	if !path.IsAbs(remoteFile) || strings.ContainsAny(remoteFile, "\x00\n\r") {
+		return fmt.Errorf("on_remote_file must be an absolute path: %q", remoteFile)
+	}
+	if node == "" {
+		return fmt.Errorf("on_remote_file requires the task node")
+	}
+	return nil
+}
+
+// findTriggerCycle returns an error if the task is triggered, directly or through other tasks, by its own runs
+func (sf *ScriptFlow) findTriggerCycle(taskId string, onRunOf []string) error {
+	visited := map[string]bool{}
+	queue := slices.Clone(onRunOf)
+	for len(queue) > 0 {
+		upstreamId := queue[0]
+		queue = queue[1:]
+		if upstreamId == taskId {
+			return fmt.Errorf("on_run_of triggers form a cycle")
+		}
+		if visited[upstreamId] {
+			continue
+		}
+		visited[upstreamId] = true
+		upstream, err := sf.app.FindRecordById(CollectionTasks, upstreamId)
+		if err != nil {
+			continue
+		}
+		queue = append(queue, upstream.GetStringSlice("on_run_of")...)
+	}
+	return nil
+}
+
+// validateTaskRecordTriggers rejects task record with invalid triggers or triggers forming a cycle
+func (sf *ScriptFlow) validateTaskRecordTriggers(task *core.Record) error {
+	onRunOf := task.GetStringSlice("on_run_of")
+	err := validateTaskTriggers(task.Id, task.GetString("node"), onRunOf, task.GetStringSlice("on_run_statuses"), task.GetString("on_remote_file"))
+	if err != nil {
+		return validation.Errors{"on_run_of": validation.NewError("validation_invalid_trigger", err.Error())}
+	}
+	if err := sf.findTriggerCycle(task.Id, onRunOf); err != nil {
+		return validation.Errors{"on_run_of": validation.NewError("validation_invalid_trigger", err.Error())}
+	}
+	return nil
+}
+
+// ProcessRunTriggers starts active tasks triggered by the finished run. A run is saved with its final status more
+// than once, e.g. when its retry turns out to be impossible, so the upstream run triggers each task only once.
+func (sf *ScriptFlow) ProcessRunTriggers(run *core.Record) {
+	if !isRunTriggerSource(run) {
+		return
+	}
+	sf.runTriggerMutex.Lock()
+	if sf.runTriggers[run.Id] {
+		sf.runTriggerMutex.Unlock()
+		return
+	}
+	sf.runTriggers[run.Id] = true
+	sf.runTriggerMutex.Unlock()
+
+	var wg sync.WaitGroup
+	defer func() {
+		// once the triggers are done, their runs are recorded with the source run
+		wg.Wait()
+		sf.runTriggerMutex.Lock()
+		delete(sf.runTriggers, run.Id)
+		sf.runTriggerMutex.Unlock()
+	}()
+
+	tasks, err := sf.app.FindRecordsByFilter(
+		CollectionTasks,
+		"active = true && on_run_of.id ?= {:task}",
+		"", 0, 0,
+		dbx.Params{"task": run.GetString("task")},
+	)
+	if err != nil {
+		sf.app.Logger().Error("failed to find triggered tasks", slog.String("runId", run.Id), slog.Any("error", err))
+		return
+	}
+	status := run.GetString("status")
+	for _, task := range tasks {
+		if !slices.Contains(runTriggerStatuses(task), status) {
+			continue
+		}
+		// the task was triggered by an earlier save of the run, e.g. before a restart
+		if _, err := sf.app.FindFirstRecordByFilter(CollectionRuns, "source_run={:run} && task={:task}", dbx.Params{"run": run.Id, "task": task.Id}); err == nil {
+			continue
+		}
+		sf.app.Logger().Info("trigger task by upstream run", taskAttrs(task), slog.String("sourceRunId", run.Id), slog.String("status", status))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sf.triggerTask(task.Id, RunTrigger{Type: TriggerRunOf, SourceRun: run.Id})
+		}()
+	}
+}
+
+// remoteFileTriggered reports whether the file appeared or changed since the previous poll. The first poll of
+// a path only records its state, so that existing files don't trigger tasks on startup.
+func remoteFileTriggered(previous remoteFileState, seen bool, current remoteFileState) bool {
+	return seen && previous.Path == current.Path && current.Stat != "" && current.Stat != previous.Stat
+}
+
+// remoteFileStatCommand returns the command printing modification time and size of the file, or nothing if
+// the file doesn't exist
+func remoteFileStatCommand(filePath string) string {
+	return "stat -c '%Y %s' -- " + shellQuote(filePath) + " 2>/dev/null || true"
+}
+
+// JobPollRemoteFiles checks files of on_remote_file triggers on their nodes and triggers tasks whose file
+// appeared or changed since the previous poll
+func (sf *ScriptFlow) JobPollRemoteFiles() {
+	tasks, err := sf.app.FindAllRecords(CollectionTasks, dbx.HashExp{"active": true}, dbx.Not(dbx.HashExp{"on_remote_file": ""}))
+	if err != nil {
+		sf.app.Logger().Error("failed to query tasks collection", slog.Any("error", err))
+		return
+	}
+
+	polled := make(map[string]bool, len(tasks))
+	var wg sync.WaitGroup
+	for _, task := range tasks {
+		polled[task.Id] = true
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sf.pollRemoteFile(task)
+		}()
+	}
+	wg.Wait()
+
+	// forget files of tasks which are deleted, deactivated or don't watch a file anymore
+	sf.remoteFileMutex.Lock()
+	for taskId := range sf.remoteFiles {
+		if !polled[taskId] {
+			delete(sf.remoteFiles, taskId)
+		}
+	}
+	sf.remoteFileMutex.Unlock()
+}
+
+// pollRemoteFile checks the on_remote_file file of the task on its node and triggers the task if it appeared or changed
+func (sf *ScriptFlow) pollRemoteFile(task *core.Record) {
+	node, err := sf.app.FindRecordById(CollectionNodes, task.GetString("node"))
+	if err != nil {
+		sf.app.Logger().Error("failed to find node", taskAttrs(task), slog.Any("error", err))
+		return
+	}
+	// offline nodes keep the last seen state, so that a file changed meanwhile triggers the task once they are back
+	if node.GetString("status") != NodeStatusOnline {
+		return
+	}
+
+	current := remoteFileState{Path: task.GetString("on_remote_file")}
+	ctx, cancel := context.WithTimeout(sf.ctx, 15*time.Second)
+	defer cancel()
+	_, err = sf.sshPool.RunContext(ctx, nodeSSHConfig(node), remoteFileStatCommand(current.Path), func(out string) {
+		current.Stat = strings.TrimSpace(out)
+	}, func(string) {})
+	if err != nil {
+		sf.app.Logger().Warn("failed to check remote file", taskAttrs(task), nodeAttrs(node), slog.Any("error", err))
+		return
+	}
+
+	sf.remoteFileMutex.Lock()
+	previous, seen := sf.remoteFiles[task.Id]
+	sf.remoteFiles[task.Id] = current
+	sf.remoteFileMutex.Unlock()
+
+	if remoteFileTriggered(previous, seen, current) {
+		sf.app.Logger().Info("trigger task by remote file", taskAttrs(task), slog.String("path", current.Path))
+		go sf.triggerTask(task.Id, RunTrigger{Type: TriggerRemoteFile})
+	}
+}
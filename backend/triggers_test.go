@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateTaskTriggers(t *testing.T) {
+	assert.NoError(t, validateTaskTriggers("task", "", nil, nil, ""))
+	assert.NoError(t, validateTaskTriggers("task", "", []string{"build"}, []string{RunStatusCompleted, RunStatusTimeout}, ""))
+	assert.NoError(t, validateTaskTriggers("task", "node", nil, nil, "/data/export.csv"))
+
+	invalid := []struct {
+		name       string
+		node       string
+		onRunOf    []string
+		statuses   []string
+		remoteFile string
+	}{
+		{"self", "", []string{"build", "task"}, nil, ""},
+		{"status", "", []string{"build"}, []string{RunStatusStarted}, ""},
+		{"relative path", "node", nil, nil, "data/export.csv"},
+		{"multiline path", "node", nil, nil, "/data/export.csv\nrm -rf /"},
+		{"no node", "", nil, nil, "/data/export.csv"},
+	}
+	for _, tc := range invalid {
+		assert.Error(t, validateTaskTriggers("task", tc.node, tc.onRunOf, tc.statuses, tc.remoteFile), tc.name)
+	}
+}
+
+func TestRemoteFileTriggered(t *testing.T) {
+	missing := remoteFileState{Path: "/data/export.csv"}
+	created := remoteFileState{Path: "/data/export.csv", Stat: "1750000000 1024"}
+	modified := remoteFileState{Path: "/data/export.csv", Stat: "1750000060 2048"}
+
+	assert.False(t, remoteFileTriggered(remoteFileState{}, false, created), "first poll only records the state")
+	assert.True(t, remoteFileTriggered(missing, true, created), "file appeared")
+	assert.True(t, remoteFileTriggered(created, true, modified), "file changed")
+	assert.False(t, remoteFileTriggered(modified, true, modified), "file unchanged")
+	assert.False(t, remoteFileTriggered(created, true, missing), "file removed")
+	assert.False(t, remoteFileTriggered(created, true, remoteFileState{Path: "/data/other.csv", Stat: "1"}), "path of the task changed")
+}
+
+func TestRemoteFileStatCommand(t *testing.T) {
+	assert.Equal(t, `stat -c '%Y %s' -- '/data/it'\''s.csv' 2>/dev/null || true`, remoteFileStatCommand("/data/it's.csv"))
+}
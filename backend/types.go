@@ -4,9 +4,9 @@ import (
 	"context"
 	"log/slog"
 	"sync"
+	"time"
 
 	"github.com/go-co-op/gocron/v2"
-	"github.com/odemakov/sshrun"
 	"github.com/pocketbase/pocketbase"
 	"github.com/pocketbase/pocketbase/core"
 	"github.com/pocketbase/pocketbase/tools/types"
@@ -20,6 +20,10 @@ const (
 	CollectionChannels      = "channels"
 	CollectionSubscriptions = "subscriptions"
 	CollectionNotifications = "notifications"
+	CollectionSecrets       = "secrets"
+	CollectionCalendars     = "calendars"
+	CollectionScheduledRuns = "scheduled_runs"
+	CollectionPipelines     = "pipelines"
 	ChannelTypeEmail        = "email"
 	ChannelTypeSlack        = "slack"
 )
@@ -30,15 +34,100 @@ const (
 	SchedulePeriod           = 60 // max delay in seconds for tasks with @every schedule
 	LogSeparator             = "[%s] [scriptflow] run %s"
 	LogsMaxDays              = 90
+	LogsUncompressedDays     = 1 // days log files stay uncompressed by default
 	SendMaxErrorCount        = 3
 	JobCheckNodeStatus       = "check-node-status"
 	JobRemoveOutdatedLogs    = "remove-outdated-logs"
+	JobCompressLogs          = "compress-logs"
 	JobRemoveOutdatedRecords = "remove-outdated-records"
 	JobSendNotifications     = "send-notifications"
 	JobReconcileJobs         = "reconcile-jobs"
+	JobPollRemoteFiles       = "poll-remote-files"
+	JobHeartbeat             = "heartbeat"
 	SystemTask               = "system-task"
+	ScheduledRunTask         = "scheduled-run"  // tag of jobs of the scheduled_runs collection
+	PipelineTask             = "pipeline"       // tag of jobs of scheduled pipelines
+	TimeoutGracePeriod       = 10 * time.Second // default delay between SIGTERM and SIGKILL for timed out runs
+	RemotePidFile            = RemotePidDir + "/%s.pid"
+	RemotePidDir             = "${XDG_RUNTIME_DIR:-$HOME}/.scriptflow"
+	SecretRefPrefix          = "secret:" // env value referencing the secrets collection, e.g. "secret:db-password"
+	SecretMask               = "***"
+	SecretsKeyEnv            = "SCRIPTFLOW_SECRETS_KEY" // 32 characters AES key used to encrypt secrets
+	TaskQueueMaxSize         = 10                       // max number of triggers waiting for a run slot of a task
+	MissedRunsMaxCount       = 100                      // max number of missed runs recorded per task on startup
+	WebhookMaxPayloadSize    = 1 << 20                  // max size of the JSON payload of a webhook request
+	WebhookSignatureHeader   = "X-Scriptflow-Signature" // "sha256=<hex HMAC-SHA256 of "<timestamp>.<body>">" of signed webhooks
+	WebhookTimestampHeader   = "X-Scriptflow-Timestamp" // unix time in seconds when the signed webhook request was sent
+	WebhookMaxAge            = 5 * time.Minute          // signed webhook requests older or newer than that are rejected
+	WebhookTokenLength       = 40
+	RunWaitDefaultTimeout    = 5 * time.Minute // how long the run API waits for the run to finish by default
+	RunWaitMaxTimeout        = time.Hour
+	RunWaitOutputLines       = 100              // default number of the last output lines returned by the run API
+	RemoteFilePollInterval   = 30 * time.Second // how often files of on_remote_file triggers are checked
+	HeartbeatInterval        = time.Minute      // how often the time scriptflow is running is recorded
+	HeartbeatFileName        = "sf_heartbeat"   // file in the data dir with the last recorded time
 )
 
+// types of triggers stored on runs, runs started by the schedule have no trigger type
+const (
+	TriggerWebhook    = "webhook"
+	TriggerManual     = "manual"
+	TriggerRunOf      = "on_run_of"      // a run of an upstream task finished
+	TriggerRemoteFile = "on_remote_file" // a file on the task node appeared or changed
+)
+
+const (
+	ConcurrencyPolicySkip    = "skip"
+	ConcurrencyPolicyQueue   = "queue"
+	ConcurrencyPolicyReplace = "replace"
+	ConcurrencyPolicyAllow   = "allow"
+)
+
+var ConcurrencyPolicies = []string{ConcurrencyPolicySkip, ConcurrencyPolicyQueue, ConcurrencyPolicyReplace, ConcurrencyPolicyAllow}
+
+// reasons of skipped runs, besides errors of findNodeAndTaskToRun
+const (
+	SkipReasonTaskRunning   = "task is already running"
+	SkipReasonQueueFull     = "task queue is full"
+	SkipReasonMaxParallel   = "max parallel runs reached"
+	SkipReasonNoNodeMatches = "no online node matches selector"
+	SkipReasonInternalError = "internal error, see logs"
+	// trigger inside a window of an exclude calendar
+	SkipReasonBlackout = "blackout"
+	// trigger outside of windows of include calendars
+	SkipReasonOutsideCalendar = "outside include calendar"
+	SkipReasonPipelineRunning = "pipeline is already running"
+	// step of a pipeline run which was killed before the step started
+	SkipReasonPipelineKilled = "pipeline run is killed"
+)
+
+// node modes of tasks targeting a node selector
+const (
+	NodeModeAll    = "all"     // run on every matching node
+	NodeModeAnyOne = "any-one" // run on one online node, round-robin
+	NodeModeNOf    = "n-of"    // run on node_count online nodes, round-robin
+)
+
+var NodeModes = []string{NodeModeAll, NodeModeAnyOne, NodeModeNOf}
+
+// catch-up policies of runs missed while scriptflow was not running
+const (
+	CatchupNone   = "none"   // only record missed runs
+	CatchupLatest = "latest" // run the task once
+	CatchupAll    = "all"    // run the task once per missed run
+)
+
+var CatchupPolicies = []string{CatchupNone, CatchupLatest, CatchupAll}
+
+// conditions on statuses of upstream steps under which a pipeline step runs
+const (
+	StepWhenOnSuccess = "on_success" // all upstream steps completed
+	StepWhenOnFailure = "on_failure" // any upstream step failed
+	StepWhenAlways    = "always"     // upstream steps finished with any status
+)
+
+var StepConditions = []string{StepWhenOnSuccess, StepWhenOnFailure, StepWhenAlways}
+
 const (
 	RunStatusStarted       = "started"
 	RunStatusError         = "error"
@@ -46,6 +135,10 @@ const (
 	RunStatusInterrupted   = "interrupted"
 	RunStatusInternalError = "internal_error"
 	RunStatusKilled        = "killed"
+	RunStatusTimeout       = "timeout"
+	RunStatusSkipped       = "skipped"
+	RunStatusQueued        = "queued"
+	RunStatusMissed        = "missed"
 )
 
 // ScriptFlowLocks encapsulates the locks for different tasks
@@ -54,23 +147,37 @@ type ScriptFlowLocks struct {
 }
 
 type ScriptFlow struct {
-	app            *pocketbase.PocketBase
-	config         *Config
-	configFilePath string
-	scheduler      gocron.Scheduler
-	sshPool        *sshrun.Pool
-	locks          *ScriptFlowLocks
-	logsDir        string
-	configMutex    sync.RWMutex
-	reloadMutex    sync.Mutex
-	ctx            context.Context
-	cancelFunc     context.CancelFunc
-	activeJobs     map[string]gocron.Job
-	jobsMutex      sync.RWMutex
-	activeRuns     map[string]context.CancelFunc
-	runsMutex      sync.RWMutex
-	runningTaskIds map[string]struct{}
-	taskRunMutex   sync.Mutex
+	app             *pocketbase.PocketBase
+	config          *Config
+	configFilePath  string
+	scheduler       gocron.Scheduler
+	sshPool         *sshPool
+	locks           *ScriptFlowLocks
+	logsDir         string
+	logFilesMutex   sync.Mutex // held while log files are migrated, compressed or removed, so that the jobs don't overlap
+	configMutex     sync.RWMutex
+	reloadMutex     sync.Mutex
+	ctx             context.Context
+	cancelFunc      context.CancelFunc
+	activeJobs      map[string]gocron.Job
+	jobsMutex       sync.RWMutex
+	scheduledRuns   map[string]gocron.Job // jobs of pending one-time runs per scheduled run id
+	scheduledMutex  sync.Mutex
+	pipelineJobs    map[string]gocron.Job // jobs of scheduled pipelines per pipeline id
+	pipelinesMutex  sync.Mutex
+	activeRuns      map[string]context.CancelFunc
+	runsMutex       sync.RWMutex
+	runningTasks    map[string]int             // number of runs holding a run slot per task
+	taskWaiters     map[string][]chan struct{} // triggers waiting for a run slot per task, in arrival order
+	taskRunMutex    sync.Mutex
+	nodeQueues      map[string]*nodeQueue // run slots and queued runs per node
+	nodeQueuesMutex sync.Mutex
+	roundRobin      map[string]int // offset of the next node to pick per task with node selector
+	roundRobinMutex sync.Mutex
+	remoteFiles     map[string]remoteFileState // last seen state of the on_remote_file file per task
+	remoteFileMutex sync.Mutex
+	runTriggers     map[string]bool // upstream runs whose downstream tasks are being triggered
+	runTriggerMutex sync.Mutex
 }
 
 // type Node struct {
@@ -94,6 +201,17 @@ type TaskItem struct {
 	Updated   types.DateTime `db:"updated" json:"updated"`
 }
 
+// RunTrigger describes what triggered runs of a task, zero value means a scheduled or manual run
+type RunTrigger struct {
+	RunId     string            // preassigned id of the run record, so that the caller can return it right away
+	Type      string            // trigger type stored on the run, e.g. TriggerWebhook
+	Env       map[string]string // env variables passed by the trigger
+	Params    map[string]string // resolved values of the task parameters, nil means defaults
+	Execution string            // parent run which the runs belong to, e.g. a pipeline run
+	Pipeline  string            // pipeline which runs the task as its step
+	SourceRun string            // upstream run which triggered the task
+}
+
 type RunItem struct {
 	Id              string         `json:"id"`
 	Task            string         `json:"task"`
@@ -102,6 +220,11 @@ type RunItem struct {
 	Command         string         `json:"command"`
 	ConnectionError string         `json:"connection_error"`
 	ExitCode        int            `json:"exitCode"`
+	Attempt         int            `json:"attempt"`
+	ParentRun       string         `json:"parent_run"`
+	Retrying        bool           `json:"retrying"`
+	Execution       string         `json:"execution"`
+	Pipeline        string         `json:"pipeline"`
 	Created         types.DateTime `db:"created" json:"created"`
 	Updated         types.DateTime `db:"updated" json:"updated"`
 }
@@ -110,6 +233,7 @@ type SubscriptionItem struct {
 	Id        string                  `json:"id"`
 	Name      string                  `json:"name"`
 	Task      string                  `json:"task"`
+	Pipeline  string                  `json:"pipeline"`
 	Channel   string                  `json:"channel"`
 	Threshold int                     `json:"threshold"`
 	Active    bool                    `json:"active"`
@@ -137,6 +261,15 @@ func taskAttrs(task *core.Record) slog.Attr {
 	})
 }
 
+// return pipeline attributes for logging
+func pipelineAttrs(pipeline *core.Record) slog.Attr {
+	return slog.Any("pipeline", map[string]any{
+		"id":       pipeline.Id,
+		"name":     pipeline.GetString("name"),
+		"schedule": pipeline.GetString("schedule"),
+	})
+}
+
 // return project attributes for logging
 func projectAttrs(project *core.Record) slog.Attr {
 	return slog.Any("project", map[string]any{
@@ -148,7 +281,11 @@ func projectAttrs(project *core.Record) slog.Attr {
 
 // ProjectConfig represents the JSON structure of the config field.
 type ProjectConfig struct {
-	LogsMaxDays *int `json:"logsMaxDays"`
+	LogsMaxDays          *int    `json:"logsMaxDays"`
+	LogsUncompressedDays *int    `json:"logsUncompressedDays"`
+	MaxOutputBytes       *int64  `json:"maxOutputBytes"`
+	MaxOutputLines       *int    `json:"maxOutputLines"`
+	Timezone             *string `json:"timezone"`
 }
 
 type NotificationEmailConfig struct {
@@ -177,6 +314,26 @@ func GetCollectionConfigAttr(row *core.Record, attr string, defaultValue any) (a
 			return *config.LogsMaxDays, nil // Dereference pointer to get the value
 		}
 		return defaultValue, nil // Use defaultValue if LogsMaxDays is nil
+	case "logsUncompressedDays":
+		if config.LogsUncompressedDays != nil {
+			return *config.LogsUncompressedDays, nil
+		}
+		return defaultValue, nil
+	case "maxOutputBytes":
+		if config.MaxOutputBytes != nil {
+			return *config.MaxOutputBytes, nil
+		}
+		return defaultValue, nil
+	case "maxOutputLines":
+		if config.MaxOutputLines != nil {
+			return *config.MaxOutputLines, nil
+		}
+		return defaultValue, nil
+	case "timezone":
+		if config.Timezone != nil {
+			return *config.Timezone, nil
+		}
+		return defaultValue, nil
 	default:
 		return defaultValue, nil // Fallback for unsupported attributes
 	}
@@ -185,6 +342,7 @@ func GetCollectionConfigAttr(row *core.Record, attr string, defaultValue any) (a
 type NotificationContext struct {
 	Project      *core.Record
 	Task         *core.Record
+	Pipeline     *core.Record // set instead of Task for runs of a pipeline
 	Run          *core.Record
 	Notification *core.Record
 	Subscription *core.Record
@@ -205,6 +363,7 @@ type MessageContext struct {
 	Header   string
 	Subject  string
 	Status   string
+	Kind     string // "Task" or "Pipeline"
 	TaskName string
 	TaskUrl  string
 	RunUrl   string
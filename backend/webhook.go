@@ -0,0 +1,125 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// webhookSignature returns "sha256=<hex>" HMAC-SHA256 signature of "<timestamp>.<body>" with the token as the key
+func webhookSignature(token string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(token))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// verifyWebhook checks the request of the webhook with the token. Signed webhooks require the signature of
+// the timestamp and the body, the timestamp must be within WebhookMaxAge of now so that a captured request
+// can't be replayed later. Others require the token passed in the URL. Empty token means the webhook is disabled.
+func verifyWebhook(token string, signed bool, urlToken string, signature string, timestamp string, body []byte, now time.Time) bool {
+	if token == "" {
+		return false
+	}
+	if signed {
+		sentAt, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			return false
+		}
+		if age := now.Sub(time.Unix(sentAt, 0)); age > WebhookMaxAge || age < -WebhookMaxAge {
+			return false
+		}
+		return hmac.Equal([]byte(signature), []byte(webhookSignature(token, timestamp, body)))
+	}
+	return subtle.ConstantTimeCompare([]byte(urlToken), []byte(token)) == 1
+}
+
+// webhookEnv returns env variables from fields of the JSON payload selected by the mapping of variable names
+// to dot separated field paths, e.g. "head_commit.id" or "commits.0.id". Missing fields are left out,
+// string values are passed as is, other values as JSON.
+func webhookEnv(mapping map[string]string, payload []byte) (map[string]string, error) {
+	if len(mapping) == 0 {
+		return nil, nil
+	}
+	var data any
+	if len(strings.TrimSpace(string(payload))) > 0 {
+		if err := json.Unmarshal(payload, &data); err != nil {
+			return nil, fmt.Errorf("invalid JSON payload: %w", err)
+		}
+	}
+
+	env := make(map[string]string, len(mapping))
+	for name, path := range mapping {
+		value, ok := payloadField(data, path)
+		if !ok {
+			continue
+		}
+		if s, isString := value.(string); isString {
+			env[name] = s
+			continue
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode payload field %q: %w", path, err)
+		}
+		env[name] = string(encoded)
+	}
+	return env, nil
+}
+
+// payloadField returns value of the payload at the dot separated path, array items are selected by index
+func payloadField(data any, path string) (any, bool) {
+	for _, key := range strings.Split(path, ".") {
+		switch node := data.(type) {
+		case map[string]any:
+			value, ok := node[key]
+			if !ok {
+				return nil, false
+			}
+			data = value
+		case []any:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(node) {
+				return nil, false
+			}
+			data = node[index]
+		default:
+			return nil, false
+		}
+	}
+	return data, data != nil
+}
+
+// validateWebhookEnv checks env variable names and payload field paths of the webhook env mapping
+func validateWebhookEnv(mapping map[string]string) error {
+	for name, path := range mapping {
+		if !envNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid env variable name: %q", name)
+		}
+		if path == "" || strings.Contains(path, "..") || strings.HasPrefix(path, ".") || strings.HasSuffix(path, ".") {
+			return fmt.Errorf("invalid payload field path of %s: %q", name, path)
+		}
+	}
+	return nil
+}
+
+// validateTaskRecordWebhook rejects task record with invalid webhook env mapping
+func validateTaskRecordWebhook(task *core.Record) error {
+	mapping := map[string]string{}
+	if err := task.UnmarshalJSONField("webhook_env", &mapping); err != nil {
+		return validation.Errors{"webhook_env": validation.NewError("validation_invalid_webhook_env", err.Error())}
+	}
+	if err := validateWebhookEnv(mapping); err != nil {
+		return validation.Errors{"webhook_env": validation.NewError("validation_invalid_webhook_env", err.Error())}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyWebhook(t *testing.T) {
+	body := []byte(`{"ref":"main"}`)
+	now := time.Unix(1750000000, 0)
+	timestamp := "1750000000"
+	signature := webhookSignature("secret", timestamp, body)
+
+	assert.True(t, verifyWebhook("secret", false, "secret", "", "", body, now))
+	assert.False(t, verifyWebhook("secret", false, "wrong", "", "", body, now))
+	assert.False(t, verifyWebhook("", false, "", "", "", body, now), "empty token disables the webhook")
+
+	assert.True(t, verifyWebhook("secret", true, "", signature, timestamp, body, now))
+	assert.True(t, verifyWebhook("secret", true, "", signature, timestamp, body, now.Add(WebhookMaxAge)))
+	assert.False(t, verifyWebhook("secret", true, "secret", "", "", body, now), "signed webhook doesn't accept the token in the URL")
+	assert.False(t, verifyWebhook("secret", true, "", signature, timestamp, []byte(`{"ref":"dev"}`), now), "signature of another body")
+	assert.False(t, verifyWebhook("other", true, "", signature, timestamp, body, now), "signature with another token")
+	assert.False(t, verifyWebhook("secret", true, "", signature, "1750000001", body, now), "signature of another timestamp")
+	assert.False(t, verifyWebhook("secret", true, "", signature, timestamp, body, now.Add(WebhookMaxAge+time.Second)), "replayed request")
+	assert.False(t, verifyWebhook("secret", true, "", signature, timestamp, body, now.Add(-WebhookMaxAge-time.Second)), "timestamp in the future")
+	assert.False(t, verifyWebhook("secret", true, "", signature, "", body, now), "missing timestamp")
+}
+
+func TestWebhookEnv(t *testing.T) {
+	payload := []byte(`{
+		"ref": "refs/heads/main",
+		"head_commit": {"id": "abc123", "message": "fix"},
+		"commits": [{"id": "abc123"}, {"id": "def456"}],
+		"forced": false,
+		"size": 2,
+		"repository": {"owner": {"name": "scriptflow"}},
+		"empty": null
+	}`)
+	env, err := webhookEnv(map[string]string{
+		"GIT_REF":    "ref",
+		"COMMIT":     "head_commit.id",
+		"SECOND":     "commits.1.id",
+		"FORCED":     "forced",
+		"SIZE":       "size",
+		"OWNER":      "repository.owner",
+		"MISSING":    "head_commit.author",
+		"OUT_OF_IDX": "commits.5.id",
+		"EMPTY":      "empty",
+	}, payload)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"GIT_REF": "refs/heads/main",
+		"COMMIT":  "abc123",
+		"SECOND":  "def456",
+		"FORCED":  "false",
+		"SIZE":    "2",
+		"OWNER":   `{"name":"scriptflow"}`,
+	}, env)
+
+	env, err = webhookEnv(map[string]string{"GIT_REF": "ref"}, nil)
+	assert.NoError(t, err, "payload is optional")
+	assert.Empty(t, env)
+
+	_, err = webhookEnv(map[string]string{"GIT_REF": "ref"}, []byte("ref=main"))
+	assert.Error(t, err)
+
+	env, err = webhookEnv(nil, []byte("not json"))
+	assert.NoError(t, err, "payload isn't parsed without mapping")
+	assert.Nil(t, env)
+}
+
+func TestValidateWebhookEnv(t *testing.T) {
+	assert.NoError(t, validateWebhookEnv(map[string]string{"GIT_REF": "ref", "COMMIT": "commits.0.id"}))
+	assert.Error(t, validateWebhookEnv(map[string]string{"GIT-REF": "ref"}))
+	assert.Error(t, validateWebhookEnv(map[string]string{"GIT_REF": ""}))
+	assert.Error(t, validateWebhookEnv(map[string]string{"GIT_REF": "head_commit..id"}))
+}
@@ -110,12 +110,16 @@ const RunStatusClass = (status: string): string => {
     case CRunStatus.completed:
       return "badge badge-success bg-opacity-60";
     case CRunStatus.started:
+    case CRunStatus.queued:
       return "badge badge-info bg-opacity-60";
     case CRunStatus.error:
     case CRunStatus.internal_error:
+    case CRunStatus.timeout:
       return "badge badge-error bg-opacity-60";
     case CRunStatus.interrupted:
     case CRunStatus.killed:
+    case CRunStatus.skipped:
+    case CRunStatus.missed:
       return "badge badge-warning bg-opacity-60";
     default:
       return "";
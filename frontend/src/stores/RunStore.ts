@@ -112,6 +112,7 @@ export const useRunStore = defineStore("runs", () => {
             status: data.record.status,
             command: data.record.command,
             connection_error: data.record.connection_error,
+            reason: data.record.reason,
             exit_code: data.record.exit_code,
           } as IRun;
           if (data.action == "update") {
@@ -164,7 +165,7 @@ export const useRunStore = defineStore("runs", () => {
     }
   }
   function getConsecutiveFailureCount(taskId: string) {
-    const errorTypes = [CRunStatus.error, CRunStatus.internal_error];
+    const errorTypes = [CRunStatus.error, CRunStatus.internal_error, CRunStatus.timeout];
     const runs = lastRuns.value[taskId] || [];
 
     if (runs.length === 0 || !errorTypes.includes(runs[0].status)) {
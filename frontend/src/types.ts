@@ -16,6 +16,10 @@ export const CRunStatus = {
   error: "error",
   internal_error: "internal_error",
   killed: "killed",
+  timeout: "timeout",
+  skipped: "skipped",
+  queued: "queued",
+  missed: "missed",
 } as const;
 
 export const CNodeStatus = {
@@ -60,6 +64,9 @@ export interface INode {
   user: string;
   name: string;
   status?: string;
+  env?: Record<string, string>;
+  max_concurrent_runs?: number;
+  labels?: string[];
   created: string;
   updated: string;
 }
@@ -78,6 +85,7 @@ export interface ISubscription {
   id: string;
   name: string;
   task: string;
+  pipeline?: string;
   channel: string;
   event: string[];
   threshold: number;
@@ -111,10 +119,22 @@ export interface IProject {
   collectionName: string;
   name: string;
   config?: Record<string, unknown>;
+  env?: Record<string, string>;
+  exclude_calendars?: string[];
+  include_calendars?: string[];
   created: string;
   updated: string;
 }
 
+export interface ITaskParam {
+  name: string;
+  type?: string; // string, int, float, bool
+  default?: string;
+  allowed?: string[];
+  required?: boolean;
+  description?: string;
+}
+
 export interface ITask {
   id: string;
   collectionName: string;
@@ -124,6 +144,34 @@ export interface ITask {
   schedule?: string;
   project: string;
   node: string;
+  timeout?: string;
+  timeout_grace?: string;
+  retries?: number;
+  retry_delay?: string;
+  retry_backoff?: number;
+  env?: Record<string, string>;
+  workdir?: string;
+  shell?: string;
+  sudo_user?: string;
+  concurrency_policy?: string;
+  max_parallel?: number;
+  node_selector?: string;
+  node_mode?: string;
+  node_count?: number;
+  fallback_nodes?: string[];
+  catchup?: string;
+  timezone?: string;
+  exclude_calendars?: string[];
+  include_calendars?: string[];
+  webhook_signed?: boolean;
+  webhook_env?: Record<string, string>;
+  params?: ITaskParam[];
+  on_run_of?: string[];
+  on_run_statuses?: string[];
+  on_remote_file?: string;
+  max_output_bytes?: number;
+  max_output_lines?: number;
+  output_limit_kill?: boolean;
   consecutive_failure_count?: number;
   expand: {
     project?: IProject;
@@ -137,11 +185,23 @@ export interface IRun {
   id: string;
   collectionName: string;
   task: string;
-  status: string; // started, completed, interrupted, error, internal_error
+  status: string; // started, completed, interrupted, error, internal_error, killed, timeout, skipped, queued, missed
   host: string;
   command: string;
   connection_error: string;
   exit_code: number;
+  attempt?: number;
+  parent_run?: string;
+  retrying?: boolean;
+  reason?: string;
+  execution?: string;
+  pipeline?: string;
+  trigger?: string;
+  env?: Record<string, string>;
+  params?: Record<string, string>;
+  source_run?: string;
+  node?: string;
+  truncated?: boolean;
   expand: {
     task?: ITask;
   };